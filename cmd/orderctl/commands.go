@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"orders/internal/generator"
+	k "orders/internal/kafka"
+	"orders/internal/repository"
+	"orders/internal/validation"
+)
+
+func runGet(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	noCache := fs.Bool("no-cache", false, "read the order from the database, bypassing the cache")
+
+	s, err := newSession(ctx, fs, args)
+	if err != nil {
+		return err
+	}
+	if len(s.args) != 1 {
+		return errors.New("expected exactly one order uid")
+	}
+
+	redisCache, err := s.openCache()
+	if err != nil {
+		return err
+	}
+	defer redisCache.Close()
+
+	repo, err := s.openRepo(redisCache)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	order, err := repo.GetOrderById(s.args[0], s.ctx, !*noCache)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("order %s not found", s.args[0])
+		}
+		return err
+	}
+	return s.render(order, ordersTable([]*generator.Order{order}))
+}
+
+func runList(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	var filter repository.OrdersFilter
+	limit := fs.Int("limit", int(repository.DefaultPageLimit), fmt.Sprintf("orders per page, from 1 to %d", repository.MaxPageLimit))
+	cursor := fs.String("cursor", "", "next_cursor of the previous page")
+	fs.StringVar(&filter.CustomerID, "customer-id", "", "filter by customer id")
+	fs.StringVar(&filter.TrackNumber, "track-number", "", "filter by track number")
+	fs.StringVar(&filter.DeliveryService, "delivery-service", "", "filter by delivery service")
+	fs.StringVar(&filter.Locale, "locale", "", "filter by locale")
+	fs.StringVar(&filter.Entry, "entry", "", "filter by entry")
+	fs.StringVar(&filter.Currency, "currency", "", "filter by payment currency")
+	fs.StringVar(&filter.Provider, "provider", "", "filter by payment provider")
+	fs.StringVar(&filter.Bank, "bank", "", "filter by bank")
+	createdFrom := fs.String("created-from", "", "orders created at or after this date (RFC 3339 or YYYY-MM-DD)")
+	createdTo := fs.String("created-to", "", "orders created before this moment (RFC 3339) or on or before this day (YYYY-MM-DD)")
+
+	s, err := newSession(ctx, fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *limit <= 0 || *limit > int(repository.MaxPageLimit) {
+		return fmt.Errorf("limit should be from 1 to %d", repository.MaxPageLimit)
+	}
+	filter.Limit = int32(*limit)
+	if *cursor != "" {
+		c, err := repository.DecodeCursor(*cursor)
+		if err != nil {
+			return err
+		}
+		filter.Cursor = &c
+	}
+	if filter.CreatedFrom, err = parseDate("created-from", *createdFrom, false); err != nil {
+		return err
+	}
+	if filter.CreatedTo, err = parseDate("created-to", *createdTo, true); err != nil {
+		return err
+	}
+
+	redisCache, err := s.openCache()
+	if err != nil {
+		return err
+	}
+	defer redisCache.Close()
+
+	repo, err := s.openRepo(redisCache)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	page, err := repo.ListOrders(s.ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if err := s.render(page, ordersTable(page.Orders)); err != nil {
+		return err
+	}
+	// В json и yaml курсор уже есть в выводе
+	if s.format == formatTable && page.NextCursor != "" {
+		fmt.Fprintln(os.Stderr, "Next page: -cursor", page.NextCursor)
+	}
+	return nil
+}
+
+// parseDate принимает дату в формате RFC 3339 или просто YYYY-MM-DD.
+// Дата без времени в верхней границе (upper) включает весь день
+func parseDate(name, value string, upper bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		if upper {
+			return date.AddDate(0, 0, 1), nil
+		}
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("%s should be a date in RFC 3339 or YYYY-MM-DD format", name)
+}
+
+func runGenerate(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	s, err := newKafkaSession(ctx, fs, args)
+	if err != nil {
+		return err
+	}
+	if len(s.args) != 1 {
+		return errors.New("expected amount of orders to generate")
+	}
+	amount, err := strconv.Atoi(s.args[0])
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("amount should be a positive integer, got %q", s.args[0])
+	}
+
+	orders := generator.MakeRandomOrder(amount)
+
+	writer := k.CreateWriter(s.cfg.Kafka)
+	defer writer.Close()
+
+	// Каждый заказ уходит отдельным сообщением, как и из /random/{amount}
+	if err := k.WriteOrders(writer, s.ctx, orders, k.MessageKey(s.cfg.Kafka.MessageKey), k.ProducerID(s.cfg.Kafka)); err != nil {
+		return err
+	}
+	return s.render(orders, ordersTable(orders))
+}
+
+// cacheStats - состояние кэша для вывода в json и yaml
+type cacheStats struct {
+	Orders       int64      `json:"orders"`
+	Capacity     int32      `json:"capacity"`
+	TTL          string     `json:"ttl"`
+	OldestAccess *time.Time `json:"oldest_access,omitempty"`
+	NewestAccess *time.Time `json:"newest_access,omitempty"`
+}
+
+func runCache(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	limit := fs.Int("limit", 0, "orders to load by warm, cache.capacity by default")
+
+	s, err := newSession(ctx, fs, args)
+	if err != nil {
+		return err
+	}
+	if len(s.args) != 1 {
+		return errors.New("expected one of stats, flush, warm")
+	}
+
+	redisCache, err := s.openCache()
+	if err != nil {
+		return err
+	}
+	defer redisCache.Close()
+
+	switch s.args[0] {
+	case "stats":
+		stats, err := redisCache.Stats(s.ctx)
+		if err != nil {
+			return err
+		}
+		result := cacheStats{
+			Orders:   stats.Orders,
+			Capacity: stats.Capacity,
+			TTL:      stats.TTL.String(),
+		}
+		// У пустого кэша нет времени обращений
+		if stats.Orders > 0 {
+			result.OldestAccess, result.NewestAccess = &stats.OldestAccess, &stats.NewestAccess
+		}
+		return s.render(result, fieldsTable(
+			"orders", result.Orders,
+			"capacity", result.Capacity,
+			"ttl", result.TTL,
+			"oldest_access", formatTime(stats.OldestAccess),
+			"newest_access", formatTime(stats.NewestAccess),
+		))
+
+	case "flush":
+		// Локальные кэши запущенных экземпляров сервиса очищаются по своему TTL
+		removed, err := redisCache.Flush(s.ctx)
+		if err != nil {
+			return err
+		}
+		result := map[string]int{"removed": removed}
+		return s.render(result, fieldsTable("removed", removed))
+
+	case "warm":
+		if *limit < 0 {
+			return fmt.Errorf("limit should not be negative, got %d", *limit)
+		}
+		capacity := s.cfg.Cache.Capacity
+		if *limit > 0 {
+			capacity = int32(*limit)
+		}
+
+		repo, err := s.openRepo(redisCache)
+		if err != nil {
+			return err
+		}
+		defer repo.Close()
+
+		orders, err := repo.GetLatestOrders(s.ctx, capacity)
+		if err != nil {
+			return err
+		}
+		redisCache.LoadInitialOrders(s.ctx, orders, capacity)
+
+		result := map[string]int{"loaded": len(orders)}
+		return s.render(result, fieldsTable("loaded", len(orders)))
+	}
+	return fmt.Errorf("unknown cache action %q, expected one of stats, flush, warm", s.args[0])
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+// replayResult - итог повторной обработки для вывода
+type replayResult struct {
+	Partition  int   `json:"partition"`
+	FromOffset int64 `json:"from_offset"`
+	EndOffset  int64 `json:"end_offset"`
+	Messages   int   `json:"messages"`
+	Failed     int   `json:"failed"`
+}
+
+func runReplay(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	fromOffset := fs.Int64("from-offset", -1, "offset of the first message to process again (required)")
+	partition := fs.Int("partition", 0, "partition of the orders topic to replay")
+
+	s, err := newSession(ctx, fs, args)
+	if err != nil {
+		return err
+	}
+	if *fromOffset < 0 {
+		return errors.New("-from-offset is required and should not be negative")
+	}
+	if *partition < 0 {
+		return errors.New("-partition should not be negative")
+	}
+
+	reader, err := k.CreateReplayReader(s.cfg.Kafka, *partition, *fromOffset)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// Обрабатываем только то, что уже было в топике на момент запуска
+	lag, err := reader.ReadLag(s.ctx)
+	if err != nil {
+		return fmt.Errorf("Error reading topic lag: %w", err)
+	}
+	result := replayResult{Partition: *partition, FromOffset: *fromOffset, EndOffset: *fromOffset + lag}
+
+	if lag > 0 {
+		redisCache, err := s.openCache()
+		if err != nil {
+			return err
+		}
+		defer redisCache.Close()
+
+		repo, err := s.openRepo(redisCache)
+		if err != nil {
+			return err
+		}
+		defer repo.Close()
+
+		// Заказы проверяются действующей политикой валидации сервиса
+		validator, err := validation.NewPolicies(s.cfg.Validation.PolicyFile)
+		if err != nil {
+			return err
+		}
+
+		dlq := k.CreateDeadLetterWriter(s.cfg.Kafka)
+		defer dlq.Close()
+
+		stats, err := k.Replay(s.ctx, reader, dlq, repo, validator, k.RetryPolicy(s.cfg.Kafka.Retry), result.EndOffset, s.logger())
+		result.Messages, result.Failed = stats.Messages, stats.Failed
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.render(result, fieldsTable(
+		"partition", result.Partition,
+		"from_offset", result.FromOffset,
+		"end_offset", result.EndOffset,
+		"messages", result.Messages,
+		"failed", result.Failed,
+	))
+}
@@ -0,0 +1,168 @@
+// orderctl - утилита для операторов сервиса заказов: просмотр заказов,
+// генерация тестовых заказов, управление кэшем и повторная обработка сообщений.
+// Использует те же настройки, что и сервис: файл, переменные окружения и флаги
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+
+	"orders/internal/cache"
+	"orders/internal/config"
+	"orders/internal/logging"
+	"orders/internal/repository"
+)
+
+// command - подкоманда orderctl
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"get", "<order_uid>", "show an order", runGet},
+	{"list", "", "list orders page by page with filters", runList},
+	{"generate", "<amount>", "publish random orders to the orders Kafka topic", runGenerate},
+	{"cache", "stats|flush|warm", "show, flush or warm the Redis cache", runCache},
+	{"replay", "", "process messages of a -partition of the orders topic again starting from -from-offset", runReplay},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: orderctl <command> [flags] [arguments]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %-18s %s\n", c.name, c.args, c.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'orderctl <command> -h' for command flags")
+}
+
+func main() {
+	godotenv.Load()
+
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "help" {
+		usage()
+		os.Exit(2)
+	}
+
+	i := slices.IndexFunc(commands, func(c command) bool { return c.name == os.Args[1] })
+	if i < 0 {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	c := commands[i]
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	fs := flag.NewFlagSet("orderctl "+c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), strings.TrimSpace("Usage: orderctl "+c.name+" [flags] "+c.args))
+		fs.PrintDefaults()
+	}
+
+	if err := c.run(ctx, fs, os.Args[2:]); err != nil {
+		// Справка по флагам уже выведена
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// session - настройки одной команды и подключения к внешним зависимостям
+type session struct {
+	cfg    *config.Config
+	format string
+	args   []string
+	ctx    context.Context
+}
+
+// newSession разбирает флаги команды вместе с настройками сервиса.
+// Флаги команды должны быть зарегистрированы в fs заранее
+func newSession(ctx context.Context, fs *flag.FlagSet, args []string) (*session, error) {
+	return startSession(ctx, fs, args, config.LoadFlagSet)
+}
+
+// newKafkaSession работает как newSession для команд, которые работают только
+// с Kafka: адреса PostgreSQL и Redis для них не требуются
+func newKafkaSession(ctx context.Context, fs *flag.FlagSet, args []string) (*session, error) {
+	return startSession(ctx, fs, args, config.LoadKafkaFlagSet)
+}
+
+func startSession(ctx context.Context, fs *flag.FlagSet, args []string, load func(fs *flag.FlagSet, args []string) (*config.Config, error)) (*session, error) {
+	format := fs.String("o", formatTable, "output format: "+strings.Join(formats, ", "))
+
+	cfg, err := load(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(formats, *format) {
+		return nil, fmt.Errorf("unknown output format %q, should be one of %s", *format, strings.Join(formats, ", "))
+	}
+
+	// Логи пишутся в stderr, чтобы не смешиваться с выводом команды
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+
+	return &session{
+		cfg:    cfg,
+		format: *format,
+		args:   fs.Args(),
+		ctx:    logging.WithLogger(ctx, logger),
+	}, nil
+}
+
+func (s *session) logger() *slog.Logger {
+	return logging.FromContext(s.ctx)
+}
+
+// render выводит результат команды в выбранном формате
+func (s *session) render(value any, t *table) error {
+	return render(os.Stdout, s.format, value, t)
+}
+
+func (s *session) openCache() (*cache.Cache, error) {
+	redisCache, err := cache.NewCache(s.cfg.Redis.URL, s.cfg.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating cache: %w", err)
+	}
+	return redisCache, nil
+}
+
+// openRepo подключается к бд с настройками репозитория сервиса
+func (s *session) openRepo(c cache.OrdersCache) (*repository.Repository, error) {
+	txScope, err := repository.ParseTxScope(s.cfg.Repository.TxScope)
+	if err != nil {
+		return nil, err
+	}
+	conflictPolicy, err := repository.ParseConflictPolicy(s.cfg.Repository.ConflictPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := repository.NewRepository(s.cfg.Postgres.Driver, s.cfg.Postgres.URL, c)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to database: %w", err)
+	}
+	repo.TxScope = txScope
+	repo.ConflictPolicy = conflictPolicy
+	return repo, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"orders/internal/generator"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Форматы вывода результатов команд
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+var formats = []string{formatTable, formatJSON, formatYAML}
+
+// table - таблица для вывода в формате table
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func (t *table) add(values ...any) {
+	row := make([]string, len(values))
+	for i, v := range values {
+		row[i] = fmt.Sprint(v)
+	}
+	t.rows = append(t.rows, row)
+}
+
+// render выводит value в формате format. Для table выводится таблица t,
+// для json и yaml - сам value с именами полей из json тегов
+func render(w io.Writer, format string, value any, t *table) error {
+	switch format {
+	case formatJSON:
+		data, err := json.MarshalIndent(value, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case formatYAML:
+		// Проходим через JSON, чтобы в YAML были те же имена полей
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		var generic any
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+		data, err = yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format %q, should be one of %s", format, strings.Join(formats, ", "))
+}
+
+// ordersTable - краткая таблица заказов, по строке на заказ
+func ordersTable(orders []*generator.Order) *table {
+	t := &table{header: []string{"ORDER_UID", "TRACK_NUMBER", "STATUS", "CUSTOMER_ID", "AMOUNT", "ITEMS", "CREATED"}}
+	for _, o := range orders {
+		t.add(o.OrderUID, o.TrackNumber, o.Status, o.CustomerID,
+			fmt.Sprintf("%d %s", o.Payment.Amount, o.Payment.Currency), len(o.Items),
+			o.DateCreated.Format(time.DateTime))
+	}
+	return t
+}
+
+// fieldsTable - таблица из пар "поле - значение"
+func fieldsTable(fields ...any) *table {
+	t := &table{header: []string{"FIELD", "VALUE"}}
+	for i := 0; i+1 < len(fields); i += 2 {
+		t.add(fields[i], fields[i+1])
+	}
+	return t
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"orders/internal/generator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// Тестирует вывод заказов в каждом из форматов
+func TestRender(t *testing.T) {
+	orders := generator.MakeRandomOrder(2)
+
+	t.Run("Table", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, render(&out, formatTable, orders, ordersTable(orders)))
+
+		// Заголовок и по строке на заказ
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 3)
+		assert.True(t, strings.HasPrefix(lines[0], "ORDER_UID"))
+		assert.True(t, strings.HasPrefix(lines[1], orders[0].OrderUID))
+		assert.Contains(t, lines[2], orders[1].TrackNumber)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, render(&out, formatJSON, orders, ordersTable(orders)))
+
+		var decoded []*generator.Order
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		require.Len(t, decoded, 2)
+		assert.Equal(t, orders[0].OrderUID, decoded[0].OrderUID)
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, render(&out, formatYAML, orders, ordersTable(orders)))
+
+		// Поля называются так же, как в JSON
+		var decoded []map[string]any
+		require.NoError(t, yaml.Unmarshal(out.Bytes(), &decoded))
+		require.Len(t, decoded, 2)
+		assert.Equal(t, orders[1].OrderUID, decoded[1]["order_uid"])
+		assert.Equal(t, orders[1].Payment.Transaction, decoded[1]["payment"].(map[string]any)["transaction"])
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		assert.Error(t, render(&bytes.Buffer{}, "xml", orders, ordersTable(orders)))
+	})
+}
+
+// Тестирует разбор дат фильтра списка заказов
+func TestParseDate(t *testing.T) {
+	date, err := parseDate("created-from", "2025-10-08", false)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 8, 0, 0, 0, 0, time.UTC), date)
+
+	// Верхняя граница исключается, поэтому дата без времени включает весь день
+	date, err = parseDate("created-to", "2025-10-08", true)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 9, 0, 0, 0, 0, time.UTC), date)
+
+	// Точное время остается как есть
+	date, err = parseDate("created-to", "2025-10-08T12:00:00Z", true)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 8, 12, 0, 0, 0, time.UTC), date)
+
+	date, err = parseDate("created-from", "", false)
+	require.NoError(t, err)
+	assert.True(t, date.IsZero())
+
+	_, err = parseDate("created-from", "08.10.2025", false)
+	assert.ErrorContains(t, err, "created-from")
+}
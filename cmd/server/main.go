@@ -4,40 +4,52 @@ import (
 	"context"
 	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	"orders/internal/app"
+	"orders/internal/config"
 	"orders/internal/dependencies"
+	"orders/internal/logging"
+	"orders/internal/metrics"
 )
 
 func main() {
 	godotenv.Load()
 
-	dbURL := os.Getenv("DB_CONN_STRING")
-	if dbURL == "" {
-		log.Fatalln("DB_CONN_STRING is not found")
+	// Подкоманда migrate управляет схемой бд и не запускает сам сервис
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
 	}
 
-	driver := os.Getenv("DRIVER")
-	if driver == "" {
-		log.Fatalln("DRIVER is not found")
+	// Собираем конфигурацию из файла, окружения и флагов
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalln("Failed to load config:", err)
 	}
 
-	redisURL := os.Getenv("REDIS_CONN_STRING")
-	if redisURL == "" {
-		log.Fatalln("Redis URL is not found")
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		log.Fatalln("Failed to create logger:", err)
 	}
+	// Логгер по умолчанию используется там, где нет контекста запроса или сообщения
+	slog.SetDefault(logger)
 
 	// Создаем внешние зависимости сервиса
-	deps, err := dependencies.InitDependencies(driver, dbURL, redisURL)
+	deps, err := dependencies.InitDependencies(cfg, logger)
 	if err != nil {
-		log.Fatalf("Failed to init dependencies: %s", err)
+		logger.Error("Failed to init dependencies", "error", err)
+		os.Exit(1)
 	}
 
 	// Передаем зависимости и инициализируем приложение
@@ -51,11 +63,33 @@ func main() {
 	staticFileServer := http.FileServer(http.Dir("web/static"))
 	http.Handle("/static/", http.StripPrefix("/static/", staticFileServer))
 
+	// Регистрирует обработчик с подсчетом запросов и задержек по шаблону маршрута
+	handle := func(pattern string, handler http.HandlerFunc) {
+		http.Handle(pattern, metrics.InstrumentHandler(pattern, handler))
+	}
+
 	// Основные эндпоинты
-	http.HandleFunc("/", myApp.HomeHandler)
-	http.HandleFunc("/orders", myApp.ShowOrdersHandler)
-	http.HandleFunc("/orders/{order_uid}", myApp.GetOrderByIdHandler)
-	http.HandleFunc("/random/{amount}", myApp.RandomOrdersHandler)
+	handle("/", myApp.HomeHandler)
+	handle("GET /orders", myApp.ShowOrdersHandler)
+	handle("POST /orders", myApp.CreateOrdersHandler)
+	handle("POST /orders/validate", myApp.ValidateOrdersHandler)
+	handle("/orders/{order_uid}", myApp.GetOrderByIdHandler)
+	// Поиск по трек-номеру и транзакции делит шаблон со статусом заказа,
+	// метрики при этом считаются по исходным маршрутам
+	http.Handle("GET /orders/{order_uid}/{resource}", app.OrderSubpathHandler(
+		metrics.InstrumentHandler("GET /orders/by-track/{track_number}", myApp.GetOrdersByTrackHandler),
+		metrics.InstrumentHandler("GET /orders/by-transaction/{transaction}", myApp.GetOrdersByTransactionHandler),
+		metrics.InstrumentHandler("GET /orders/{order_uid}/status", myApp.GetStatusHandler),
+		metrics.InstrumentHandler("/", myApp.HomeHandler),
+	))
+	handle("PATCH /orders/{order_uid}/status", myApp.UpdateStatusHandler)
+	handle("GET /customers/{customer_id}/orders", myApp.CustomerOrdersHandler)
+	handle("/random/{amount}", myApp.RandomOrdersHandler)
+
+	// Проверки для оркестратора
+	http.HandleFunc("GET /healthz", myApp.LivenessHandler)
+	http.HandleFunc("GET /readyz", myApp.ReadinessHandler)
+	http.Handle("GET /metrics", metrics.Handler())
 
 	// Отдаем файл с документацией и рендерим его по эндпоинту /docs
 	http.HandleFunc("/swagger.yaml", func(w http.ResponseWriter, r *http.Request) {
@@ -64,14 +98,17 @@ func main() {
 	http.Handle("/docs/", httpSwagger.Handler(httpSwagger.URL("/swagger.yaml")))
 
 	// Создаем сервер
+	// Каждому запросу присваивается id, который попадает во все его логи
 	server := &http.Server{
-		Addr: ":8080",
+		Addr:    cfg.HTTP.Addr,
+		Handler: logging.Middleware(logger, http.DefaultServeMux),
 	}
 	// Запускаем сервер фоном, ListenAndServe - блокирующая функция
 	go func() {
-		log.Println("Server is running on http://localhost:8080")
+		logger.Info("Server is running", "addr", cfg.HTTP.Addr)
 		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalln("Server error:", err)
+			logger.Error("Server error", "error", err)
+			os.Exit(1)
 		}
 	}()
 
@@ -79,12 +116,19 @@ func main() {
 	<-sigCtx.Done()
 	// При получении сигнала останавливаем все процессы далее
 
-	log.Println("Service stopped by a signal: shutting down HTTP server...")
+	// Сначала сообщаем о неготовности, чтобы на сервис перестали направлять трафик
+	myApp.MarkNotReady()
+	if cfg.HTTP.ShutdownDelay > 0 {
+		logger.Info("Service stopped by a signal: waiting before shutdown...", "delay", cfg.HTTP.ShutdownDelay)
+		time.Sleep(cfg.HTTP.ShutdownDelay)
+	}
+
+	logger.Info("Service stopped by a signal: shutting down HTTP server...")
 	if err := server.Shutdown(context.Background()); err != nil {
-		log.Printf("HTTP server shutdown error: %v", err)
+		logger.Error("HTTP server shutdown error", "error", err)
 	}
 
 	if err := myApp.Close(); err != nil {
-		log.Println("Service resources close error:", err)
+		logger.Error("Service resources close error", "error", err)
 	}
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"orders/internal/config"
+	"orders/internal/logging"
+	"orders/internal/migrations"
+)
+
+const migrateUsage = "usage: orders-service migrate up|down [steps]|status [flags]"
+
+// runMigrate выполняет подкоманду migrate: применяет, откатывает миграции
+// или показывает их состояние. Флаги и переменные окружения те же, что у сервиса
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	action, args := args[0], args[1:]
+
+	steps := 1
+	if action == "down" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("steps should be a positive integer, got %q", args[0])
+		}
+		steps, args = n, args[1:]
+	}
+
+	cfg, err := config.Load(args)
+	if err != nil {
+		return fmt.Errorf("Failed to load config: %w", err)
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		return fmt.Errorf("Failed to create logger: %w", err)
+	}
+	ctx := logging.WithLogger(context.Background(), logger)
+
+	db, err := sql.Open(cfg.Postgres.Driver, cfg.Postgres.URL)
+	if err != nil {
+		return fmt.Errorf("Error opening database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx, steps)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown migrate action %q, %s", action, migrateUsage)
+}
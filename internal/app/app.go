@@ -1,18 +1,26 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"time"
 
 	"orders/internal/dependencies"
 	"orders/internal/generator"
+	"orders/internal/health"
+	"orders/internal/logging"
 	"orders/internal/repository"
+	"orders/internal/validation"
 
 	c "orders/internal/cache"
 	k "orders/internal/kafka"
@@ -21,41 +29,57 @@ import (
 )
 
 type App struct {
-	kafkaConsumer k.MessagesConsumer
-	kafkaProducer k.MessagesProducer
-	repo          repository.OrdersRepository
-	cache         c.OrdersCache
+	kafkaConsumer   k.MessagesConsumer
+	stopConsumer    context.CancelFunc
+	consumerDone    <-chan struct{}
+	kafkaProducer   k.MessagesProducer
+	deadLetterQueue k.MessagesProducer
+	statusProducer  k.MessagesProducer
+	repo            repository.OrdersRepository
+	cache           c.OrdersCache
+	validator       *validation.Policies
+	messageKey      k.MessageKey
+	producerID      string
+	health          *health.Checker
+	logger          *slog.Logger
 }
 
+// Таймаут проверки каждой зависимости при запросе готовности
+const readinessTimeout = 2 * time.Second
+
 func (a *App) HomeHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+
 	if r.URL.Path != "/" {
 		notFound, err := os.ReadFile("web/templates/404.html")
 		if err != nil {
-			log.Println("Error reading file:", err)
+			logger.Error("Error reading file", "error", err)
 			http.Error(w, "Nothing's here...", http.StatusNotFound)
 			return
 		}
+		w.WriteHeader(http.StatusNotFound)
 		if _, err := w.Write([]byte(notFound)); err != nil {
-			log.Fatalln("Handler error: HomeHandler:", err)
+			logger.Error("Handler error: HomeHandler", "error", err)
 		}
 		return
 	}
 
 	html, err := os.ReadFile("web/templates/index.html")
 	if err != nil {
-		log.Println("Error reading file:", err)
+		logger.Error("Error reading file", "error", err)
 		http.Error(w, "Nothing's here...", http.StatusNotFound)
 		return
 	}
 
 	if _, err := w.Write([]byte(html)); err != nil {
-		log.Fatalln("Handler error: HomeHandler:", err)
+		logger.Error("Handler error: HomeHandler", "error", err)
 	}
 }
 
 func (a *App) GetOrderByIdHandler(w http.ResponseWriter, r *http.Request) {
 	orderUID := r.PathValue("order_uid")
-	ctx := context.Background()
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
 
 	orderData, err := a.repo.GetOrderById(orderUID, ctx, true)
 	if err != nil {
@@ -64,135 +88,410 @@ func (a *App) GetOrderByIdHandler(w http.ResponseWriter, r *http.Request) {
 		} else {
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
+		return
 	}
 
 	orderJSON, err := json.MarshalIndent(orderData, "", "    ")
 	if err != nil {
-		log.Println("Error marshalling JSON:", err)
+		logger.Error("Error marshalling JSON", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	if _, err := w.Write([]byte(orderJSON)); err != nil {
-		log.Fatalln("Handler error: GetOrderByIdHandler:", err)
+		logger.Error("Handler error: GetOrderByIdHandler", "error", err)
 	}
 }
 
 func (a *App) ShowOrdersHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	filter, err := parseOrdersFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	ordersList, err := a.repo.GetAllOrders(ctx)
+	ordersPage, err := a.repo.ListOrders(ctx, filter)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	ordersJSON, err := json.MarshalIndent(ordersList, "", "    ")
+	ordersJSON, err := json.MarshalIndent(ordersPage, "", "    ")
 	if err != nil {
-		log.Println("Error marshalling JSON:", err)
+		logger.Error("Error marshalling JSON", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	if _, err := w.Write([]byte(ordersJSON)); err != nil {
-		log.Fatalln("Handler error: ShowOrdersHandler:", err)
+		logger.Error("Handler error: ShowOrdersHandler", "error", err)
+	}
+}
+
+// parseOrdersFilter разбирает параметры запроса списка заказов
+func parseOrdersFilter(query url.Values) (repository.OrdersFilter, error) {
+	filter := repository.OrdersFilter{
+		CustomerID:      query.Get("customer_id"),
+		TrackNumber:     query.Get("track_number"),
+		DeliveryService: query.Get("delivery_service"),
+		Locale:          query.Get("locale"),
+		Entry:           query.Get("entry"),
+		Currency:        query.Get("currency"),
+		Provider:        query.Get("provider"),
+		Bank:            query.Get("bank"),
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > int(repository.MaxPageLimit) {
+			return filter, fmt.Errorf("limit should be an integer from 1 to %d", repository.MaxPageLimit)
+		}
+		filter.Limit = int32(limit)
 	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := repository.DecodeCursor(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Cursor = &cursor
+	}
+
+	var err error
+	filter.CreatedFrom, err = parseDateParam(query, "created_from", false)
+	if err != nil {
+		return filter, err
+	}
+	filter.CreatedTo, err = parseDateParam(query, "created_to", true)
+	if err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseDateParam принимает дату в формате RFC 3339 или просто YYYY-MM-DD.
+// Верхняя граница (upper) исключается из выборки, поэтому дата без времени
+// в ней сдвигается на следующий день, чтобы заказы этого дня попали в выборку
+func parseDateParam(query url.Values, name string, upper bool) (time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		if upper {
+			return date.AddDate(0, 0, 1), nil
+		}
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("%s should be a date in RFC 3339 or YYYY-MM-DD format", name)
+}
+
+// Максимальный размер тела запроса на создание заказов
+const maxCreateOrdersBody = 10 << 20
+
+// createOrdersResponse - ответ на создание заказов: uid принятых
+// заказов и причины, по которым остальные были отклонены
+type createOrdersResponse struct {
+	Accepted []string        `json:"accepted"`
+	Rejected []rejectedOrder `json:"rejected"`
+}
+
+type rejectedOrder struct {
+	Index    int                     `json:"index"`
+	OrderUID string                  `json:"order_uid,omitempty"`
+	Reason   string                  `json:"reason"`
+	Errors   []validation.FieldError `json:"errors,omitempty"`
+}
+
+func (a *App) CreateOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCreateOrdersBody))
+	if err != nil {
+		http.Error(w, "Bad request: can't read request body", http.StatusBadRequest)
+		return
+	}
+
+	orders, err := decodeOrders(body)
+	if err != nil {
+		http.Error(w, "Bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := createOrdersResponse{
+		Accepted: []string{},
+		Rejected: []rejectedOrder{},
+	}
+
+	// Проверяем заказы по одному, чтобы клиент мог сопоставить отказы со своим запросом
+	var validOrders []*generator.Order
+	for i, order := range orders {
+		valid, rejected := k.ValidateOrders(ctx, a.validator, []*generator.Order{order})
+		if len(rejected) > 0 {
+			rej := rejectedOrder{Index: i, Reason: rejected[0].Reason, Errors: rejected[0].Errors}
+			if order != nil {
+				rej.OrderUID = order.OrderUID
+			}
+			response.Rejected = append(response.Rejected, rej)
+			continue
+		}
+		validOrders = append(validOrders, valid...)
+	}
+
+	status := http.StatusUnprocessableEntity
+	if len(validOrders) > 0 {
+		err := k.WriteOrders(a.kafkaProducer, ctx, validOrders, a.messageKey, a.producerID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		for _, order := range validOrders {
+			response.Accepted = append(response.Accepted, order.OrderUID)
+		}
+		status = http.StatusAccepted
+	}
+
+	responseJSON, err := json.MarshalIndent(response, "", "    ")
+	if err != nil {
+		logger.Error("Error marshalling JSON", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(responseJSON); err != nil {
+		logger.Error("Handler error: CreateOrdersHandler", "error", err)
+	}
+}
+
+// decodeOrders принимает как одиночный заказ, так и массив заказов
+func decodeOrders(body []byte) ([]*generator.Order, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, errors.New("empty request body")
+	}
+
+	var orders []*generator.Order
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &orders); err != nil {
+			return nil, fmt.Errorf("invalid orders array: %w", err)
+		}
+	} else {
+		var order generator.Order
+		if err := json.Unmarshal(body, &order); err != nil {
+			return nil, fmt.Errorf("invalid order: %w", err)
+		}
+		orders = append(orders, &order)
+	}
+
+	if len(orders) == 0 {
+		return nil, errors.New("no orders in request body")
+	}
+	return orders, nil
 }
 
 func (a *App) RandomOrdersHandler(w http.ResponseWriter, r *http.Request) {
 	value := r.PathValue("amount")
 	amount, err := strconv.Atoi(value)
 
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
 	badRequest := func() {
 		result, err := os.ReadFile("web/templates/400.html")
 		if err != nil {
-			http.Error(w, "Nothing's here...", http.StatusNotFound)
-			log.Fatalln("Error reading file:", err)
+			logger.Error("Error reading file", "error", err)
+			http.Error(w, "Bad request: amount should be a positive integer", http.StatusBadRequest)
+			return
 		}
+		w.WriteHeader(http.StatusBadRequest)
 		if _, err := w.Write([]byte(result)); err != nil {
-			log.Fatalln("Handler error: RandomOrdersHandler:", err)
+			logger.Error("Handler error: RandomOrdersHandler", "error", err)
 		}
 	}
 
 	if err != nil {
-		log.Println("Error in internal/app/app.go: amount, err := strconv.Atoi(value):", err)
+		logger.Warn("Error parsing amount of orders to generate", "amount", value, "error", err)
 		badRequest()
 	} else {
 		if amount <= 0 {
-			log.Println("Error creating orders: Value is equal or less than zero")
+			logger.Warn("Error creating orders: Value is equal or less than zero", "amount", amount)
 			badRequest()
 			return
 		}
 
-		ctx := context.Background()
 		orders := generator.MakeRandomOrder(amount)
 
 		orderJSON, err := json.MarshalIndent(orders, "", "    ")
 		if err != nil {
-			log.Println("Error marshalling JSON:", err)
+			logger.Error("Error marshalling JSON", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		err = k.WriteMessage(a.kafkaProducer, ctx, orderJSON)
+		err = k.WriteOrders(a.kafkaProducer, ctx, orders, a.messageKey, a.producerID)
 		if err != nil {
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
 		if _, err := w.Write([]byte(orderJSON)); err != nil {
-			log.Fatalln("Handler error: RandomOrdersHandler:", err)
+			logger.Error("Handler error: RandomOrdersHandler", "error", err)
 		}
 	}
 }
 
+// LivenessHandler отвечает, пока процесс жив и обслуживает запросы
+func (a *App) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write([]byte(`{"status":"ok"}`)); err != nil {
+		logging.FromContext(r.Context()).Error("Handler error: LivenessHandler", "error", err)
+	}
+}
+
+// ReadinessHandler проверяет все зависимости сервиса и отвечает 503,
+// если хотя бы одна недоступна или сервис останавливается
+func (a *App) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	report := a.health.Run(r.Context())
+
+	reportJSON, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		logger.Error("Error marshalling JSON", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(reportJSON); err != nil {
+		logger.Error("Handler error: ReadinessHandler", "error", err)
+	}
+}
+
 func NewApp(d *dependencies.Dependencies) *App {
-	ctx := context.Background()
+	ctx := logging.WithLogger(context.Background(), d.Logger)
 
-	latestOrders, err := d.Repo.GetLatestOrders(ctx, c.CacheCapacity)
+	capacity := d.Config.Cache.Capacity
+	latestOrders, err := d.Repo.GetLatestOrders(ctx, capacity)
 	if err == nil {
-		d.Cache.LoadInitialOrders(ctx, latestOrders, c.CacheCapacity)
+		d.Cache.LoadInitialOrders(ctx, latestOrders, capacity)
 	} else {
-		log.Println("Cache is empty, running on redis:6379")
+		d.Logger.Warn("Cache is empty: can't get latest orders", "error", err)
 	}
 
-	go k.StartConsuming(d.KafkaConsumer, d.Repo)
+	consumerState := &k.ConsumerState{}
+	retry := k.RetryPolicy(d.Config.Kafka.Retry)
+	// consumerDone закрывается, когда консьюмер дообработал уже полученные сообщения,
+	// а stopConsumer прерывает повторы сообщений, которые не удается обработать
+	consumerCtx, stopConsumer := context.WithCancel(ctx)
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		if batch := k.BatchPolicy(d.Config.Kafka.Batch); batch.Enabled() {
+			k.StartConsumingBatches(consumerCtx, d.KafkaConsumer, d.DeadLetterQueue, d.Repo, d.Validator, retry, batch, consumerState, d.Logger)
+		} else {
+			k.StartConsuming(consumerCtx, d.KafkaConsumer, d.DeadLetterQueue, d.Repo, d.Validator, retry, d.Config.Kafka.Workers, consumerState, d.Logger)
+		}
+	}()
+	go d.Validator.Watch(ctx, d.Config.Validation.ReloadInterval)
+
+	checker := health.NewChecker(readinessTimeout)
+	checker.Add("postgres", d.Repo.Ping)
+	checker.Add("redis", d.Cache.Ping)
+	checker.Add("kafka", func(ctx context.Context) error {
+		return k.PingBrokers(ctx, d.Config.Kafka.Brokers)
+	})
+	checker.Add("consumer", consumerState.Check)
 
 	return &App{
-		kafkaConsumer: d.KafkaConsumer,
-		kafkaProducer: d.KafkaProducer,
-		repo:          d.Repo,
-		cache:         d.Cache,
+		kafkaConsumer:   d.KafkaConsumer,
+		stopConsumer:    stopConsumer,
+		consumerDone:    consumerDone,
+		kafkaProducer:   d.KafkaProducer,
+		deadLetterQueue: d.DeadLetterQueue,
+		statusProducer:  d.StatusProducer,
+		repo:            d.Repo,
+		cache:           d.Cache,
+		validator:       d.Validator,
+		messageKey:      k.MessageKey(d.Config.Kafka.MessageKey),
+		producerID:      k.ProducerID(d.Config.Kafka),
+		health:          checker,
+		logger:          d.Logger,
 	}
 }
 
+// MarkNotReady переводит /readyz в состояние неготовности перед остановкой сервиса
+func (a *App) MarkNotReady() {
+	a.health.Shutdown()
+}
+
+// Close останавливает чтение сообщений и дожидается обработки уже полученных,
+// и только потом закрывает базу, кэш и продюсеры, которые нужны обработке.
+// Сообщения, которые не удается обработать, больше не повторяются и остаются
+// незакоммиченными
 func (a App) Close() error {
-	log.Println("Closing service connections...")
+	a.logger.Info("Closing service connections...")
 	var errs []error
 
-	err := a.repo.Close()
+	if a.stopConsumer != nil {
+		a.stopConsumer()
+	}
+	err := a.kafkaConsumer.Close()
 	if err != nil {
 		errs = append(errs, err)
-		log.Println("Database connection can't be closed:", err)
+		a.logger.Error("Kafka stream can't be closed", "error", err)
+	}
+	if a.consumerDone != nil {
+		a.logger.Info("Waiting for received messages to be processed...")
+		<-a.consumerDone
 	}
 
-	err = a.cache.Close()
+	err = a.repo.Close()
 	if err != nil {
 		errs = append(errs, err)
-		log.Println("Cache connection can't be closed:", err)
+		a.logger.Error("Database connection can't be closed", "error", err)
 	}
 
-	err = a.kafkaConsumer.Close()
+	err = a.cache.Close()
 	if err != nil {
 		errs = append(errs, err)
-		log.Println("Kafka stream can't be closed:", err)
+		a.logger.Error("Cache connection can't be closed", "error", err)
 	}
 
 	err = a.kafkaProducer.Close()
 	if err != nil {
 		errs = append(errs, err)
-		log.Println("Kafka producer can't be closed:", err)
+		a.logger.Error("Kafka producer can't be closed", "error", err)
+	}
+
+	err = a.deadLetterQueue.Close()
+	if err != nil {
+		errs = append(errs, err)
+		a.logger.Error("Kafka dead-letter producer can't be closed", "error", err)
+	}
+
+	err = a.statusProducer.Close()
+	if err != nil {
+		errs = append(errs, err)
+		a.logger.Error("Kafka status producer can't be closed", "error", err)
 	}
-	log.Println("Done!")
+	a.logger.Info("Done!")
 
 	return errors.Join(errs...)
 }
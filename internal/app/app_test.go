@@ -0,0 +1,361 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"orders/internal/generator"
+	"orders/internal/health"
+	k "orders/internal/kafka"
+	"orders/internal/mocks"
+	"orders/internal/validation"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// postOrders отправляет body в CreateOrdersHandler и возвращает записанный ответ
+func postOrders(t *testing.T, a *App, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	a.CreateOrdersHandler(rec, req)
+	return rec
+}
+
+// builtinPolicies возвращает политику валидации только со встроенными правилами
+func builtinPolicies(t *testing.T) *validation.Policies {
+	policies, err := validation.NewPolicies("")
+	require.NoError(t, err)
+	return policies
+}
+
+// Тестирует прием заказов по HTTP и их отправку в Kafka
+func TestCreateOrdersHandler(t *testing.T) {
+	t.Run("Mixed batch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockProducer := mocks.NewMockMessagesProducer(ctrl)
+		a := &App{kafkaProducer: mockProducer, validator: builtinPolicies(t)}
+
+		orders := generator.MakeRandomOrder(3)
+		orders[1].TrackNumber = ""
+		body, err := json.Marshal(orders)
+		require.NoError(t, err)
+
+		// В Kafka уходят только валидные заказы, каждый своим сообщением с ключом
+		a.messageKey = k.KeyCustomerID
+		mockProducer.EXPECT().
+			WriteMessages(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+				require.Len(t, msgs, 2, "Only valid orders should be published")
+				for i, order := range []*generator.Order{orders[0], orders[2]} {
+					var published generator.Order
+					require.NoError(t, json.Unmarshal(msgs[i].Value, &published))
+					assert.Equal(t, order.OrderUID, published.OrderUID)
+					assert.Equal(t, []byte(order.CustomerID), msgs[i].Key)
+				}
+				return nil
+			}).
+			Times(1)
+
+		rec := postOrders(t, a, body)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		var response createOrdersResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []string{orders[0].OrderUID, orders[2].OrderUID}, response.Accepted)
+		require.Len(t, response.Rejected, 1)
+		assert.Equal(t, 1, response.Rejected[0].Index, "Rejection should point to the order position")
+		assert.Equal(t, "track_number: is required", response.Rejected[0].Reason)
+		// Нарушения приходят и по полям, чтобы клиент мог подсветить их у себя
+		require.NotEmpty(t, response.Rejected[0].Errors)
+		assert.Equal(t, validation.FieldError{Field: "track_number", Rule: "required", Message: "is required"}, response.Rejected[0].Errors[0])
+	})
+
+	t.Run("Single order", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockProducer := mocks.NewMockMessagesProducer(ctrl)
+		a := &App{kafkaProducer: mockProducer, validator: builtinPolicies(t)}
+
+		order := generator.MakeRandomOrder(1)[0]
+		body, err := json.Marshal(order)
+		require.NoError(t, err)
+
+		mockProducer.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		rec := postOrders(t, a, body)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Contains(t, rec.Body.String(), order.OrderUID)
+	})
+
+	t.Run("Order of the previous format", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockProducer := mocks.NewMockMessagesProducer(ctrl)
+		a := &App{kafkaProducer: mockProducer, validator: builtinPolicies(t)}
+
+		// Старые клиенты передают значение oof_shard в поле status
+		order := generator.MakeRandomOrder(1)[0]
+		order.Status = ""
+		var fields map[string]any
+		data, err := json.Marshal(order)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &fields))
+		fields["status"] = fields["oof_shard"]
+		delete(fields, "oof_shard")
+		body, err := json.Marshal(fields)
+		require.NoError(t, err)
+
+		mockProducer.EXPECT().
+			WriteMessages(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+				var published generator.Order
+				require.NoError(t, json.Unmarshal(msgs[0].Value, &published))
+				assert.Equal(t, order.OofShard, published.OofShard)
+				assert.Empty(t, published.Status, "Lifecycle status is filled by the service")
+				assert.Contains(t, string(msgs[0].Value), `"oof_shard"`)
+				return nil
+			}).
+			Times(1)
+
+		rec := postOrders(t, a, body)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+	})
+
+	t.Run("All orders rejected", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockProducer := mocks.NewMockMessagesProducer(ctrl)
+		a := &App{kafkaProducer: mockProducer, validator: builtinPolicies(t)}
+
+		// Ничего не публикуется, если принимать нечего
+		mockProducer.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Times(0)
+
+		rec := postOrders(t, a, []byte(`[null, {"order_uid": ""}]`))
+		require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+		var response createOrdersResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Empty(t, response.Accepted)
+		assert.Len(t, response.Rejected, 2)
+	})
+
+	t.Run("Malformed body", func(t *testing.T) {
+		a := &App{}
+		for _, body := range []string{"", "{", "[]", "42"} {
+			rec := postOrders(t, a, []byte(body))
+			assert.Equal(t, http.StatusBadRequest, rec.Code, "Body %q should be rejected", body)
+		}
+	})
+
+	t.Run("Kafka is unavailable", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockProducer := mocks.NewMockMessagesProducer(ctrl)
+		a := &App{kafkaProducer: mockProducer, validator: builtinPolicies(t)}
+
+		body, err := json.Marshal(generator.MakeRandomOrder(1))
+		require.NoError(t, err)
+
+		mockProducer.EXPECT().
+			WriteMessages(gomock.Any(), gomock.Any()).
+			Return(errors.New("Simulated Kafka error")).
+			Times(1)
+
+		rec := postOrders(t, a, body)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+// Тестирует ответы на запрос заказа по id
+func TestGetOrderByIdHandler(t *testing.T) {
+	getOrder := func(a *App, uid string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/orders/"+uid, nil)
+		req.SetPathValue("order_uid", uid)
+		rec := httptest.NewRecorder()
+		a.GetOrderByIdHandler(rec, req)
+		return rec
+	}
+
+	t.Run("Order found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+		a := &App{repo: mockRepo}
+
+		order := generator.MakeRandomOrder(1)[0]
+		mockRepo.EXPECT().GetOrderById(order.OrderUID, gomock.Any(), true).Return(order, nil)
+
+		rec := getOrder(a, order.OrderUID)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), order.OrderUID)
+	})
+
+	t.Run("Errors are not followed by body", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+		a := &App{repo: mockRepo}
+
+		mockRepo.EXPECT().GetOrderById("missing", gomock.Any(), true).Return(nil, sql.ErrNoRows)
+		mockRepo.EXPECT().GetOrderById("broken", gomock.Any(), true).Return(nil, errors.New("Simulated database error"))
+
+		rec := getOrder(a, "missing")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "null")
+
+		rec = getOrder(a, "broken")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "null")
+	})
+}
+
+// Тестирует разбор границ дат в фильтре списка заказов
+func TestParseOrdersFilter(t *testing.T) {
+	filter, err := parseOrdersFilter(url.Values{
+		"created_from": {"2024-05-01"},
+		"created_to":   {"2024-05-10"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), filter.CreatedFrom)
+	// Верхняя граница исключается: дата без времени сдвигается на день, чтобы заказы 10 мая попали в выборку
+	assert.Equal(t, time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC), filter.CreatedTo)
+
+	filter, err = parseOrdersFilter(url.Values{"created_to": {"2024-05-10T12:30:00Z"}})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 10, 12, 30, 0, 0, time.UTC), filter.CreatedTo)
+
+	_, err = parseOrdersFilter(url.Values{"created_to": {"10.05.2024"}})
+	assert.ErrorContains(t, err, "created_to")
+}
+
+// Тестирует ответ эндпоинта готовности
+func TestReadinessHandler(t *testing.T) {
+	getReadiness := func(a *App) (*httptest.ResponseRecorder, health.Report) {
+		rec := httptest.NewRecorder()
+		a.ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		var report health.Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec, report
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	mockCache := mocks.NewMockOrdersCache(ctrl)
+
+	checker := health.NewChecker(time.Second)
+	checker.Add("postgres", mockRepo.Ping)
+	checker.Add("redis", mockCache.Ping)
+	a := &App{repo: mockRepo, cache: mockCache, health: checker}
+
+	t.Run("Ready", func(t *testing.T) {
+		mockRepo.EXPECT().Ping(gomock.Any()).Return(nil)
+		mockCache.EXPECT().Ping(gomock.Any()).Return(nil)
+
+		rec, report := getReadiness(a)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, health.StatusOK, report.Status)
+		assert.Len(t, report.Checks, 2)
+	})
+
+	t.Run("Dependency is down", func(t *testing.T) {
+		mockRepo.EXPECT().Ping(gomock.Any()).Return(nil)
+		mockCache.EXPECT().Ping(gomock.Any()).Return(errors.New("Simulated Redis error"))
+
+		rec, report := getReadiness(a)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, health.StatusOK, report.Checks["postgres"].Status)
+		assert.Equal(t, "Simulated Redis error", report.Checks["redis"].Error)
+	})
+
+	t.Run("Shutting down", func(t *testing.T) {
+		mockRepo.EXPECT().Ping(gomock.Any()).Return(nil)
+		mockCache.EXPECT().Ping(gomock.Any()).Return(nil)
+
+		a.MarkNotReady()
+		rec, report := getReadiness(a)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, health.StatusShuttingDown, report.Status)
+	})
+}
+
+// Тестирует порядок остановки: база, кэш и продюсеры закрываются только
+// после того, как консьюмер дообработал уже полученные сообщения
+func TestClose(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+	mockProducer := mocks.NewMockMessagesProducer(ctrl)
+	mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+	mockStatus := mocks.NewMockMessagesProducer(ctrl)
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	mockCache := mocks.NewMockOrdersCache(ctrl)
+
+	// Консьюмер заканчивает обработку не сразу после закрытия ридера
+	stopped := false
+	readerClosed := make(chan struct{})
+	consumerDone := make(chan struct{})
+	var processed atomic.Bool
+	go func() {
+		defer close(consumerDone)
+		<-readerClosed
+		time.Sleep(50 * time.Millisecond)
+		processed.Store(true)
+	}()
+
+	afterProcessing := func() error {
+		assert.True(t, processed.Load(), "Dependencies should be closed after received messages are processed")
+		return nil
+	}
+	gomock.InOrder(
+		mockConsumer.EXPECT().Close().DoAndReturn(func() error {
+			// Повторы необработанных сообщений прерываются еще до закрытия ридера
+			assert.True(t, stopped, "Consumer retries should be stopped first")
+			close(readerClosed)
+			return nil
+		}),
+		mockRepo.EXPECT().Close().DoAndReturn(afterProcessing),
+		mockCache.EXPECT().Close().DoAndReturn(afterProcessing),
+		mockProducer.EXPECT().Close().DoAndReturn(afterProcessing),
+		mockDLQ.EXPECT().Close().DoAndReturn(afterProcessing),
+		mockStatus.EXPECT().Close().DoAndReturn(afterProcessing),
+	)
+
+	a := App{
+		kafkaConsumer:   mockConsumer,
+		stopConsumer:    func() { stopped = true },
+		consumerDone:    consumerDone,
+		kafkaProducer:   mockProducer,
+		deadLetterQueue: mockDLQ,
+		statusProducer:  mockStatus,
+		repo:            mockRepo,
+		cache:           mockCache,
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	require.NoError(t, a.Close())
+}
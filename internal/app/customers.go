@@ -0,0 +1,69 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"orders/internal/generator"
+	"orders/internal/logging"
+	"orders/internal/repository"
+)
+
+// customerOrdersResponse - сводка по заказам покупателя и страница его заказов
+type customerOrdersResponse struct {
+	CustomerID string                      `json:"customer_id"`
+	Summary    *repository.CustomerSummary `json:"summary"`
+	Orders     []*generator.Order          `json:"orders"`
+	NextCursor string                      `json:"next_cursor,omitempty"`
+}
+
+func (a *App) CustomerOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	customerID := r.PathValue("customer_id")
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	// Принимаем те же параметры, что и список всех заказов, но покупатель берется из пути
+	filter, err := parseOrdersFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.CustomerID = customerID
+
+	summary, err := a.repo.GetCustomerSummary(ctx, customerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Customer not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	ordersPage, err := a.repo.ListOrders(ctx, filter)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := customerOrdersResponse{
+		CustomerID: customerID,
+		Summary:    summary,
+		Orders:     ordersPage.Orders,
+		NextCursor: ordersPage.NextCursor,
+	}
+
+	responseJSON, err := json.MarshalIndent(response, "", "    ")
+	if err != nil {
+		logger.Error("Error marshalling JSON", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(responseJSON); err != nil {
+		logger.Error("Handler error: CustomerOrdersHandler", "error", err)
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"orders/internal/generator"
+	"orders/internal/mocks"
+	"orders/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// Тестирует выдачу заказов покупателя со сводкой
+func TestCustomerOrdersHandler(t *testing.T) {
+	getCustomerOrders := func(a *App, customerID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/customers/"+customerID+"/orders"+query, nil)
+		req.SetPathValue("customer_id", customerID)
+		rec := httptest.NewRecorder()
+		a.CustomerOrdersHandler(rec, req)
+		return rec
+	}
+
+	t.Run("Customer found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+		a := &App{repo: mockRepo}
+
+		orders := generator.MakeRandomOrder(2)
+		summary := &repository.CustomerSummary{OrdersCount: 3, TotalSpent: map[string]int64{"RUB": 300}}
+		mockRepo.EXPECT().GetCustomerSummary(gomock.Any(), "customer-1").Return(summary, nil)
+		// Покупатель из пути перекрывает параметр запроса
+		mockRepo.EXPECT().
+			ListOrders(gomock.Any(), repository.OrdersFilter{CustomerID: "customer-1", Limit: 2}).
+			Return(&repository.OrdersPage{Orders: orders, NextCursor: "next"}, nil)
+
+		rec := getCustomerOrders(a, "customer-1", "?limit=2&customer_id=other")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response customerOrdersResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "customer-1", response.CustomerID)
+		assert.Equal(t, int64(3), response.Summary.OrdersCount)
+		assert.Len(t, response.Orders, 2)
+		assert.Equal(t, "next", response.NextCursor)
+	})
+
+	t.Run("Unknown customer", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+		a := &App{repo: mockRepo}
+
+		mockRepo.EXPECT().GetCustomerSummary(gomock.Any(), "nobody").Return(nil, sql.ErrNoRows)
+		mockRepo.EXPECT().ListOrders(gomock.Any(), gomock.Any()).Times(0)
+
+		rec := getCustomerOrders(a, "nobody", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		rec := getCustomerOrders(&App{}, "customer-1", "?limit=0")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
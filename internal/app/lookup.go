@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"orders/internal/generator"
+	"orders/internal/logging"
+)
+
+func (a *App) GetOrdersByTrackHandler(w http.ResponseWriter, r *http.Request) {
+	a.lookupOrders(w, r, r.PathValue("track_number"), a.repo.GetOrdersByTrackNumber)
+}
+
+func (a *App) GetOrdersByTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	a.lookupOrders(w, r, r.PathValue("transaction"), a.repo.GetOrdersByTransaction)
+}
+
+// lookupOrders ищет заказы по значению key функцией find и отдает их списком
+func (a *App) lookupOrders(w http.ResponseWriter, r *http.Request, key string,
+	find func(ctx context.Context, key string) ([]*generator.Order, error)) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	orders, err := find(ctx, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Order not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	ordersJSON, err := json.MarshalIndent(orders, "", "    ")
+	if err != nil {
+		logger.Error("Error marshalling JSON", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(ordersJSON); err != nil {
+		logger.Error("Handler error: lookupOrders", "error", err)
+	}
+}
+
+// OrderSubpathHandler разбирает пути вида /orders/{first}/{second}. Шаблоны
+// GET /orders/by-track/{track_number} и GET /orders/{order_uid}/status пересекаются,
+// и ServeMux отказывается регистрировать их вместе, поэтому они обслуживаются
+// одним шаблоном GET /orders/{order_uid}/{resource}, а выбор делается здесь.
+// Обработчики получают значения пути под своими именами
+func OrderSubpathHandler(byTrack, byTransaction, orderStatus, notFound http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first, second := r.PathValue("order_uid"), r.PathValue("resource")
+
+		switch {
+		case first == "by-track":
+			r.SetPathValue("track_number", second)
+			byTrack.ServeHTTP(w, r)
+		case first == "by-transaction":
+			r.SetPathValue("transaction", second)
+			byTransaction.ServeHTTP(w, r)
+		case second == "status":
+			orderStatus.ServeHTTP(w, r)
+		default:
+			notFound.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"orders/internal/generator"
+	"orders/internal/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// Тестирует поиск заказов по трек-номеру и транзакции оплаты
+func TestLookupHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	a := &App{repo: mockRepo}
+
+	orders := generator.MakeRandomOrder(2)
+	mockRepo.EXPECT().GetOrdersByTrackNumber(gomock.Any(), "TRACK").Return(orders, nil)
+	mockRepo.EXPECT().GetOrdersByTrackNumber(gomock.Any(), "MISSING").Return(nil, sql.ErrNoRows)
+	mockRepo.EXPECT().GetOrdersByTransaction(gomock.Any(), "TX").Return(orders[:1], nil)
+	mockRepo.EXPECT().GetOrdersByTransaction(gomock.Any(), "BROKEN").Return(nil, errors.New("Simulated database error"))
+
+	cases := []struct {
+		name, key string
+		handler   http.HandlerFunc
+		pathValue string
+		code      int
+		found     int
+	}{
+		{"By track", "TRACK", a.GetOrdersByTrackHandler, "track_number", http.StatusOK, 2},
+		{"Unknown track", "MISSING", a.GetOrdersByTrackHandler, "track_number", http.StatusNotFound, 0},
+		{"By transaction", "TX", a.GetOrdersByTransactionHandler, "transaction", http.StatusOK, 1},
+		{"Database error", "BROKEN", a.GetOrdersByTransactionHandler, "transaction", http.StatusInternalServerError, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders/lookup/"+tc.key, nil)
+			req.SetPathValue(tc.pathValue, tc.key)
+			rec := httptest.NewRecorder()
+			tc.handler(rec, req)
+			require.Equal(t, tc.code, rec.Code, rec.Body.String())
+
+			if tc.code == http.StatusOK {
+				var response []*generator.Order
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Len(t, response, tc.found)
+			}
+		})
+	}
+}
+
+// Тестирует выбор обработчика для путей /orders/{first}/{second}
+func TestOrderSubpathHandler(t *testing.T) {
+	// Каждый обработчик пишет в ответ свое имя и полученное значение пути
+	named := func(name, pathValue string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + ":" + r.PathValue(pathValue)))
+		})
+	}
+
+	// Шаблоны повторяют регистрацию в main: ServeMux паникует при их конфликте
+	mux := http.NewServeMux()
+	mux.Handle("/orders/{order_uid}", named("order", "order_uid"))
+	mux.Handle("PATCH /orders/{order_uid}/status", named("patch", "order_uid"))
+	mux.Handle("GET /orders/{order_uid}/{resource}", OrderSubpathHandler(
+		named("track", "track_number"),
+		named("transaction", "transaction"),
+		named("status", "order_uid"),
+		named("not found", "resource"),
+	))
+
+	cases := []struct {
+		method, path, expected string
+	}{
+		{http.MethodGet, "/orders/by-track/WBILMTESTTRACK", "track:WBILMTESTTRACK"},
+		{http.MethodGet, "/orders/by-transaction/BE430C44M63VOZ", "transaction:BE430C44M63VOZ"},
+		{http.MethodGet, "/orders/order-1/status", "status:order-1"},
+		{http.MethodPatch, "/orders/order-1/status", "patch:order-1"},
+		{http.MethodGet, "/orders/order-1", "order:order-1"},
+		{http.MethodGet, "/orders/order-1/items", "not found:items"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(t, tc.expected, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"orders/internal/logging"
+	"orders/internal/metrics"
+	"orders/internal/status"
+
+	k "orders/internal/kafka"
+)
+
+// Максимальный размер тела запроса на смену статуса
+const maxUpdateStatusBody = 1 << 10
+
+type updateStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// statusResponse - текущий статус заказа, доступные переходы и история смены статусов
+type statusResponse struct {
+	OrderUID string          `json:"order_uid"`
+	Status   status.Status   `json:"status"`
+	Next     []status.Status `json:"next"`
+	History  []status.Change `json:"history"`
+}
+
+func (a *App) UpdateStatusHandler(w http.ResponseWriter, r *http.Request) {
+	orderUID := r.PathValue("order_uid")
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	var request updateStatusRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUpdateStatusBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Bad request: invalid status update: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := status.Parse(request.Status)
+	if err != nil {
+		http.Error(w, "Bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	change, err := a.repo.UpdateStatus(ctx, orderUID, to, request.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "Order not found", http.StatusNotFound)
+		case errors.Is(err, status.ErrInvalidTransition):
+			http.Error(w, "Conflict: "+err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	metrics.StatusChanges.WithLabelValues(string(change.To)).Inc()
+	logger.Info("Order status changed", "order_uid", orderUID, "from", change.From, "to", change.To)
+
+	// Переход уже зафиксирован в бд, поэтому ошибка публикации
+	// события не отменяет его, а только логируется и учитывается в метриках
+	err = k.PublishStatusChange(a.statusProducer, ctx, change, a.producerID)
+	if err != nil {
+		metrics.StatusEventsFailed.Inc()
+	}
+
+	changeJSON, err := json.MarshalIndent(change, "", "    ")
+	if err != nil {
+		logger.Error("Error marshalling JSON", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(changeJSON); err != nil {
+		logger.Error("Handler error: UpdateStatusHandler", "error", err)
+	}
+}
+
+func (a *App) GetStatusHandler(w http.ResponseWriter, r *http.Request) {
+	orderUID := r.PathValue("order_uid")
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	order, err := a.repo.GetOrderById(orderUID, ctx, true)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Order not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	history, err := a.repo.GetStatusHistory(ctx, orderUID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	current := status.Status(order.Status)
+	response := statusResponse{
+		OrderUID: orderUID,
+		Status:   current,
+		Next:     current.Next(),
+		History:  history,
+	}
+
+	responseJSON, err := json.MarshalIndent(response, "", "    ")
+	if err != nil {
+		logger.Error("Error marshalling JSON", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(responseJSON); err != nil {
+		logger.Error("Handler error: GetStatusHandler", "error", err)
+	}
+}
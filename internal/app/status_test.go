@@ -0,0 +1,143 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"orders/internal/generator"
+	"orders/internal/mocks"
+	"orders/internal/status"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// patchStatus отправляет body в UpdateStatusHandler и возвращает записанный ответ
+func patchStatus(a *App, uid, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/orders/"+uid+"/status", strings.NewReader(body))
+	req.SetPathValue("order_uid", uid)
+	rec := httptest.NewRecorder()
+	a.UpdateStatusHandler(rec, req)
+	return rec
+}
+
+// Тестирует смену статуса заказа по HTTP
+func TestUpdateStatusHandler(t *testing.T) {
+	t.Run("Allowed transition", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+		mockProducer := mocks.NewMockMessagesProducer(ctrl)
+		a := &App{repo: mockRepo, statusProducer: mockProducer}
+
+		change := &status.Change{OrderUID: "order-1", From: status.Created, To: status.Paid, Reason: "paid", ChangedAt: time.Now().UTC()}
+		mockRepo.EXPECT().UpdateStatus(gomock.Any(), "order-1", status.Paid, "paid").Return(change, nil)
+		// О переходе сообщается в Kafka
+		mockProducer.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		rec := patchStatus(a, "order-1", `{"status": "paid", "reason": "paid"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response status.Change
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, status.Created, response.From)
+		assert.Equal(t, status.Paid, response.To)
+	})
+
+	t.Run("Event is not published", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+		mockProducer := mocks.NewMockMessagesProducer(ctrl)
+		a := &App{repo: mockRepo, statusProducer: mockProducer}
+
+		change := &status.Change{OrderUID: "order-1", From: status.Created, To: status.Cancelled}
+		mockRepo.EXPECT().UpdateStatus(gomock.Any(), "order-1", status.Cancelled, "").Return(change, nil)
+		mockProducer.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(errors.New("Simulated Kafka error"))
+
+		// Переход уже сохранен, поэтому клиент получает успешный ответ
+		rec := patchStatus(a, "order-1", `{"status": "cancelled"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+		a := &App{repo: mockRepo}
+
+		mockRepo.EXPECT().UpdateStatus(gomock.Any(), "missing", status.Paid, "").Return(nil, sql.ErrNoRows)
+		mockRepo.EXPECT().UpdateStatus(gomock.Any(), "shipped", status.Paid, "").
+			Return(nil, fmt.Errorf("%w: shipped -> paid", status.ErrInvalidTransition))
+		mockRepo.EXPECT().UpdateStatus(gomock.Any(), "broken", status.Paid, "").Return(nil, errors.New("Simulated database error"))
+
+		cases := []struct {
+			name, uid, body string
+			code            int
+		}{
+			{"Malformed body", "order-1", `{"status":`, http.StatusBadRequest},
+			{"Unknown field", "order-1", `{"status": "paid", "comment": "x"}`, http.StatusBadRequest},
+			{"Unknown status", "order-1", `{"status": "lost"}`, http.StatusBadRequest},
+			{"Empty body", "order-1", ``, http.StatusBadRequest},
+			{"Missing order", "missing", `{"status": "paid"}`, http.StatusNotFound},
+			{"Invalid transition", "shipped", `{"status": "paid"}`, http.StatusConflict},
+			{"Database error", "broken", `{"status": "paid"}`, http.StatusInternalServerError},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				rec := patchStatus(a, tc.uid, tc.body)
+				assert.Equal(t, tc.code, rec.Code, rec.Body.String())
+			})
+		}
+	})
+}
+
+// Тестирует ответ с текущим статусом и историей заказа
+func TestGetStatusHandler(t *testing.T) {
+	getStatus := func(a *App, uid string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/orders/"+uid+"/status", nil)
+		req.SetPathValue("order_uid", uid)
+		rec := httptest.NewRecorder()
+		a.GetStatusHandler(rec, req)
+		return rec
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+	a := &App{repo: mockRepo}
+
+	order := generator.MakeRandomOrder(1)[0]
+	order.Status = string(status.Paid)
+	history := []status.Change{
+		{OrderUID: order.OrderUID, To: status.Created},
+		{OrderUID: order.OrderUID, From: status.Created, To: status.Paid},
+	}
+	mockRepo.EXPECT().GetOrderById(order.OrderUID, gomock.Any(), true).Return(order, nil)
+	mockRepo.EXPECT().GetStatusHistory(gomock.Any(), order.OrderUID).Return(history, nil)
+	mockRepo.EXPECT().GetOrderById("missing", gomock.Any(), true).Return(nil, sql.ErrNoRows)
+
+	rec := getStatus(a, order.OrderUID)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response statusResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, status.Paid, response.Status)
+	assert.Equal(t, []status.Status{status.Assembling, status.Cancelled}, response.Next)
+	assert.Len(t, response.History, 2)
+
+	rec = getStatus(a, "missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
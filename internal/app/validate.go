@@ -0,0 +1,104 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"orders/internal/logging"
+	"orders/internal/validation"
+)
+
+// validateOrdersResponse - итог пробной проверки заказов действующей политикой
+type validateOrdersResponse struct {
+	Policy  policyInfo         `json:"policy"`
+	Valid   int                `json:"valid"`
+	Invalid int                `json:"invalid"`
+	Results []validationResult `json:"results"`
+}
+
+// policyInfo - файл действующей политики, builtin - только встроенные правила
+type policyInfo struct {
+	Source   string    `json:"source"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+type validationResult struct {
+	Index    int    `json:"index"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Entry    string `json:"entry,omitempty"`
+	// Ruleset - набор правил политики, выбранный по entry заказа
+	Ruleset string                  `json:"ruleset,omitempty"`
+	Valid   bool                    `json:"valid"`
+	Reason  string                  `json:"reason,omitempty"`
+	Errors  []validation.FieldError `json:"errors,omitempty"`
+}
+
+// ValidateOrdersHandler проверяет заказы так же, как POST /orders, но ничего
+// не отправляет в Kafka и не учитывает отказы в метриках
+func (a *App) ValidateOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCreateOrdersBody))
+	if err != nil {
+		http.Error(w, "Bad request: can't read request body", http.StatusBadRequest)
+		return
+	}
+
+	orders, err := decodeOrders(body)
+	if err != nil {
+		http.Error(w, "Bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := validateOrdersResponse{
+		Policy:  policyInfo{Source: "builtin", LoadedAt: a.validator.LoadedAt()},
+		Results: make([]validationResult, 0, len(orders)),
+	}
+	if source := a.validator.Source(); source != "" {
+		response.Policy.Source = filepath.Base(source)
+	}
+
+	for i, order := range orders {
+		result := validationResult{Index: i}
+		if order == nil {
+			result.Reason = "empty order"
+			response.Results = append(response.Results, result)
+			response.Invalid++
+			continue
+		}
+
+		result.OrderUID = order.OrderUID
+		result.Entry = order.Entry
+		result.Ruleset = a.validator.Ruleset(order.Entry)
+
+		if err := a.validator.Validate(order); err != nil {
+			result.Reason = err.Error()
+			// Нарушенные правила есть только у ошибок валидации, остальные - только с причиной
+			var errs validation.Errors
+			if errors.As(err, &errs) {
+				result.Errors = errs
+			}
+			response.Invalid++
+		} else {
+			result.Valid = true
+			response.Valid++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	responseJSON, err := json.MarshalIndent(response, "", "    ")
+	if err != nil {
+		logger.Error("Error marshalling JSON", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(responseJSON); err != nil {
+		logger.Error("Handler error: ValidateOrdersHandler", "error", err)
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"orders/internal/generator"
+	"orders/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирует пробную проверку заказов действующей политикой
+func TestValidateOrdersHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("entries:\n  WBIL_1:\n    enums:\n      payment.bank: [Sber]"), 0o644))
+	policies, err := validation.NewPolicies(path)
+	require.NoError(t, err)
+
+	// Без Kafka: пробная проверка ничего не публикует
+	a := &App{validator: policies}
+
+	validate := func(body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orders/validate", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		a.ValidateOrdersHandler(rec, req)
+		return rec
+	}
+
+	t.Run("Every failure is explained", func(t *testing.T) {
+		orders := generator.MakeRandomOrder(2)
+		orders[0].Entry = "WBIL"
+		orders[1].Entry = "WBIL_1"
+		orders[1].Payment.Bank = "Alpha"
+		orders[1].Payment.Currency = "XYZ"
+		body, err := json.Marshal(append(orders, nil))
+		require.NoError(t, err)
+
+		rec := validate(body)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response validateOrdersResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "policy.yaml", response.Policy.Source)
+		assert.Equal(t, 1, response.Valid)
+		assert.Equal(t, 2, response.Invalid)
+		require.Len(t, response.Results, 3)
+
+		assert.True(t, response.Results[0].Valid)
+		assert.Equal(t, validation.DefaultRuleset, response.Results[0].Ruleset)
+
+		// Набор правил выбран по entry, а отказ объяснен по каждому полю
+		invalid := response.Results[1]
+		assert.False(t, invalid.Valid)
+		assert.Equal(t, orders[1].OrderUID, invalid.OrderUID)
+		assert.Equal(t, "WBIL_1", invalid.Ruleset)
+		require.Len(t, invalid.Errors, 2)
+		assert.Equal(t, "payment.currency", invalid.Errors[0].Field)
+		assert.Equal(t, "currency", invalid.Errors[0].Rule)
+		assert.Equal(t, "payment.bank", invalid.Errors[1].Field)
+		assert.Equal(t, "policy_enum", invalid.Errors[1].Rule)
+		assert.NotEmpty(t, invalid.Reason)
+
+		assert.Equal(t, 2, response.Results[2].Index)
+		assert.Equal(t, "empty order", response.Results[2].Reason)
+	})
+
+	t.Run("Built-in rules only", func(t *testing.T) {
+		a := &App{validator: builtinPolicies(t)}
+		body, err := json.Marshal(generator.MakeRandomOrder(1)[0])
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/orders/validate", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		a.ValidateOrdersHandler(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response validateOrdersResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "builtin", response.Policy.Source)
+		assert.Equal(t, 1, response.Valid)
+	})
+
+	t.Run("Malformed body", func(t *testing.T) {
+		for _, body := range []string{"", "{", "[]"} {
+			assert.Equal(t, http.StatusBadRequest, validate([]byte(body)).Code, "Body %q should be rejected", body)
+		}
+	})
+}
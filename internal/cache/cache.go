@@ -3,10 +3,14 @@ package cache
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"time"
 
+	"orders/internal/config"
 	g "orders/internal/generator"
+	"orders/internal/logging"
+	"orders/internal/metrics"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -14,147 +18,294 @@ import (
 type Cache struct {
 	redisClient *redis.Client
 	Capacity    int32
+	// TTL - время жизни заказа без обращений к нему, 0 - без ограничения
+	TTL time.Duration
+	// LRUKey - ключ ZSET, в котором заказы ранжируются по времени последнего обращения
+	LRUKey string
+	// KeyPrefix отделяет ключи заказов от остальных ключей в Redis
+	KeyPrefix string
 }
 
+// CacheCapacity - вместимость кэша по умолчанию
 const CacheCapacity int32 = 200
 
-func NewCache(redisURL string) (*Cache, error) {
+func NewCache(redisURL string, cfg config.Cache) (*Cache, error) {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
-		log.Fatalln("Error connecting to redis:", err)
+		slog.Error("Error parsing redis URL", "error", err)
+		return nil, err
 	}
 
 	rdb := redis.NewClient(opt)
-	return &Cache{redisClient: rdb, Capacity: CacheCapacity}, nil
+	return &Cache{
+		redisClient: rdb,
+		Capacity:    cfg.Capacity,
+		TTL:         cfg.TTL,
+		LRUKey:      cfg.LRUKey,
+		KeyPrefix:   cfg.KeyPrefix,
+	}, nil
 }
 
+// LoadInitialOrders заполняет кэш не более чем limit заказами из latestOrders,
+// отсортированных от новых к старым
 func (c *Cache) LoadInitialOrders(ctx context.Context, latestOrders []*g.Order, limit int32) {
+	if limit >= 0 && int(limit) < len(latestOrders) {
+		latestOrders = latestOrders[:limit]
+	}
+
 	var successfulOrders int
 
-	for _, order := range latestOrders {
+	// Добавляем с конца, чтобы самые новые заказы вытеснялись последними
+	for i := len(latestOrders) - 1; i >= 0; i-- {
+		order := latestOrders[i]
 		orderJSON, err := json.Marshal(order)
 		if err != nil {
-			log.Println("Error marshalling order to JSON:", err)
+			logging.FromContext(ctx).Error("Error marshalling order to JSON", "error", err)
 			continue
 		}
 
-		redisKey := order.OrderUID
-		err = c.addToCache(ctx, redisKey, orderJSON)
+		err = c.store(ctx, order.OrderUID, orderJSON)
 		if err != nil {
-			log.Printf("Error adding order with uid %s\n", redisKey)
-			continue
-		}
-
-		err = c.updateLRU(ctx, redisKey)
-		if err != nil {
-			log.Printf("Error updating LRU with order with uid %s\n", redisKey)
 			continue
 		}
 
 		successfulOrders++
 	}
-	log.Printf("Cache filled with %d/%d orders, running on redis:6379\n", successfulOrders, CacheCapacity)
+	logging.FromContext(ctx).Info("Cache filled", "orders", successfulOrders, "capacity", c.Capacity)
 }
 
-func (c *Cache) addToCache(ctx context.Context, redisKey string, orderJSON []byte) error {
-	err := c.redisClient.Set(ctx, redisKey, orderJSON, 0).Err()
+// Скрипты ниже выполняются в Redis атомарно, поэтому параллельные записи
+// не превышают вместимость и не оставляют заказов без записи в ZSET.
+// Время берется с сервера Redis, чтобы порядок не зависел от часов
+// экземпляров сервиса. Счет в ZSET - время последнего обращения в микросекундах.
+// При TTL обращение продлевает жизнь заказа, так что заказы со счетом старше
+// TTL уже истекли в Redis и убираются из ZSET без DEL
+
+// addScript сохраняет заказ, отмечает обращение к нему и вытесняет самые
+// давние заказы сверх вместимости.
+// KEYS[1] - ZSET, KEYS[2] - ключ заказа, ARGV[1] - JSON заказа,
+// ARGV[2] - вместимость, ARGV[3] - TTL в миллисекундах или 0.
+// Возвращает число вытесненных заказов
+var addScript = redis.NewScript(`
+local now = redis.call('TIME')
+local score = now[1] * 1000000 + now[2]
+local ttl = tonumber(ARGV[3])
+
+if ttl > 0 then
+	redis.call('SET', KEYS[2], ARGV[1], 'PX', ttl)
+	redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', string.format('(%d', score - ttl * 1000))
+else
+	redis.call('SET', KEYS[2], ARGV[1])
+end
+redis.call('ZADD', KEYS[1], string.format('%d', score), KEYS[2])
+
+local excess = redis.call('ZCARD', KEYS[1]) - tonumber(ARGV[2])
+if excess <= 0 then
+	return 0
+end
+
+local evicted = redis.call('ZPOPMIN', KEYS[1], excess)
+for i = 1, #evicted, 2 do
+	redis.call('DEL', evicted[i])
+end
+return excess
+`)
+
+// getScript возвращает заказ и отмечает обращение к нему. Если заказ
+// уже истек или удален, убирает его из ZSET и возвращает nil.
+// KEYS[1] - ZSET, KEYS[2] - ключ заказа, ARGV[1] - TTL в миллисекундах или 0
+var getScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[2])
+if not value then
+	redis.call('ZREM', KEYS[1], KEYS[2])
+	return false
+end
+
+local now = redis.call('TIME')
+redis.call('ZADD', KEYS[1], string.format('%d', now[1] * 1000000 + now[2]), KEYS[2])
+
+local ttl = tonumber(ARGV[1])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return value
+`)
+
+// removeScript удаляет заказ и его запись в ZSET.
+// KEYS[1] - ZSET, KEYS[2] - ключ заказа
+var removeScript = redis.NewScript(`
+redis.call('ZREM', KEYS[1], KEYS[2])
+return redis.call('DEL', KEYS[2])
+`)
+
+// flushScript удаляет все заказы из ZSET и сам ZSET.
+// KEYS[1] - ZSET. Возвращает число удаленных заказов
+var flushScript = redis.NewScript(`
+local keys = redis.call('ZRANGE', KEYS[1], 0, -1)
+for i = 1, #keys do
+	redis.call('DEL', keys[i])
+end
+redis.call('DEL', KEYS[1])
+return #keys
+`)
+
+// formatVersion - версия JSON заказа в кэше, часть ключа после KeyPrefix.
+// Меняется при несовместимом изменении формата: в v1 значение oof_shard
+// хранилось в поле status. Записи прежнего формата не читаются, а остаются
+// в ZSET самыми давними и вытесняются первыми
+const formatVersion = "v2:"
+
+// key возвращает ключ Redis, под которым хранится заказ
+func (c *Cache) key(uid string) string {
+	return c.KeyPrefix + formatVersion + uid
+}
+
+// store сохраняет заказ в кэш за одно обращение к Redis
+func (c *Cache) store(ctx context.Context, uid string, orderJSON []byte) error {
+	keys := []string{c.LRUKey, c.key(uid)}
+	evicted, err := addScript.Run(ctx, c.redisClient, keys, orderJSON, c.Capacity, c.TTL.Milliseconds()).Int()
 	if err != nil {
-		log.Println("Error adding order to Redis:", err)
+		logging.FromContext(ctx).Error("Error adding order to cache", "order_uid", uid, "error", err)
 		return err
 	}
+	metrics.CacheEvictions.Add(float64(evicted))
 	return nil
 }
 
-func (c *Cache) updateLRU(ctx context.Context, uid string) error {
-	zKey := "LRU-orders"
+func (c *Cache) GetFromCache(ctx context.Context, uid string) (*g.Order, error) {
+	keys := []string{c.LRUKey, c.key(uid)}
+	orderJSON, err := getScript.Run(ctx, c.redisClient, keys, c.TTL.Milliseconds()).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			metrics.CacheLookups.WithLabelValues(metrics.ResultMiss).Inc()
+		} else {
+			metrics.CacheLookups.WithLabelValues(metrics.ResultError).Inc()
+		}
+		logging.FromContext(ctx).Debug("Can't find cached data", "order_uid", uid, "error", err)
+		return nil, err
+	}
 
-	now := float64(time.Now().UnixMilli())
-	err := c.redisClient.ZAdd(ctx, zKey, redis.Z{
-		Member: uid,
-		Score:  now,
-	}).Err()
+	var order g.Order
+	err = json.Unmarshal([]byte(orderJSON), &order)
 	if err != nil {
-		log.Println("Error adding to ZSET:")
-		return err
+		metrics.CacheLookups.WithLabelValues(metrics.ResultError).Inc()
+		logging.FromContext(ctx).Error("Error unmarshalling cached data", "order_uid", uid, "error", err)
+		return nil, err
 	}
+	metrics.CacheLookups.WithLabelValues(metrics.ResultHit).Inc()
+	return &order, nil
+}
 
-	currentCapacity, err := c.redisClient.ZCard(ctx, zKey).Result()
+func (c *Cache) UpdateCache(ctx context.Context, order *g.Order) error {
+	orderJSON, err := json.Marshal(order)
 	if err != nil {
-		log.Println("Error getting cache capacity:")
+		logging.FromContext(ctx).Error("Error marshalling order before adding to cache", "error", err)
 		return err
 	}
+	return c.store(ctx, order.OrderUID, orderJSON)
+}
 
-	for currentCapacity > int64(CacheCapacity) {
-		members, err := c.redisClient.ZPopMin(ctx, zKey, 1).Result()
+// UpdateCacheBatch сохраняет заказы в кэш за одно обращение к Redis:
+// скрипт добавления выполняется для каждого заказа в одном пайплайне
+func (c *Cache) UpdateCacheBatch(ctx context.Context, orders []*g.Order) error {
+	values := make([][]byte, len(orders))
+	for i, order := range orders {
+		orderJSON, err := json.Marshal(order)
 		if err != nil {
-			log.Println("Error removing order with lowest score:", err)
+			logging.FromContext(ctx).Error("Error marshalling order before adding to cache", "order_uid", order.OrderUID, "error", err)
 			return err
 		}
-		currentCapacity--
+		values[i] = orderJSON
+	}
 
-		lowestScoreUid := members[0].Member.(string)
-		err = c.removeFromCache(ctx, lowestScoreUid)
-		if err != nil {
-			return err
+	cmds, err := c.storeBatch(ctx, orders, values)
+	// Пайплайн не загружает скрипт сам, как Script.Run, поэтому после
+	// перезапуска Redis загружаем его и повторяем пайплайн
+	if redis.HasErrorPrefix(err, "NOSCRIPT") {
+		if err = addScript.Load(ctx, c.redisClient).Err(); err == nil {
+			cmds, err = c.storeBatch(ctx, orders, values)
 		}
 	}
-	return nil
-}
-
-func (c *Cache) removeFromCache(ctx context.Context, uid string) error {
-	err := c.redisClient.Del(ctx, uid).Err()
 	if err != nil {
-		log.Printf("Error removing order with uid %s from cache: %d\n", uid, err)
+		logging.FromContext(ctx).Error("Error adding orders to cache", "orders", len(orders), "error", err)
 		return err
 	}
+
+	for _, cmd := range cmds {
+		evicted, _ := cmd.Int()
+		metrics.CacheEvictions.Add(float64(evicted))
+	}
 	return nil
 }
 
-func (c *Cache) GetFromCache(ctx context.Context, uid string) (*g.Order, error) {
-	cmd := c.redisClient.Get(ctx, uid)
-	if cmd.Err() != nil {
-		log.Println("Can't find cached data for", uid)
-		return nil, cmd.Err()
+func (c *Cache) storeBatch(ctx context.Context, orders []*g.Order, values [][]byte) ([]*redis.Cmd, error) {
+	pipe := c.redisClient.Pipeline()
+	cmds := make([]*redis.Cmd, len(orders))
+	for i, order := range orders {
+		keys := []string{c.LRUKey, c.key(order.OrderUID)}
+		cmds[i] = addScript.EvalSha(ctx, pipe, keys, values[i], c.Capacity, c.TTL.Milliseconds())
 	}
+	_, err := pipe.Exec(ctx)
+	return cmds, err
+}
 
-	orderJSON, err := cmd.Bytes()
+// Invalidate удаляет заказ из кэша, чтобы следующее чтение взяло его из бд
+func (c *Cache) Invalidate(ctx context.Context, uid string) error {
+	keys := []string{c.LRUKey, c.key(uid)}
+	err := removeScript.Run(ctx, c.redisClient, keys).Err()
 	if err != nil {
-		log.Println("Error marshalling cached data for", uid)
-		return nil, err
+		logging.FromContext(ctx).Error("Error removing order from cache", "order_uid", uid, "error", err)
+		return err
 	}
+	return nil
+}
 
-	var order g.Order
-	err = json.Unmarshal(orderJSON, &order)
-	if err != nil {
-		log.Println("Error marshalling cached data for", uid)
-		return nil, err
-	}
+// Stats - состояние кэша в Redis. Время обращений пустое, если кэш пуст
+type Stats struct {
+	Orders       int64
+	Capacity     int32
+	TTL          time.Duration
+	OldestAccess time.Time
+	NewestAccess time.Time
+}
 
-	err = c.updateLRU(ctx, uid)
-	if err != nil {
+// Stats возвращает число закэшированных заказов и время самого давнего
+// и самого недавнего обращения к ним
+func (c *Cache) Stats(ctx context.Context) (*Stats, error) {
+	stats := &Stats{Capacity: c.Capacity, TTL: c.TTL}
+
+	pipe := c.redisClient.TxPipeline()
+	count := pipe.ZCard(ctx, c.LRUKey)
+	oldest := pipe.ZRangeWithScores(ctx, c.LRUKey, 0, 0)
+	newest := pipe.ZRangeWithScores(ctx, c.LRUKey, -1, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
 		return nil, err
 	}
-	return &order, nil
-}
 
-func (c *Cache) UpdateCache(ctx context.Context, order *g.Order) error {
-	orderJSON, err := json.Marshal(order)
-	if err != nil {
-		log.Println("Error marshalling order before adding to cache:", err)
-		return err
+	stats.Orders = count.Val()
+	// Счет в ZSET - время обращения в микросекундах
+	if z := oldest.Val(); len(z) > 0 {
+		stats.OldestAccess = time.UnixMicro(int64(z[0].Score))
 	}
-
-	redisKey := order.OrderUID
-	err = c.addToCache(ctx, redisKey, orderJSON)
-	if err != nil {
-		return err
+	if z := newest.Val(); len(z) > 0 {
+		stats.NewestAccess = time.UnixMicro(int64(z[0].Score))
 	}
+	return stats, nil
+}
 
-	err = c.updateLRU(ctx, redisKey)
+// Flush удаляет из Redis все закэшированные заказы, не трогая остальные
+// ключи. Возвращает число удаленных заказов
+func (c *Cache) Flush(ctx context.Context) (int, error) {
+	removed, err := flushScript.Run(ctx, c.redisClient, []string{c.LRUKey}).Int()
 	if err != nil {
-		return err
+		logging.FromContext(ctx).Error("Error flushing cache", "error", err)
+		return 0, err
 	}
-	return nil
+	return removed, nil
+}
+
+func (c *Cache) Ping(ctx context.Context) error {
+	return c.redisClient.Ping(ctx).Err()
 }
 
 func (c *Cache) Close() error {
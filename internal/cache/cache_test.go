@@ -2,18 +2,31 @@ package cache
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
+	"orders/internal/config"
 	"orders/internal/generator"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// testConfig возвращает настройки кэша для тестов с вместимостью capacity
+func testConfig(capacity int32) config.Cache {
+	return config.Cache{
+		Capacity:  capacity,
+		LRUKey:    "LRU-orders",
+		KeyPrefix: "order:",
+	}
+}
+
 // Тестирует добавление и извлечение заказов в/из кэша
 func TestCachePutAndGet(t *testing.T) {
 	// Используется отдельная бд под номером 1, в проде используется нулевая
-	testCache, err := NewCache("redis://localhost:6379/1")
+	testCache, err := NewCache("redis://localhost:6379/1", testConfig(CacheCapacity))
 	require.NoError(t, err, "NewCache function should not return error if successful")
 
 	// Очищаем кэш
@@ -52,7 +65,7 @@ func TestCachePutAndGet(t *testing.T) {
 // Тестирует вытеснение заазов из кэша при превышении лимита
 func TestCacheLRU(t *testing.T) {
 	// Используется отдельная бд под номером 2 в проде используется нулевая
-	testCache, err := NewCache("redis://localhost:6379/2")
+	testCache, err := NewCache("redis://localhost:6379/2", testConfig(CacheCapacity))
 	require.NoError(t, err, "NewCache function should not return error if successful")
 
 	// Очищаем кэш
@@ -97,7 +110,7 @@ func TestCacheLRU(t *testing.T) {
 // Тестирует заполнение кэша заказами на старте сервиса
 func TestLoadInitialOrders(t *testing.T) {
 	// Используется отдельная бд под номером 3 в проде используется нулевая
-	testCache, err := NewCache("redis://localhost:6379/3")
+	testCache, err := NewCache("redis://localhost:6379/3", testConfig(CacheCapacity))
 	require.NoError(t, err, "NewCache function should not return error if successful")
 
 	// Очищаем кэш
@@ -123,3 +136,301 @@ func TestLoadInitialOrders(t *testing.T) {
 	require.NoError(t, err, "DBSize should not return error if successful")
 	t.Logf("Expected %d/%d orders, got %d\n", ordersToCache, CacheCapacity, currentCap)
 }
+
+// Тестирует, что параллельные записи и чтения не превышают вместимость
+// кэша и не оставляют заказов без записи в ZSET
+func TestCacheParallelCapacity(t *testing.T) {
+	// Используется отдельная бд под номером 4 в проде используется нулевая
+	capacity := int32(20)
+	testCache, err := NewCache("redis://localhost:6379/4", testConfig(capacity))
+	require.NoError(t, err, "NewCache function should not return error if successful")
+
+	// Очищаем кэш
+	err = testCache.redisClient.FlushDB(context.Background()).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	ctx := context.Background()
+	workers := 16
+	ordersPerWorker := 50
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, order := range generator.MakeRandomOrder(ordersPerWorker) {
+				assert.NoError(t, testCache.UpdateCache(ctx, order))
+
+				// Чтения обновляют время обращения и конкурируют с вытеснением
+				_, _ = testCache.GetFromCache(ctx, order.OrderUID)
+
+				currentCap, err := testCache.redisClient.ZCard(ctx, testCache.LRUKey).Result()
+				assert.NoError(t, err)
+				assert.LessOrEqual(t, currentCap, int64(capacity), "Capacity should never be exceeded")
+			}
+		}()
+	}
+	wg.Wait()
+
+	// В итоге кэш заполнен ровно до вместимости, а каждому
+	// заказу в ZSET соответствует ключ с его данными
+	members, err := testCache.redisClient.ZRange(ctx, testCache.LRUKey, 0, -1).Result()
+	require.NoError(t, err)
+	assert.Len(t, members, int(capacity))
+
+	keys, err := testCache.redisClient.DBSize(ctx).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(capacity)+1, keys, "Only cached orders and ZSET should be stored")
+
+	for _, key := range members {
+		exists, err := testCache.redisClient.Exists(ctx, key).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), exists, "Order %s in ZSET should have cached data", key)
+	}
+}
+
+// Тестирует ограничение числа заказов, загружаемых на старте
+func TestLoadInitialOrdersLimit(t *testing.T) {
+	// Используется отдельная бд под номером 5 в проде используется нулевая
+	testCache, err := NewCache("redis://localhost:6379/5", testConfig(CacheCapacity))
+	require.NoError(t, err, "NewCache function should not return error if successful")
+
+	// Очищаем кэш
+	err = testCache.redisClient.FlushDB(context.Background()).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	ctx := context.Background()
+	// Заказы отсортированы от новых к старым, загрузить нужно только 10 первых
+	latestOrders := generator.MakeRandomOrder(30)
+	testCache.LoadInitialOrders(ctx, latestOrders, 10)
+
+	members, err := testCache.redisClient.ZRange(ctx, testCache.LRUKey, 0, -1).Result()
+	require.NoError(t, err)
+	require.Len(t, members, 10, "Only limit orders should be loaded")
+
+	// Самый новый заказ должен вытесняться последним
+	assert.Equal(t, testCache.key(latestOrders[0].OrderUID), members[len(members)-1])
+	assert.Equal(t, testCache.key(latestOrders[9].OrderUID), members[0])
+}
+
+// Тестирует хранение заказов под ключами с префиксом
+func TestCacheKeyPrefix(t *testing.T) {
+	// Используется отдельная бд под номером 6 в проде используется нулевая
+	testCache, err := NewCache("redis://localhost:6379/6", testConfig(CacheCapacity))
+	require.NoError(t, err, "NewCache function should not return error if successful")
+
+	// Очищаем кэш
+	err = testCache.redisClient.FlushDB(context.Background()).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	ctx := context.Background()
+	order := generator.MakeRandomOrder(1)[0]
+	require.NoError(t, testCache.UpdateCache(ctx, order))
+
+	// В Redis нет ключей с голым uid заказа
+	keys, err := testCache.redisClient.Keys(ctx, "*").Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{testCache.LRUKey, "order:v2:" + order.OrderUID}, keys)
+}
+
+// Тестирует, что заказы прежнего формата из кэша не читаются и вытесняются первыми
+func TestCacheLegacyFormat(t *testing.T) {
+	// Используется отдельная бд под номером 12 в проде используется нулевая
+	testCache, err := NewCache("redis://localhost:6379/12", testConfig(2))
+	require.NoError(t, err, "NewCache function should not return error if successful")
+
+	// Очищаем кэш
+	err = testCache.redisClient.FlushDB(context.Background()).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	ctx := context.Background()
+	orders := generator.MakeRandomOrder(2)
+
+	// Запись v1: ключ без версии, oof_shard в поле status
+	legacyKey := "order:" + orders[0].OrderUID
+	legacy := `{"order_uid": "` + orders[0].OrderUID + `", "status": "` + orders[0].OofShard + `"}`
+	require.NoError(t, testCache.redisClient.Set(ctx, legacyKey, legacy, 0).Err())
+	require.NoError(t, testCache.redisClient.ZAdd(ctx, testCache.LRUKey, redis.Z{Score: 1, Member: legacyKey}).Err())
+
+	_, err = testCache.GetFromCache(ctx, orders[0].OrderUID)
+	assert.ErrorIs(t, err, redis.Nil, "Legacy entry should not be served")
+
+	// Новые заказы вытесняют запись прежнего формата
+	for _, order := range orders {
+		require.NoError(t, testCache.UpdateCache(ctx, order))
+	}
+	exists, err := testCache.redisClient.Exists(ctx, legacyKey).Result()
+	require.NoError(t, err)
+	assert.Zero(t, exists, "Legacy entry should be evicted first")
+
+	cached, err := testCache.GetFromCache(ctx, orders[0].OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, orders[0].OofShard, cached.OofShard)
+}
+
+// Тестирует удаление заказа из кэша
+func TestCacheInvalidate(t *testing.T) {
+	// Используется отдельная бд под номером 9 в проде используется нулевая
+	testCache, err := NewCache("redis://localhost:6379/9", testConfig(CacheCapacity))
+	require.NoError(t, err, "NewCache function should not return error if successful")
+
+	// Очищаем кэш
+	err = testCache.redisClient.FlushDB(context.Background()).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	ctx := context.Background()
+	orders := generator.MakeRandomOrder(2)
+	for _, order := range orders {
+		require.NoError(t, testCache.UpdateCache(ctx, order))
+	}
+
+	require.NoError(t, testCache.Invalidate(ctx, orders[0].OrderUID))
+	// Удаление отсутствующего заказа не считается ошибкой
+	require.NoError(t, testCache.Invalidate(ctx, "missing"))
+
+	_, err = testCache.GetFromCache(ctx, orders[0].OrderUID)
+	assert.ErrorIs(t, err, redis.Nil, "Invalidated order should not be cached")
+
+	// Из ZSET убирается только удаленный заказ
+	members, err := testCache.redisClient.ZRange(ctx, testCache.LRUKey, 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{testCache.key(orders[1].OrderUID)}, members)
+}
+
+// Тестирует истечение заказов в кэше и их удаление из ZSET
+func TestCacheTTL(t *testing.T) {
+	// Используется отдельная бд под номером 7 в проде используется нулевая
+	cfg := testConfig(CacheCapacity)
+	cfg.TTL = 200 * time.Millisecond
+	testCache, err := NewCache("redis://localhost:6379/7", cfg)
+	require.NoError(t, err, "NewCache function should not return error if successful")
+
+	// Очищаем кэш
+	err = testCache.redisClient.FlushDB(context.Background()).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	ctx := context.Background()
+	orders := generator.MakeRandomOrder(3)
+	for _, order := range orders[:2] {
+		require.NoError(t, testCache.UpdateCache(ctx, order))
+	}
+
+	ttl, err := testCache.redisClient.PTTL(ctx, testCache.key(orders[0].OrderUID)).Result()
+	require.NoError(t, err)
+	assert.Greater(t, ttl, time.Duration(0), "Cached order should expire")
+
+	time.Sleep(300 * time.Millisecond)
+
+	// Истекший заказ не находится и убирается из ZSET при чтении
+	_, err = testCache.GetFromCache(ctx, orders[0].OrderUID)
+	assert.ErrorIs(t, err, redis.Nil)
+
+	// Остальные истекшие заказы убираются из ZSET при следующей записи
+	require.NoError(t, testCache.UpdateCache(ctx, orders[2]))
+	members, err := testCache.redisClient.ZRange(ctx, testCache.LRUKey, 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{testCache.key(orders[2].OrderUID)}, members)
+}
+
+// Тестирует статистику кэша и его очистку
+func TestCacheStatsAndFlush(t *testing.T) {
+	// Используется отдельная бд под номером 10 в проде используется нулевая
+	testCache, err := NewCache("redis://localhost:6379/10", testConfig(CacheCapacity))
+	require.NoError(t, err, "NewCache function should not return error if successful")
+
+	// Очищаем кэш
+	err = testCache.redisClient.FlushDB(context.Background()).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	ctx := context.Background()
+
+	// Пустой кэш
+	stats, err := testCache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Orders)
+	assert.Equal(t, CacheCapacity, stats.Capacity)
+	assert.True(t, stats.OldestAccess.IsZero())
+
+	orders := generator.MakeRandomOrder(3)
+	for _, order := range orders {
+		require.NoError(t, testCache.UpdateCache(ctx, order))
+	}
+	// Посторонний ключ очистка кэша трогать не должна
+	require.NoError(t, testCache.redisClient.Set(ctx, "unrelated", "value", 0).Err())
+
+	stats, err = testCache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Orders)
+	assert.False(t, stats.OldestAccess.IsZero())
+	assert.False(t, stats.NewestAccess.Before(stats.OldestAccess))
+
+	removed, err := testCache.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, removed)
+
+	keys, err := testCache.redisClient.Keys(ctx, "*").Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"unrelated"}, keys)
+}
+
+// Тестирует сохранение пачки заказов за одно обращение к Redis
+func TestUpdateCacheBatch(t *testing.T) {
+	// Используется отдельная бд под номером 11, в проде используется нулевая
+	testCache, err := NewCache("redis://localhost:6379/11", testConfig(5))
+	require.NoError(t, err, "NewCache function should not return error if successful")
+	ctx := context.Background()
+
+	// Очищаем кэш
+	err = testCache.redisClient.FlushDB(ctx).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	orders := generator.MakeRandomOrder(3)
+	require.NoError(t, testCache.UpdateCacheBatch(ctx, orders))
+	for _, order := range orders {
+		cached, err := testCache.GetFromCache(ctx, order.OrderUID)
+		require.NoError(t, err)
+		assert.Equal(t, order.TrackNumber, cached.TrackNumber)
+	}
+
+	// Скрипт добавления пропал из Redis, например, после перезапуска:
+	// пачка все равно сохраняется, а вместимость соблюдается
+	require.NoError(t, testCache.redisClient.ScriptFlush(ctx).Err())
+	more := generator.MakeRandomOrder(4)
+	require.NoError(t, testCache.UpdateCacheBatch(ctx, more))
+
+	count, err := testCache.redisClient.ZCard(ctx, "LRU-orders").Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count, "Batch should not exceed cache capacity")
+
+	_, err = testCache.GetFromCache(ctx, orders[0].OrderUID)
+	assert.Error(t, err, "The oldest order should be evicted")
+	cached, err := testCache.GetFromCache(ctx, more[3].OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, more[3].OrderUID, cached.OrderUID)
+}
+
+// Сравнивает сохранение заказов в кэш по одному и пачкой
+func BenchmarkUpdateCache(b *testing.B) {
+	testCache, err := NewCache("redis://localhost:6379/11", testConfig(CacheCapacity))
+	require.NoError(b, err)
+	ctx := context.Background()
+	orders := generator.MakeRandomOrder(100)
+
+	b.Run("One by one", func(b *testing.B) {
+		for range b.N {
+			for _, order := range orders {
+				if err := testCache.UpdateCache(ctx, order); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("Pipeline", func(b *testing.B) {
+		for range b.N {
+			if err := testCache.UpdateCacheBatch(ctx, orders); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -10,5 +10,8 @@ type OrdersCache interface {
 	LoadInitialOrders(ctx context.Context, latestOrders []*g.Order, limit int32)
 	GetFromCache(ctx context.Context, uid string) (*g.Order, error)
 	UpdateCache(ctx context.Context, order *g.Order) error
+	UpdateCacheBatch(ctx context.Context, orders []*g.Order) error
+	Invalidate(ctx context.Context, uid string) error
+	Ping(ctx context.Context) error
 	Close() error
 }
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	g "orders/internal/generator"
+)
+
+// localLRU - ограниченный по размеру LRU в памяти процесса
+type localLRU struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+type localEntry struct {
+	uid     string
+	order   *g.Order
+	expires time.Time
+}
+
+func newLocalLRU(capacity int, ttl time.Duration) *localLRU {
+	return &localLRU{
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// get возвращает копию заказа и отмечает обращение к нему
+func (l *localLRU) get(uid string) (*g.Order, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[uid]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*localEntry)
+	if l.ttl > 0 && time.Now().After(entry.expires) {
+		l.removeElement(elem)
+		return nil, false
+	}
+
+	l.order.MoveToFront(elem)
+	return cloneOrder(entry.order), true
+}
+
+// set сохраняет копию заказа и вытесняет самый давний заказ сверх вместимости.
+// Возвращает число вытесненных заказов
+func (l *localLRU) set(order *g.Order) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := &localEntry{uid: order.OrderUID, order: cloneOrder(order)}
+	if l.ttl > 0 {
+		entry.expires = time.Now().Add(l.ttl)
+	}
+
+	if elem, ok := l.items[order.OrderUID]; ok {
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+		return 0
+	}
+	l.items[order.OrderUID] = l.order.PushFront(entry)
+
+	evicted := 0
+	for l.order.Len() > l.capacity {
+		l.removeElement(l.order.Back())
+		evicted++
+	}
+	return evicted
+}
+
+func (l *localLRU) remove(uid string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[uid]; ok {
+		l.removeElement(elem)
+	}
+}
+
+func (l *localLRU) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *localLRU) removeElement(elem *list.Element) {
+	l.order.Remove(elem)
+	delete(l.items, elem.Value.(*localEntry).uid)
+}
+
+// cloneOrder копирует заказ, чтобы вызывающий код не мог изменить закэшированный
+func cloneOrder(order *g.Order) *g.Order {
+	clone := *order
+	clone.Items = append([]g.Item(nil), order.Items...)
+	return &clone
+}
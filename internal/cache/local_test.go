@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"orders/internal/generator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирует вытеснение самых давних заказов из памяти
+func TestLocalLRUEviction(t *testing.T) {
+	local := newLocalLRU(3, 0)
+	orders := generator.MakeRandomOrder(4)
+
+	for _, order := range orders[:3] {
+		assert.Zero(t, local.set(order))
+	}
+
+	// Обращение к первому заказу делает самым давним второй
+	_, ok := local.get(orders[0].OrderUID)
+	require.True(t, ok)
+
+	assert.Equal(t, 1, local.set(orders[3]), "One order should be evicted")
+	assert.Equal(t, 3, local.len(), "Capacity should not be exceeded")
+
+	_, ok = local.get(orders[1].OrderUID)
+	assert.False(t, ok, "Least recently used order should be evicted")
+	for _, order := range []int{0, 2, 3} {
+		_, ok = local.get(orders[order].OrderUID)
+		assert.True(t, ok, "Order %d should stay in memory", order)
+	}
+}
+
+// Тестирует истечение заказов в памяти
+func TestLocalLRUTTL(t *testing.T) {
+	local := newLocalLRU(10, 20*time.Millisecond)
+	order := generator.MakeRandomOrder(1)[0]
+	local.set(order)
+
+	_, ok := local.get(order.OrderUID)
+	require.True(t, ok)
+
+	time.Sleep(30 * time.Millisecond)
+	_, ok = local.get(order.OrderUID)
+	assert.False(t, ok, "Expired order should not be returned")
+	assert.Zero(t, local.len(), "Expired order should be removed")
+}
+
+// Тестирует, что изменение полученного заказа не портит закэшированный
+func TestLocalLRUCopies(t *testing.T) {
+	local := newLocalLRU(10, 0)
+	order := generator.MakeRandomOrder(1)[0]
+	trackNumber := order.TrackNumber
+	local.set(order)
+
+	order.TrackNumber = "changed after set"
+	got, ok := local.get(order.OrderUID)
+	require.True(t, ok)
+	assert.Equal(t, trackNumber, got.TrackNumber)
+
+	got.Items[0].Name = "changed after get"
+	again, ok := local.get(order.OrderUID)
+	require.True(t, ok)
+	assert.NotEqual(t, "changed after get", again.Items[0].Name)
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"orders/internal/config"
+	g "orders/internal/generator"
+	"orders/internal/logging"
+	"orders/internal/metrics"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TieredCache держит самые востребованные заказы в памяти процесса перед
+// Redis. Запись заказа рассылается через Redis pub/sub, и остальные
+// экземпляры сервиса убирают его из своей памяти, чтобы не отдавать
+// устаревшие данные. TTL в памяти ограничивает устаревание, если
+// уведомление потерялось при обрыве соединения с Redis
+type TieredCache struct {
+	redis   *Cache
+	local   *localLRU
+	pubsub  *redis.PubSub
+	channel string
+	// instanceID отличает свои уведомления от уведомлений других экземпляров
+	instanceID string
+	// generation растет при каждой инвалидации: заказ, прочитанный из Redis
+	// до инвалидации, не должен попасть в память после нее
+	generation atomic.Uint64
+	done       chan struct{}
+}
+
+func NewTieredCache(ctx context.Context, redisCache *Cache, cfg config.LocalCache) (*TieredCache, error) {
+	instanceID, err := newInstanceID()
+	if err != nil {
+		return nil, err
+	}
+
+	pubsub := redisCache.redisClient.Subscribe(ctx, cfg.Channel)
+	// Дожидаемся подтверждения подписки, чтобы ошибка проявилась на старте
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("Error subscribing to cache invalidation channel: %w", err)
+	}
+
+	t := &TieredCache{
+		redis:      redisCache,
+		local:      newLocalLRU(cfg.Capacity, cfg.TTL),
+		pubsub:     pubsub,
+		channel:    cfg.Channel,
+		instanceID: instanceID,
+		done:       make(chan struct{}),
+	}
+	go t.listen(pubsub.Channel())
+	return t, nil
+}
+
+func newInstanceID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("Error generating cache instance id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// listen убирает из памяти заказы, записанные другими экземплярами
+func (t *TieredCache) listen(messages <-chan *redis.Message) {
+	defer close(t.done)
+	for msg := range messages {
+		origin, uid, ok := strings.Cut(msg.Payload, "|")
+		if !ok || origin == t.instanceID {
+			continue
+		}
+		t.invalidate(uid)
+	}
+}
+
+func (t *TieredCache) invalidate(uid string) {
+	t.generation.Add(1)
+	t.local.remove(uid)
+	metrics.LocalCacheInvalidations.Inc()
+}
+
+// LoadInitialOrders заполняет только Redis: в память заказы
+// попадают при чтении
+func (t *TieredCache) LoadInitialOrders(ctx context.Context, latestOrders []*g.Order, limit int32) {
+	t.redis.LoadInitialOrders(ctx, latestOrders, limit)
+}
+
+func (t *TieredCache) GetFromCache(ctx context.Context, uid string) (*g.Order, error) {
+	if order, ok := t.local.get(uid); ok {
+		metrics.LocalCacheLookups.WithLabelValues(metrics.ResultHit).Inc()
+		return order, nil
+	}
+	metrics.LocalCacheLookups.WithLabelValues(metrics.ResultMiss).Inc()
+
+	generation := t.generation.Load()
+	order, err := t.redis.GetFromCache(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.generation.Load() == generation {
+		t.storeLocal(order)
+	}
+	return order, nil
+}
+
+func (t *TieredCache) UpdateCache(ctx context.Context, order *g.Order) error {
+	t.generation.Add(1)
+
+	err := t.redis.UpdateCache(ctx, order)
+	if err != nil {
+		// В памяти не должна остаться версия, расходящаяся с Redis
+		t.local.remove(order.OrderUID)
+		return err
+	}
+	t.storeLocal(order)
+
+	return t.publish(ctx, order.OrderUID)
+}
+
+func (t *TieredCache) UpdateCacheBatch(ctx context.Context, orders []*g.Order) error {
+	t.generation.Add(1)
+
+	uids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		uids = append(uids, order.OrderUID)
+	}
+
+	err := t.redis.UpdateCacheBatch(ctx, orders)
+	if err != nil {
+		for _, uid := range uids {
+			t.local.remove(uid)
+		}
+		return err
+	}
+	for _, order := range orders {
+		t.storeLocal(order)
+	}
+
+	return t.publish(ctx, uids...)
+}
+
+// Invalidate удаляет заказ из памяти и Redis и сообщает об этом остальным экземплярам
+func (t *TieredCache) Invalidate(ctx context.Context, uid string) error {
+	t.generation.Add(1)
+	t.local.remove(uid)
+
+	err := t.redis.Invalidate(ctx, uid)
+	if err != nil {
+		return err
+	}
+	return t.publish(ctx, uid)
+}
+
+// publish сообщает остальным экземплярам, что версии заказов в памяти устарели
+func (t *TieredCache) publish(ctx context.Context, uids ...string) error {
+	pipe := t.redis.redisClient.Pipeline()
+	for _, uid := range uids {
+		pipe.Publish(ctx, t.channel, t.instanceID+"|"+uid)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		logging.FromContext(ctx).Error("Error publishing cache invalidation", "orders", len(uids), "error", err)
+		return err
+	}
+	return nil
+}
+
+func (t *TieredCache) storeLocal(order *g.Order) {
+	evicted := t.local.set(order)
+	metrics.LocalCacheEvictions.Add(float64(evicted))
+}
+
+func (t *TieredCache) Ping(ctx context.Context) error {
+	return t.redis.Ping(ctx)
+}
+
+func (t *TieredCache) Close() error {
+	err := t.pubsub.Close()
+	<-t.done
+	if closeErr := t.redis.Close(); closeErr != nil {
+		return closeErr
+	}
+	return err
+}
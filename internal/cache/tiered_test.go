@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"orders/internal/config"
+	"orders/internal/generator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTieredCache создает кэш в памяти поверх отдельной бд Redis под номером 8
+func newTestTieredCache(t *testing.T) *TieredCache {
+	redisCache, err := NewCache("redis://localhost:6379/8", testConfig(CacheCapacity))
+	require.NoError(t, err, "NewCache function should not return error if successful")
+
+	tiered, err := NewTieredCache(context.Background(), redisCache, config.LocalCache{
+		Capacity: 10,
+		TTL:      time.Minute,
+		Channel:  "test-cache-invalidation",
+	})
+	require.NoError(t, err, "NewTieredCache function should not return error if successful")
+	t.Cleanup(func() { tiered.Close() })
+	return tiered
+}
+
+// Тестирует чтение востребованных заказов из памяти без обращения к Redis
+func TestTieredCacheLocalHit(t *testing.T) {
+	tiered := newTestTieredCache(t)
+	ctx := context.Background()
+
+	// Очищаем кэш
+	err := tiered.redis.redisClient.FlushDB(ctx).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	order := generator.MakeRandomOrder(1)[0]
+	require.NoError(t, tiered.UpdateCache(ctx, order))
+
+	// Даже после удаления из Redis заказ отдается из памяти
+	err = tiered.redis.redisClient.FlushDB(ctx).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	cached, err := tiered.GetFromCache(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, order.OrderUID, cached.OrderUID)
+}
+
+// Тестирует инвалидацию заказа в памяти другого экземпляра сервиса
+func TestTieredCacheInvalidation(t *testing.T) {
+	first := newTestTieredCache(t)
+	second := newTestTieredCache(t)
+	ctx := context.Background()
+
+	// Очищаем кэш
+	err := first.redis.redisClient.FlushDB(ctx).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	order := generator.MakeRandomOrder(1)[0]
+	require.NoError(t, first.UpdateCache(ctx, order))
+
+	// Второй экземпляр читает заказ из Redis и запоминает его
+	cached, err := second.GetFromCache(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, order.TrackNumber, cached.TrackNumber)
+
+	// Первый экземпляр меняет заказ, второй должен увидеть новую версию
+	order.TrackNumber = "UPDATED-TRACK"
+	require.NoError(t, first.UpdateCache(ctx, order))
+
+	assert.Eventually(t, func() bool {
+		cached, err := second.GetFromCache(ctx, order.OrderUID)
+		return err == nil && cached.TrackNumber == "UPDATED-TRACK"
+	}, time.Second, 10*time.Millisecond, "Second replica should drop stale order from memory")
+
+	// Свои уведомления экземпляр пропускает и не теряет заказ из памяти
+	_, ok := first.local.get(order.OrderUID)
+	assert.True(t, ok)
+}
+
+// Тестирует сохранение пачки заказов и уведомление остальных экземпляров о каждом
+func TestTieredCacheUpdateBatch(t *testing.T) {
+	first := newTestTieredCache(t)
+	second := newTestTieredCache(t)
+	ctx := context.Background()
+
+	// Очищаем кэш
+	err := first.redis.redisClient.FlushDB(ctx).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	orders := generator.MakeRandomOrder(2)
+	require.NoError(t, first.UpdateCacheBatch(ctx, orders))
+	for _, order := range orders {
+		_, ok := first.local.get(order.OrderUID)
+		assert.True(t, ok, "Batch should be stored in memory")
+		_, err := second.GetFromCache(ctx, order.OrderUID)
+		require.NoError(t, err)
+	}
+
+	for _, order := range orders {
+		order.TrackNumber = "UPDATED-TRACK"
+	}
+	require.NoError(t, first.UpdateCacheBatch(ctx, orders))
+
+	assert.Eventually(t, func() bool {
+		for _, order := range orders {
+			cached, err := second.GetFromCache(ctx, order.OrderUID)
+			if err != nil || cached.TrackNumber != "UPDATED-TRACK" {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond, "Second replica should drop every stale order from memory")
+}
+
+// Тестирует удаление заказа из памяти и Redis на всех экземплярах
+func TestTieredCacheInvalidate(t *testing.T) {
+	first := newTestTieredCache(t)
+	second := newTestTieredCache(t)
+	ctx := context.Background()
+
+	// Очищаем кэш
+	err := first.redis.redisClient.FlushDB(ctx).Err()
+	require.NoError(t, err, "Failed to flush Redis")
+
+	order := generator.MakeRandomOrder(1)[0]
+	require.NoError(t, first.UpdateCache(ctx, order))
+	_, err = second.GetFromCache(ctx, order.OrderUID)
+	require.NoError(t, err)
+
+	require.NoError(t, first.Invalidate(ctx, order.OrderUID))
+
+	_, err = first.GetFromCache(ctx, order.OrderUID)
+	assert.Error(t, err, "Invalidated order should not be cached")
+
+	assert.Eventually(t, func() bool {
+		_, ok := second.local.get(order.OrderUID)
+		return !ok
+	}, time.Second, 10*time.Millisecond, "Second replica should drop invalidated order from memory")
+}
@@ -0,0 +1,539 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config содержит все настройки сервиса. Значения берутся по возрастанию
+// приоритета: значения по умолчанию, YAML файл, переменные окружения, флаги
+type Config struct {
+	Log        Log        `yaml:"log"`
+	HTTP       HTTP       `yaml:"http"`
+	Postgres   Postgres   `yaml:"postgres"`
+	Redis      Redis      `yaml:"redis"`
+	Cache      Cache      `yaml:"cache"`
+	Kafka      Kafka      `yaml:"kafka"`
+	Repository Repository `yaml:"repository"`
+	Validation Validation `yaml:"validation"`
+}
+
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type HTTP struct {
+	Addr string `yaml:"addr"`
+	// ShutdownDelay - пауза между переходом в состояние неготовности
+	// и остановкой сервера, чтобы балансировщик успел снять трафик
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+}
+
+type Postgres struct {
+	Driver string `yaml:"driver"`
+	URL    string `yaml:"url"`
+	// AutoMigrate - применять миграции схемы бд при запуске сервиса
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+type Redis struct {
+	URL string `yaml:"url"`
+}
+
+type Cache struct {
+	Capacity int32 `yaml:"capacity"`
+	// TTL - время жизни заказа в кэше без обращений к нему, 0 - без ограничения
+	TTL       time.Duration `yaml:"ttl"`
+	LRUKey    string        `yaml:"lru_key"`
+	KeyPrefix string        `yaml:"key_prefix"`
+	// Local - кэш в памяти процесса перед Redis
+	Local LocalCache `yaml:"local"`
+}
+
+type LocalCache struct {
+	Enabled  bool `yaml:"enabled"`
+	Capacity int  `yaml:"capacity"`
+	// TTL ограничивает устаревание заказа, если уведомление об инвалидации потерялось
+	TTL time.Duration `yaml:"ttl"`
+	// Channel - канал Redis pub/sub для инвалидации заказов между экземплярами
+	Channel string `yaml:"channel"`
+}
+
+type Kafka struct {
+	Brokers         []string `yaml:"brokers"`
+	Topic           string   `yaml:"topic"`
+	DeadLetterTopic string   `yaml:"dead_letter_topic"`
+	StatusTopic     string   `yaml:"status_topic"`
+	GroupID         string   `yaml:"group_id"`
+	// Partitions - число партиций топиков заказов и статусов
+	Partitions int `yaml:"partitions"`
+	// MessageKey - поле заказа, которое служит ключом сообщения: order_uid или customer_id
+	MessageKey string `yaml:"message_key"`
+	// ProducerID - id отправителя в заголовке каждого сообщения, по умолчанию имя хоста
+	ProducerID string `yaml:"producer_id"`
+	// Workers - число обработчиков сообщений. Сообщения с одним ключом
+	// обрабатываются одним обработчиком по порядку
+	Workers int   `yaml:"workers"`
+	Batch   Batch `yaml:"batch"`
+	Retry   Retry `yaml:"retry"`
+}
+
+// Batch описывает пакетную обработку сообщений из Kafka: сообщения копятся,
+// пока их не наберется Size или не пройдет Timeout с первого из них.
+// Size 0 или 1 отключает пакетную обработку
+type Batch struct {
+	Size    int           `yaml:"size"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Retry описывает повторные попытки сохранения сообщений из Kafka
+type Retry struct {
+	MaxAttempts int           `yaml:"max_attempts"`
+	BaseBackoff time.Duration `yaml:"base_backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+	Jitter      float64       `yaml:"jitter"`
+}
+
+type Repository struct {
+	TxScope        string `yaml:"tx_scope"`
+	ConflictPolicy string `yaml:"conflict_policy"`
+	// NotFoundTTL - время, в течение которого повторные запросы несуществующего заказа не доходят до бд
+	NotFoundTTL time.Duration `yaml:"not_found_ttl"`
+}
+
+type Validation struct {
+	// PolicyFile - YAML или JSON файл с правилами валидации заказов по entry
+	PolicyFile string `yaml:"policy_file"`
+	// ReloadInterval - как часто проверять изменения файла политики, 0 - не перечитывать
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// Допустимые значения настроек логирования и репозитория
+var (
+	LogLevels        = []string{"debug", "info", "warn", "error"}
+	LogFormats       = []string{"text", "json"}
+	TxScopes         = []string{"order", "batch"}
+	ConflictPolicies = []string{"reject", "skip", "overwrite"}
+	MessageKeys      = []string{"order_uid", "customer_id"}
+)
+
+// Default возвращает конфигурацию для запуска через docker compose
+func Default() *Config {
+	return &Config{
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+		HTTP: HTTP{
+			Addr: ":8080",
+		},
+		Postgres: Postgres{
+			Driver:      "postgres",
+			AutoMigrate: true,
+		},
+		Cache: Cache{
+			Capacity:  200,
+			LRUKey:    "LRU-orders",
+			KeyPrefix: "order:",
+			Local: LocalCache{
+				Capacity: 1000,
+				TTL:      time.Minute,
+				Channel:  "orders-cache-invalidation",
+			},
+		},
+		Kafka: Kafka{
+			Brokers:         []string{"kafka:9092"},
+			Topic:           "orders",
+			DeadLetterTopic: "orders-dlq",
+			StatusTopic:     "orders-status",
+			GroupID:         "orders-group",
+			Partitions:      3,
+			MessageKey:      "order_uid",
+			Workers:         4,
+			Batch: Batch{
+				Timeout: 100 * time.Millisecond,
+			},
+			Retry: Retry{
+				MaxAttempts: 5,
+				BaseBackoff: 200 * time.Millisecond,
+				MaxBackoff:  10 * time.Second,
+				Jitter:      0.2,
+			},
+		},
+		Repository: Repository{
+			TxScope:        "order",
+			ConflictPolicy: "reject",
+			NotFoundTTL:    5 * time.Second,
+		},
+		Validation: Validation{
+			ReloadInterval: 10 * time.Second,
+		},
+	}
+}
+
+// setting связывает поле конфигурации с переменной окружения и флагом
+type setting struct {
+	key   string
+	env   string
+	flag  string
+	usage string
+	apply func(cfg *Config, value string) error
+}
+
+var settings = []setting{
+	{"log.level", "LOG_LEVEL", "log-level", "minimal log level: " + strings.Join(LogLevels, ", "),
+		func(cfg *Config, v string) error { cfg.Log.Level = v; return nil }},
+	{"log.format", "LOG_FORMAT", "log-format", "log output format: " + strings.Join(LogFormats, ", "),
+		func(cfg *Config, v string) error { cfg.Log.Format = v; return nil }},
+	{"http.addr", "HTTP_ADDR", "http-addr", "HTTP server listen address",
+		func(cfg *Config, v string) error { cfg.HTTP.Addr = v; return nil }},
+	{"http.shutdown_delay", "HTTP_SHUTDOWN_DELAY", "http-shutdown-delay", "delay between readiness flip and HTTP server shutdown",
+		func(cfg *Config, v string) error { return setDuration(&cfg.HTTP.ShutdownDelay, v) }},
+	{"postgres.driver", "DRIVER", "db-driver", "database/sql driver name",
+		func(cfg *Config, v string) error { cfg.Postgres.Driver = v; return nil }},
+	{"postgres.url", "DB_CONN_STRING", "db-url", "PostgreSQL connection string",
+		func(cfg *Config, v string) error { cfg.Postgres.URL = v; return nil }},
+	{"postgres.auto_migrate", "DB_AUTO_MIGRATE", "db-auto-migrate", "apply database schema migrations on startup",
+		func(cfg *Config, v string) error { return setBool(&cfg.Postgres.AutoMigrate, v) }},
+	{"redis.url", "REDIS_CONN_STRING", "redis-url", "Redis connection string",
+		func(cfg *Config, v string) error { cfg.Redis.URL = v; return nil }},
+	{"cache.capacity", "CACHE_CAPACITY", "cache-capacity", "maximum number of cached orders",
+		func(cfg *Config, v string) error { return setInt32(&cfg.Cache.Capacity, v) }},
+	{"cache.ttl", "CACHE_TTL", "cache-ttl", "time an order stays cached without reads, 0 to keep until evicted",
+		func(cfg *Config, v string) error { return setDuration(&cfg.Cache.TTL, v) }},
+	{"cache.lru_key", "CACHE_LRU_KEY", "cache-lru-key", "Redis key of the sorted set ranking cached orders by last access",
+		func(cfg *Config, v string) error { cfg.Cache.LRUKey = v; return nil }},
+	{"cache.key_prefix", "CACHE_KEY_PREFIX", "cache-key-prefix", "prefix of Redis keys with cached orders",
+		func(cfg *Config, v string) error { cfg.Cache.KeyPrefix = v; return nil }},
+	{"cache.local.enabled", "CACHE_LOCAL_ENABLED", "cache-local-enabled", "keep hot orders in process memory in front of Redis",
+		func(cfg *Config, v string) error { return setBool(&cfg.Cache.Local.Enabled, v) }},
+	{"cache.local.capacity", "CACHE_LOCAL_CAPACITY", "cache-local-capacity", "maximum number of orders kept in process memory",
+		func(cfg *Config, v string) error { return setInt(&cfg.Cache.Local.Capacity, v) }},
+	{"cache.local.ttl", "CACHE_LOCAL_TTL", "cache-local-ttl", "time an order stays in process memory, bounds staleness if an invalidation is lost",
+		func(cfg *Config, v string) error { return setDuration(&cfg.Cache.Local.TTL, v) }},
+	{"cache.local.channel", "CACHE_LOCAL_CHANNEL", "cache-local-channel", "Redis pub/sub channel for invalidating orders across replicas",
+		func(cfg *Config, v string) error { cfg.Cache.Local.Channel = v; return nil }},
+	{"kafka.brokers", "KAFKA_BROKERS", "kafka-brokers", "comma-separated list of Kafka brokers",
+		func(cfg *Config, v string) error { cfg.Kafka.Brokers = splitList(v); return nil }},
+	{"kafka.topic", "KAFKA_TOPIC", "kafka-topic", "Kafka topic with orders",
+		func(cfg *Config, v string) error { cfg.Kafka.Topic = v; return nil }},
+	{"kafka.dead_letter_topic", "KAFKA_DEAD_LETTER_TOPIC", "kafka-dead-letter-topic", "Kafka topic for failed messages",
+		func(cfg *Config, v string) error { cfg.Kafka.DeadLetterTopic = v; return nil }},
+	{"kafka.status_topic", "KAFKA_STATUS_TOPIC", "kafka-status-topic", "Kafka topic for order status change events",
+		func(cfg *Config, v string) error { cfg.Kafka.StatusTopic = v; return nil }},
+	{"kafka.group_id", "KAFKA_GROUP_ID", "kafka-group-id", "Kafka consumer group",
+		func(cfg *Config, v string) error { cfg.Kafka.GroupID = v; return nil }},
+	{"kafka.partitions", "KAFKA_PARTITIONS", "kafka-partitions", "partitions of the orders and status topics, existing topics are only grown",
+		func(cfg *Config, v string) error { return setInt(&cfg.Kafka.Partitions, v) }},
+	{"kafka.message_key", "KAFKA_MESSAGE_KEY", "kafka-message-key", "order field used as Kafka message key: " + strings.Join(MessageKeys, ", "),
+		func(cfg *Config, v string) error { cfg.Kafka.MessageKey = v; return nil }},
+	{"kafka.producer_id", "KAFKA_PRODUCER_ID", "kafka-producer-id", "producer id sent in the message envelope, hostname if empty",
+		func(cfg *Config, v string) error { cfg.Kafka.ProducerID = v; return nil }},
+	{"kafka.workers", "KAFKA_WORKERS", "kafka-workers", "concurrent message handlers, messages with the same key are handled in order",
+		func(cfg *Config, v string) error { return setInt(&cfg.Kafka.Workers, v) }},
+	{"kafka.batch.size", "KAFKA_BATCH_SIZE", "kafka-batch-size", "messages saved and committed together, 0 or 1 handles messages one by one",
+		func(cfg *Config, v string) error { return setInt(&cfg.Kafka.Batch.Size, v) }},
+	{"kafka.batch.timeout", "KAFKA_BATCH_TIMEOUT", "kafka-batch-timeout", "maximum wait for a batch to fill up",
+		func(cfg *Config, v string) error { return setDuration(&cfg.Kafka.Batch.Timeout, v) }},
+	{"kafka.retry.max_attempts", "KAFKA_RETRY_MAX_ATTEMPTS", "kafka-retry-max-attempts", "attempts to save a message before sending it to dead-letter topic",
+		func(cfg *Config, v string) error { return setInt(&cfg.Kafka.Retry.MaxAttempts, v) }},
+	{"kafka.retry.base_backoff", "KAFKA_RETRY_BASE_BACKOFF", "kafka-retry-base-backoff", "delay before the first retry",
+		func(cfg *Config, v string) error { return setDuration(&cfg.Kafka.Retry.BaseBackoff, v) }},
+	{"kafka.retry.max_backoff", "KAFKA_RETRY_MAX_BACKOFF", "kafka-retry-max-backoff", "maximum delay between retries",
+		func(cfg *Config, v string) error { return setDuration(&cfg.Kafka.Retry.MaxBackoff, v) }},
+	{"kafka.retry.jitter", "KAFKA_RETRY_JITTER", "kafka-retry-jitter", "random deviation of retry delay, from 0 to 1",
+		func(cfg *Config, v string) error { return setFloat(&cfg.Kafka.Retry.Jitter, v) }},
+	{"repository.tx_scope", "REPOSITORY_TX_SCOPE", "repository-tx-scope", "transaction per order or per batch: " + strings.Join(TxScopes, ", "),
+		func(cfg *Config, v string) error { cfg.Repository.TxScope = v; return nil }},
+	{"repository.conflict_policy", "REPOSITORY_CONFLICT_POLICY", "repository-conflict-policy", "handling of already saved orders: " + strings.Join(ConflictPolicies, ", "),
+		func(cfg *Config, v string) error { cfg.Repository.ConflictPolicy = v; return nil }},
+	{"repository.not_found_ttl", "REPOSITORY_NOT_FOUND_TTL", "repository-not-found-ttl", "time a missing order uid is answered without querying the database, 0 to disable",
+		func(cfg *Config, v string) error { return setDuration(&cfg.Repository.NotFoundTTL, v) }},
+	{"validation.policy_file", "VALIDATION_POLICY_FILE", "validation-policy-file", "YAML or JSON file with order validation rules per entry, built-in rules only if empty",
+		func(cfg *Config, v string) error { cfg.Validation.PolicyFile = v; return nil }},
+	{"validation.reload_interval", "VALIDATION_RELOAD_INTERVAL", "validation-reload-interval", "how often to check the validation policy file for changes, 0 to disable reloading",
+		func(cfg *Config, v string) error { return setDuration(&cfg.Validation.ReloadInterval, v) }},
+}
+
+// Load собирает конфигурацию из файла, переменных окружения и флагов командной
+// строки args. Путь к файлу задается флагом -config или переменной CONFIG_FILE
+func Load(args []string) (*Config, error) {
+	return LoadFlagSet(flag.NewFlagSet("orders", flag.ContinueOnError), args)
+}
+
+// LoadFlagSet работает как Load, но регистрирует флаги настроек в fs, чтобы
+// утилиты могли добавить к ним свои. Аргументы после флагов остаются в fs.Args()
+func LoadFlagSet(fs *flag.FlagSet, args []string) (*Config, error) {
+	return loadFlagSet(fs, args, (*Config).Validate)
+}
+
+// LoadKafkaFlagSet работает как LoadFlagSet, но проверяет только настройки
+// логов и Kafka: для команд, которым не нужны PostgreSQL и Redis
+func LoadKafkaFlagSet(fs *flag.FlagSet, args []string) (*Config, error) {
+	return loadFlagSet(fs, args, (*Config).ValidateKafka)
+}
+
+func loadFlagSet(fs *flag.FlagSet, args []string, validate func(cfg *Config) error) (*Config, error) {
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file")
+
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.flag] = fs.String(s.flag, "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	if *configPath != "" {
+		if err := loadFile(cfg, *configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := s.apply(cfg, value); err != nil {
+			errs = append(errs, fmt.Errorf("env %s: %w", s.env, err))
+		}
+	}
+
+	// Применяем только флаги, явно переданные в командной строке
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag != f.Name {
+				continue
+			}
+			if err := s.apply(cfg, *flagValues[s.flag]); err != nil {
+				errs = append(errs, fmt.Errorf("flag -%s: %w", s.flag, err))
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := validate(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("Error parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// checker собирает ошибки проверки конфигурации, чтобы вывести их разом
+type checker struct {
+	errs []error
+}
+
+func (c *checker) check(ok bool, key, problem string) {
+	if !ok {
+		c.errs = append(c.errs, fmt.Errorf("%s %s%s", key, problem, hint(key)))
+	}
+}
+
+func (c *checker) err() error {
+	if len(c.errs) > 0 {
+		return fmt.Errorf("Invalid configuration:\n%w", errors.Join(c.errs...))
+	}
+	return nil
+}
+
+// Validate проверяет конфигурацию и возвращает все найденные ошибки разом
+func (cfg *Config) Validate() error {
+	var c checker
+	cfg.checkLog(&c)
+	check := c.check
+
+	check(cfg.HTTP.Addr != "", "http.addr", "is required")
+	check(cfg.HTTP.ShutdownDelay >= 0, "http.shutdown_delay",
+		fmt.Sprintf("should not be negative, got %v", cfg.HTTP.ShutdownDelay))
+	check(cfg.Postgres.Driver != "", "postgres.driver", "is required")
+	check(cfg.Postgres.URL != "", "postgres.url", "is required")
+	check(cfg.Redis.URL != "", "redis.url", "is required")
+	check(cfg.Cache.Capacity > 0, "cache.capacity",
+		fmt.Sprintf("should be positive, got %d", cfg.Cache.Capacity))
+	check(cfg.Cache.TTL >= 0, "cache.ttl",
+		fmt.Sprintf("should not be negative, got %v", cfg.Cache.TTL))
+	check(cfg.Cache.TTL == 0 || cfg.Cache.TTL >= time.Millisecond, "cache.ttl",
+		fmt.Sprintf("should be at least 1ms, got %v", cfg.Cache.TTL))
+	check(cfg.Cache.LRUKey != "", "cache.lru_key", "is required")
+	if local := cfg.Cache.Local; local.Enabled {
+		check(local.Capacity > 0, "cache.local.capacity",
+			fmt.Sprintf("should be positive, got %d", local.Capacity))
+		// Уведомления pub/sub теряются, например при переподключении к Redis,
+		// и без TTL пропустивший их экземпляр отдавал бы устаревший заказ до вытеснения
+		check(local.TTL > 0, "cache.local.ttl",
+			fmt.Sprintf("should be positive when cache.local.enabled is set, got %v", local.TTL))
+		check(local.Channel != "", "cache.local.channel", "is required")
+	}
+	check(cfg.Cache.KeyPrefix == "" || !strings.HasPrefix(cfg.Cache.LRUKey, cfg.Cache.KeyPrefix), "cache.lru_key",
+		fmt.Sprintf("should not start with cache.key_prefix %q", cfg.Cache.KeyPrefix))
+
+	cfg.checkKafka(&c)
+
+	check(oneOf(cfg.Repository.TxScope, TxScopes), "repository.tx_scope",
+		fmt.Sprintf("should be one of %s, got %q", strings.Join(TxScopes, ", "), cfg.Repository.TxScope))
+	check(oneOf(cfg.Repository.ConflictPolicy, ConflictPolicies), "repository.conflict_policy",
+		fmt.Sprintf("should be one of %s, got %q", strings.Join(ConflictPolicies, ", "), cfg.Repository.ConflictPolicy))
+	check(cfg.Repository.NotFoundTTL >= 0, "repository.not_found_ttl",
+		fmt.Sprintf("should not be negative, got %v", cfg.Repository.NotFoundTTL))
+	check(cfg.Validation.ReloadInterval >= 0, "validation.reload_interval",
+		fmt.Sprintf("should not be negative, got %v", cfg.Validation.ReloadInterval))
+
+	return c.err()
+}
+
+// ValidateKafka проверяет только настройки логов и Kafka
+func (cfg *Config) ValidateKafka() error {
+	var c checker
+	cfg.checkLog(&c)
+	cfg.checkKafka(&c)
+	return c.err()
+}
+
+func (cfg *Config) checkLog(c *checker) {
+	c.check(oneOf(cfg.Log.Level, LogLevels), "log.level",
+		fmt.Sprintf("should be one of %s, got %q", strings.Join(LogLevels, ", "), cfg.Log.Level))
+	c.check(oneOf(cfg.Log.Format, LogFormats), "log.format",
+		fmt.Sprintf("should be one of %s, got %q", strings.Join(LogFormats, ", "), cfg.Log.Format))
+}
+
+func (cfg *Config) checkKafka(c *checker) {
+	check := c.check
+	check(len(cfg.Kafka.Brokers) > 0, "kafka.brokers", "should contain at least one broker")
+	check(cfg.Kafka.Topic != "", "kafka.topic", "is required")
+	check(cfg.Kafka.DeadLetterTopic != "", "kafka.dead_letter_topic", "is required")
+	check(cfg.Kafka.DeadLetterTopic != cfg.Kafka.Topic, "kafka.dead_letter_topic", "should differ from kafka.topic")
+	check(cfg.Kafka.StatusTopic != "", "kafka.status_topic", "is required")
+	check(cfg.Kafka.StatusTopic != cfg.Kafka.Topic && cfg.Kafka.StatusTopic != cfg.Kafka.DeadLetterTopic, "kafka.status_topic",
+		"should differ from kafka.topic and kafka.dead_letter_topic")
+	check(cfg.Kafka.GroupID != "", "kafka.group_id", "is required")
+	check(cfg.Kafka.Partitions > 0, "kafka.partitions",
+		fmt.Sprintf("should be positive, got %d", cfg.Kafka.Partitions))
+	check(oneOf(cfg.Kafka.MessageKey, MessageKeys), "kafka.message_key",
+		fmt.Sprintf("should be one of %s, got %q", strings.Join(MessageKeys, ", "), cfg.Kafka.MessageKey))
+	check(cfg.Kafka.Workers > 0, "kafka.workers",
+		fmt.Sprintf("should be positive, got %d", cfg.Kafka.Workers))
+
+	batch := cfg.Kafka.Batch
+	check(batch.Size >= 0, "kafka.batch.size",
+		fmt.Sprintf("should not be negative, got %d", batch.Size))
+	check(batch.Size <= 1 || batch.Timeout > 0, "kafka.batch.timeout",
+		fmt.Sprintf("should be positive, got %v", batch.Timeout))
+
+	retry := cfg.Kafka.Retry
+	check(retry.MaxAttempts > 0, "kafka.retry.max_attempts",
+		fmt.Sprintf("should be positive, got %d", retry.MaxAttempts))
+	check(retry.BaseBackoff > 0, "kafka.retry.base_backoff",
+		fmt.Sprintf("should be positive, got %v", retry.BaseBackoff))
+	check(retry.MaxBackoff >= retry.BaseBackoff, "kafka.retry.max_backoff",
+		fmt.Sprintf("should not be less than base_backoff, got %v", retry.MaxBackoff))
+	check(retry.Jitter >= 0 && retry.Jitter <= 1, "kafka.retry.jitter",
+		fmt.Sprintf("should be from 0 to 1, got %v", retry.Jitter))
+}
+
+// hint подсказывает, где задать значение настройки key
+func hint(key string) string {
+	for _, s := range settings {
+		if s.key == key {
+			return fmt.Sprintf(" (set %s in config file, env %s or flag -%s)", s.key, s.env, s.flag)
+		}
+	}
+	return ""
+}
+
+func oneOf(value string, allowed []string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+func setInt(dst *int, value string) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	*dst = v
+	return nil
+}
+
+func setBool(dst *bool, value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", value)
+	}
+	*dst = v
+	return nil
+}
+
+func setInt32(dst *int32, value string) error {
+	v, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	*dst = int32(v)
+	return nil
+}
+
+func setFloat(dst *float64, value string) error {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	*dst = v
+	return nil
+}
+
+func setDuration(dst *time.Duration, value string) error {
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration", value)
+	}
+	*dst = v
+	return nil
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig сохраняет YAML во временный файл и возвращает путь к нему
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+// clearEnv убирает переменные окружения, которые могли попасть из .env
+func clearEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+	}
+}
+
+// Тестирует загрузку значений по умолчанию с обязательными адресами из окружения
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_CONN_STRING", "postgres://localhost/orders")
+	t.Setenv("REDIS_CONN_STRING", "redis://localhost:6379/0")
+
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+
+	expected := Default()
+	expected.Postgres.URL = "postgres://localhost/orders"
+	expected.Redis.URL = "redis://localhost:6379/0"
+	assert.Equal(t, expected, cfg)
+}
+
+// Тестирует приоритет источников: файл < окружение < флаги
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, `
+http:
+  addr: ":9000"
+postgres:
+  url: postgres://file/orders
+redis:
+  url: redis://file:6379/0
+cache:
+  capacity: 10
+kafka:
+  brokers: [file-1:9092, file-2:9092]
+  topic: file-orders
+  retry:
+    base_backoff: 1s
+    max_backoff: 1m
+repository:
+  conflict_policy: skip
+`)
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("CACHE_CAPACITY", "20")
+	t.Setenv("KAFKA_TOPIC", "env-orders")
+	t.Setenv("KAFKA_BROKERS", "env-1:9092, env-2:9092")
+
+	cfg, err := Load([]string{"-kafka-topic", "flag-orders", "-repository-tx-scope", "batch"})
+	require.NoError(t, err)
+
+	// Значения только из файла
+	assert.Equal(t, ":9000", cfg.HTTP.Addr)
+	assert.Equal(t, "postgres://file/orders", cfg.Postgres.URL)
+	assert.Equal(t, time.Second, cfg.Kafka.Retry.BaseBackoff)
+	assert.Equal(t, time.Minute, cfg.Kafka.Retry.MaxBackoff)
+	assert.Equal(t, "skip", cfg.Repository.ConflictPolicy)
+
+	// Окружение перекрывает файл
+	assert.Equal(t, int32(20), cfg.Cache.Capacity)
+	assert.Equal(t, []string{"env-1:9092", "env-2:9092"}, cfg.Kafka.Brokers)
+
+	// Флаги перекрывают окружение
+	assert.Equal(t, "flag-orders", cfg.Kafka.Topic)
+	assert.Equal(t, "batch", cfg.Repository.TxScope)
+
+	// Не заданные нигде значения остаются по умолчанию
+	assert.Equal(t, "orders-dlq", cfg.Kafka.DeadLetterTopic)
+	assert.Equal(t, "orders-status", cfg.Kafka.StatusTopic)
+	assert.Equal(t, 5, cfg.Kafka.Retry.MaxAttempts)
+}
+
+// Тестирует выбор файла флагом -config
+func TestLoadConfigFlag(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, `
+postgres:
+  url: postgres://file/orders
+redis:
+  url: redis://file:6379/0
+`)
+
+	cfg, err := Load([]string{"-config", path})
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://file/orders", cfg.Postgres.URL)
+}
+
+// Тестирует загрузку с собственными флагами утилиты и позиционными аргументами
+func TestLoadFlagSet(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("REDIS_CONN_STRING", "redis://localhost:6379/0")
+
+	fs := flag.NewFlagSet("orderctl", flag.ContinueOnError)
+	output := fs.String("o", "table", "output format")
+
+	cfg, err := LoadFlagSet(fs, []string{"-o", "json", "-db-url", "postgres://flag/orders", "some-uid"})
+	require.NoError(t, err)
+	assert.Equal(t, "json", *output)
+	assert.Equal(t, "postgres://flag/orders", cfg.Postgres.URL)
+	assert.Equal(t, []string{"some-uid"}, fs.Args())
+}
+
+// Тестирует загрузку настроек для команд, которым нужна только Kafka
+func TestLoadKafkaFlagSet(t *testing.T) {
+	clearEnv(t)
+
+	// Адреса PostgreSQL и Redis не нужны
+	fs := flag.NewFlagSet("orderctl", flag.ContinueOnError)
+	cfg, err := LoadKafkaFlagSet(fs, []string{"-kafka-topic", "orders-test", "10"})
+	require.NoError(t, err)
+	assert.Equal(t, "orders-test", cfg.Kafka.Topic)
+	assert.Equal(t, []string{"10"}, fs.Args())
+
+	// Настройки Kafka проверяются как обычно
+	fs = flag.NewFlagSet("orderctl", flag.ContinueOnError)
+	_, err = LoadKafkaFlagSet(fs, []string{"-kafka-dead-letter-topic", "orders", "-cache-capacity", "0"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "kafka.dead_letter_topic should differ from kafka.topic")
+	assert.NotContains(t, err.Error(), "cache.capacity")
+	assert.NotContains(t, err.Error(), "postgres.url")
+}
+
+// Тестирует ошибки загрузки и проверки конфигурации
+func TestLoadErrors(t *testing.T) {
+	t.Run("Missing required values", func(t *testing.T) {
+		clearEnv(t)
+
+		_, err := Load(nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "postgres.url is required")
+		assert.Contains(t, err.Error(), "env DB_CONN_STRING")
+		assert.Contains(t, err.Error(), "redis.url is required")
+	})
+
+	t.Run("Invalid values", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("DB_CONN_STRING", "postgres://localhost/orders")
+		t.Setenv("REDIS_CONN_STRING", "redis://localhost:6379/0")
+
+		_, err := Load([]string{
+			"-cache-capacity", "0",
+			"-kafka-dead-letter-topic", "orders",
+			"-kafka-status-topic", "orders",
+			"-kafka-message-key", "track_number",
+			"-kafka-workers", "0",
+			"-kafka-retry-jitter", "2",
+			"-repository-conflict-policy", "ignore",
+			"-cache-local-enabled", "true",
+			"-cache-local-ttl", "0s",
+		})
+		require.Error(t, err)
+
+		// Все ошибки выводятся разом
+		assert.Contains(t, err.Error(), "cache.capacity should be positive")
+		assert.Contains(t, err.Error(), "kafka.dead_letter_topic should differ from kafka.topic")
+		assert.Contains(t, err.Error(), "kafka.status_topic should differ from kafka.topic and kafka.dead_letter_topic")
+		assert.Contains(t, err.Error(), `kafka.message_key should be one of order_uid, customer_id, got "track_number"`)
+		assert.Contains(t, err.Error(), "kafka.workers should be positive")
+		assert.Contains(t, err.Error(), "kafka.retry.jitter should be from 0 to 1")
+		assert.Contains(t, err.Error(), `repository.conflict_policy should be one of reject, skip, overwrite, got "ignore"`)
+		assert.Contains(t, err.Error(), "cache.local.ttl should be positive")
+	})
+
+	t.Run("Malformed env value", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("KAFKA_RETRY_BASE_BACKOFF", "soon")
+
+		_, err := Load(nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `env KAFKA_RETRY_BASE_BACKOFF: "soon" is not a duration`)
+	})
+
+	t.Run("Unknown file field", func(t *testing.T) {
+		clearEnv(t)
+		path := writeConfig(t, "cache:\n  size: 10\n")
+
+		_, err := Load([]string{"-config", path})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "field size not found")
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		clearEnv(t)
+
+		_, err := Load([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")})
+		assert.Error(t, err)
+	})
+}
@@ -7,7 +7,57 @@ package database
 
 import (
 	"context"
+
+	"github.com/lib/pq"
+)
+
+const createDeliveries = `-- name: CreateDeliveries :exec
+INSERT INTO delivery (
+    order_uid, 
+    name,
+    phone,
+    zip,
+    city,
+    address,
+    region,
+    email
+)
+SELECT * FROM unnest(
+    $1::text[],
+    $2::text[],
+    $3::text[],
+    $4::text[],
+    $5::text[],
+    $6::text[],
+    $7::text[],
+    $8::text[]
 )
+`
+
+type CreateDeliveriesParams struct {
+	OrderUids []string
+	Names     []string
+	Phones    []string
+	Zips      []string
+	Cities    []string
+	Addresses []string
+	Regions   []string
+	Emails    []string
+}
+
+func (q *Queries) CreateDeliveries(ctx context.Context, arg CreateDeliveriesParams) error {
+	_, err := q.db.ExecContext(ctx, createDeliveries,
+		pq.Array(arg.OrderUids),
+		pq.Array(arg.Names),
+		pq.Array(arg.Phones),
+		pq.Array(arg.Zips),
+		pq.Array(arg.Cities),
+		pq.Array(arg.Addresses),
+		pq.Array(arg.Regions),
+		pq.Array(arg.Emails),
+	)
+	return err
+}
 
 const createDelivery = `-- name: CreateDelivery :exec
 INSERT INTO delivery (
@@ -49,12 +99,12 @@ func (q *Queries) CreateDelivery(ctx context.Context, arg CreateDeliveryParams)
 	return err
 }
 
-const getDelivery = `-- name: GetDelivery :many
-SELECT order_uid, name, phone, zip, city, address, region, email FROM delivery
+const getDeliveriesByOrderUIDs = `-- name: GetDeliveriesByOrderUIDs :many
+SELECT order_uid, name, phone, zip, city, address, region, email FROM delivery WHERE order_uid = ANY($1::text[])
 `
 
-func (q *Queries) GetDelivery(ctx context.Context) ([]Delivery, error) {
-	rows, err := q.db.QueryContext(ctx, getDelivery)
+func (q *Queries) GetDeliveriesByOrderUIDs(ctx context.Context, orderUids []string) ([]Delivery, error) {
+	rows, err := q.db.QueryContext(ctx, getDeliveriesByOrderUIDs, pq.Array(orderUids))
 	if err != nil {
 		return nil, err
 	}
@@ -104,3 +154,50 @@ func (q *Queries) GetSpecificDelivery(ctx context.Context, orderUid string) (Del
 	)
 	return i, err
 }
+
+const upsertDelivery = `-- name: UpsertDelivery :exec
+INSERT INTO delivery (
+    order_uid, 
+    name,
+    phone,
+    zip,
+    city,
+    address,
+    region,
+    email
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (order_uid) DO UPDATE SET
+    name = EXCLUDED.name,
+    phone = EXCLUDED.phone,
+    zip = EXCLUDED.zip,
+    city = EXCLUDED.city,
+    address = EXCLUDED.address,
+    region = EXCLUDED.region,
+    email = EXCLUDED.email
+`
+
+type UpsertDeliveryParams struct {
+	OrderUid string
+	Name     string
+	Phone    string
+	Zip      string
+	City     string
+	Address  string
+	Region   string
+	Email    string
+}
+
+func (q *Queries) UpsertDelivery(ctx context.Context, arg UpsertDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, upsertDelivery,
+		arg.OrderUid,
+		arg.Name,
+		arg.Phone,
+		arg.Zip,
+		arg.City,
+		arg.Address,
+		arg.Region,
+		arg.Email,
+	)
+	return err
+}
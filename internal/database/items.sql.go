@@ -7,6 +7,8 @@ package database
 
 import (
 	"context"
+
+	"github.com/lib/pq"
 )
 
 const createItem = `-- name: CreateItem :exec
@@ -61,12 +63,91 @@ func (q *Queries) CreateItem(ctx context.Context, arg CreateItemParams) error {
 	return err
 }
 
-const getItems = `-- name: GetItems :many
-SELECT item_id, order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM items
+const createItems = `-- name: CreateItems :exec
+INSERT INTO items (
+    order_uid, 
+    chrt_id,
+    track_number,
+    price,
+    rid,
+    name,
+    sale,
+    size,
+    total_price,
+    nm_id,
+    brand,
+    status
+)
+SELECT * FROM unnest(
+    $1::text[],
+    $2::int[],
+    $3::text[],
+    $4::int[],
+    $5::text[],
+    $6::text[],
+    $7::int[],
+    $8::text[],
+    $9::int[],
+    $10::int[],
+    $11::text[],
+    $12::int[]
+)
 `
 
-func (q *Queries) GetItems(ctx context.Context) ([]Item, error) {
-	rows, err := q.db.QueryContext(ctx, getItems)
+type CreateItemsParams struct {
+	OrderUids    []string
+	ChrtIds      []int32
+	TrackNumbers []string
+	Prices       []int32
+	Rids         []string
+	Names        []string
+	Sales        []int32
+	Sizes        []string
+	TotalPrices  []int32
+	NmIds        []int32
+	Brands       []string
+	Statuses     []int32
+}
+
+func (q *Queries) CreateItems(ctx context.Context, arg CreateItemsParams) error {
+	_, err := q.db.ExecContext(ctx, createItems,
+		pq.Array(arg.OrderUids),
+		pq.Array(arg.ChrtIds),
+		pq.Array(arg.TrackNumbers),
+		pq.Array(arg.Prices),
+		pq.Array(arg.Rids),
+		pq.Array(arg.Names),
+		pq.Array(arg.Sales),
+		pq.Array(arg.Sizes),
+		pq.Array(arg.TotalPrices),
+		pq.Array(arg.NmIds),
+		pq.Array(arg.Brands),
+		pq.Array(arg.Statuses),
+	)
+	return err
+}
+
+const deleteStaleItems = `-- name: DeleteStaleItems :exec
+DELETE FROM items
+WHERE order_uid = $1 AND NOT (rid = ANY($2::text[]))
+`
+
+type DeleteStaleItemsParams struct {
+	OrderUid string
+	Rids     []string
+}
+
+func (q *Queries) DeleteStaleItems(ctx context.Context, arg DeleteStaleItemsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteStaleItems, arg.OrderUid, pq.Array(arg.Rids))
+	return err
+}
+
+const getItemsByOrderUIDs = `-- name: GetItemsByOrderUIDs :many
+SELECT item_id, order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM items WHERE order_uid = ANY($1::text[]) ORDER BY item_id
+`
+
+func (q *Queries) GetItemsByOrderUIDs(ctx context.Context, orderUids []string) ([]Item, error) {
+	rows, err := q.db.QueryContext(ctx, getItemsByOrderUIDs, pq.Array(orderUids))
 	if err != nil {
 		return nil, err
 	}
@@ -142,3 +223,65 @@ func (q *Queries) GetSpecificItems(ctx context.Context, orderUid string) ([]Item
 	}
 	return items, nil
 }
+
+const upsertItem = `-- name: UpsertItem :exec
+INSERT INTO items (
+    order_uid, 
+    chrt_id,
+    track_number,
+    price,
+    rid,
+    name,
+    sale,
+    size,
+    total_price,
+    nm_id,
+    brand,
+    status
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (order_uid, rid) DO UPDATE SET
+    chrt_id = EXCLUDED.chrt_id,
+    track_number = EXCLUDED.track_number,
+    price = EXCLUDED.price,
+    name = EXCLUDED.name,
+    sale = EXCLUDED.sale,
+    size = EXCLUDED.size,
+    total_price = EXCLUDED.total_price,
+    nm_id = EXCLUDED.nm_id,
+    brand = EXCLUDED.brand,
+    status = EXCLUDED.status
+`
+
+type UpsertItemParams struct {
+	OrderUid    string
+	ChrtID      int32
+	TrackNumber string
+	Price       int32
+	Rid         string
+	Name        string
+	Sale        int32
+	Size        string
+	TotalPrice  int32
+	NmID        int32
+	Brand       string
+	Status      int32
+}
+
+func (q *Queries) UpsertItem(ctx context.Context, arg UpsertItemParams) error {
+	_, err := q.db.ExecContext(ctx, upsertItem,
+		arg.OrderUid,
+		arg.ChrtID,
+		arg.TrackNumber,
+		arg.Price,
+		arg.Rid,
+		arg.Name,
+		arg.Sale,
+		arg.Size,
+		arg.TotalPrice,
+		arg.NmID,
+		arg.Brand,
+		arg.Status,
+	)
+	return err
+}
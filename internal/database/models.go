@@ -48,6 +48,16 @@ type Order struct {
 	SmID              int32
 	DateCreated       time.Time
 	OofShard          string
+	Status            string
+}
+
+type OrderStatusHistory struct {
+	ID         int64
+	OrderUid   string
+	FromStatus sql.NullString
+	ToStatus   string
+	Reason     sql.NullString
+	ChangedAt  time.Time
 }
 
 type Payment struct {
@@ -8,7 +8,10 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 const createOrder = `-- name: CreateOrder :exec
@@ -26,7 +29,7 @@ INSERT INTO orders (
     oof_shard
 )
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-RETURNING order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+RETURNING order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status
 `
 
 type CreateOrderParams struct {
@@ -60,6 +63,189 @@ func (q *Queries) CreateOrder(ctx context.Context, arg CreateOrderParams) error
 	return err
 }
 
+const getCustomerSpending = `-- name: GetCustomerSpending :many
+SELECT p.currency, SUM(p.amount)::bigint AS total
+FROM orders o
+JOIN payments p ON p.order_uid = o.order_uid
+WHERE o.customer_id = $1
+GROUP BY p.currency
+ORDER BY p.currency
+`
+
+type GetCustomerSpendingRow struct {
+	Currency string
+	Total    int64
+}
+
+func (q *Queries) GetCustomerSpending(ctx context.Context, customerID string) ([]GetCustomerSpendingRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCustomerSpending, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCustomerSpendingRow
+	for rows.Next() {
+		var i GetCustomerSpendingRow
+		if err := rows.Scan(&i.Currency, &i.Total); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCustomerSummary = `-- name: GetCustomerSummary :one
+SELECT
+    customer_id,
+    COUNT(*) AS orders_count,
+    MIN(date_created)::timestamptz AS first_order_at,
+    MAX(date_created)::timestamptz AS last_order_at,
+    (
+        SELECT f.delivery_service FROM orders f
+        WHERE f.customer_id = o.customer_id
+        GROUP BY f.delivery_service
+        ORDER BY COUNT(*) DESC, f.delivery_service
+        LIMIT 1
+    )::text AS favourite_delivery_service
+FROM orders o
+WHERE o.customer_id = $1
+GROUP BY o.customer_id
+`
+
+type GetCustomerSummaryRow struct {
+	CustomerID               string
+	OrdersCount              int64
+	FirstOrderAt             time.Time
+	LastOrderAt              time.Time
+	FavouriteDeliveryService string
+}
+
+func (q *Queries) GetCustomerSummary(ctx context.Context, customerID string) (GetCustomerSummaryRow, error) {
+	row := q.db.QueryRowContext(ctx, getCustomerSummary, customerID)
+	var i GetCustomerSummaryRow
+	err := row.Scan(
+		&i.CustomerID,
+		&i.OrdersCount,
+		&i.FirstOrderAt,
+		&i.LastOrderAt,
+		&i.FavouriteDeliveryService,
+	)
+	return i, err
+}
+
+const getFullOrder = `-- name: GetFullOrder :one
+SELECT
+    o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status,
+    to_jsonb(d)::jsonb AS delivery,
+    to_jsonb(p)::jsonb AS payment,
+    COALESCE(
+        (SELECT jsonb_agg(to_jsonb(i) ORDER BY i.item_id) FROM items i WHERE i.order_uid = o.order_uid),
+        '[]'
+    )::jsonb AS items
+FROM orders o
+JOIN delivery d ON d.order_uid = o.order_uid
+JOIN payments p ON p.order_uid = o.order_uid
+WHERE o.order_uid = $1
+`
+
+type GetFullOrderRow struct {
+	Order    Order
+	Delivery json.RawMessage
+	Payment  json.RawMessage
+	Items    json.RawMessage
+}
+
+func (q *Queries) GetFullOrder(ctx context.Context, orderUid string) (GetFullOrderRow, error) {
+	row := q.db.QueryRowContext(ctx, getFullOrder, orderUid)
+	var i GetFullOrderRow
+	err := row.Scan(
+		&i.Order.OrderUid,
+		&i.Order.TrackNumber,
+		&i.Order.Entry,
+		&i.Order.Locale,
+		&i.Order.InternalSignature,
+		&i.Order.CustomerID,
+		&i.Order.DeliveryService,
+		&i.Order.Shardkey,
+		&i.Order.SmID,
+		&i.Order.DateCreated,
+		&i.Order.OofShard,
+		&i.Order.Status,
+		&i.Delivery,
+		&i.Payment,
+		&i.Items,
+	)
+	return i, err
+}
+
+const getFullOrders = `-- name: GetFullOrders :many
+SELECT
+    o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status,
+    to_jsonb(d)::jsonb AS delivery,
+    to_jsonb(p)::jsonb AS payment,
+    COALESCE(
+        (SELECT jsonb_agg(to_jsonb(i) ORDER BY i.item_id) FROM items i WHERE i.order_uid = o.order_uid),
+        '[]'
+    )::jsonb AS items
+FROM orders o
+JOIN delivery d ON d.order_uid = o.order_uid
+JOIN payments p ON p.order_uid = o.order_uid
+WHERE o.order_uid = ANY($1::text[])
+ORDER BY o.date_created DESC, o.order_uid DESC
+`
+
+type GetFullOrdersRow struct {
+	Order    Order
+	Delivery json.RawMessage
+	Payment  json.RawMessage
+	Items    json.RawMessage
+}
+
+func (q *Queries) GetFullOrders(ctx context.Context, orderUids []string) ([]GetFullOrdersRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFullOrders, pq.Array(orderUids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFullOrdersRow
+	for rows.Next() {
+		var i GetFullOrdersRow
+		if err := rows.Scan(
+			&i.Order.OrderUid,
+			&i.Order.TrackNumber,
+			&i.Order.Entry,
+			&i.Order.Locale,
+			&i.Order.InternalSignature,
+			&i.Order.CustomerID,
+			&i.Order.DeliveryService,
+			&i.Order.Shardkey,
+			&i.Order.SmID,
+			&i.Order.DateCreated,
+			&i.Order.OofShard,
+			&i.Order.Status,
+			&i.Delivery,
+			&i.Payment,
+			&i.Items,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getLatestOrders = `-- name: GetLatestOrders :many
 SELECT order_uid FROM orders ORDER BY date_created DESC LIMIT $1
 `
@@ -87,35 +273,31 @@ func (q *Queries) GetLatestOrders(ctx context.Context, limit int32) ([]string, e
 	return items, nil
 }
 
-const getOrders = `-- name: GetOrders :many
-SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard FROM orders
+const getOrderUIDsByTrackNumber = `-- name: GetOrderUIDsByTrackNumber :many
+SELECT order_uid FROM orders
+WHERE track_number = $1
+ORDER BY date_created DESC, order_uid DESC
+LIMIT $2
 `
 
-func (q *Queries) GetOrders(ctx context.Context) ([]Order, error) {
-	rows, err := q.db.QueryContext(ctx, getOrders)
+type GetOrderUIDsByTrackNumberParams struct {
+	TrackNumber string
+	Limit       int32
+}
+
+func (q *Queries) GetOrderUIDsByTrackNumber(ctx context.Context, arg GetOrderUIDsByTrackNumberParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getOrderUIDsByTrackNumber, arg.TrackNumber, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Order
+	var items []string
 	for rows.Next() {
-		var i Order
-		if err := rows.Scan(
-			&i.OrderUid,
-			&i.TrackNumber,
-			&i.Entry,
-			&i.Locale,
-			&i.InternalSignature,
-			&i.CustomerID,
-			&i.DeliveryService,
-			&i.Shardkey,
-			&i.SmID,
-			&i.DateCreated,
-			&i.OofShard,
-		); err != nil {
+		var order_uid string
+		if err := rows.Scan(&order_uid); err != nil {
 			return nil, err
 		}
-		items = append(items, i)
+		items = append(items, order_uid)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -127,7 +309,7 @@ func (q *Queries) GetOrders(ctx context.Context) ([]Order, error) {
 }
 
 const getSpecificOrder = `-- name: GetSpecificOrder :one
-SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard FROM orders WHERE order_uid = $1
+SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status FROM orders WHERE order_uid = $1
 `
 
 func (q *Queries) GetSpecificOrder(ctx context.Context, orderUid string) (Order, error) {
@@ -145,6 +327,302 @@ func (q *Queries) GetSpecificOrder(ctx context.Context, orderUid string) (Order,
 		&i.SmID,
 		&i.DateCreated,
 		&i.OofShard,
+		&i.Status,
 	)
 	return i, err
 }
+
+const insertOrderIfNotExists = `-- name: InsertOrderIfNotExists :execrows
+INSERT INTO orders (
+    order_uid, 
+    track_number,
+    entry,
+    locale,
+    internal_signature,
+    customer_id,
+    delivery_service,
+    shardkey,
+    sm_id,
+    date_created,
+    oof_shard
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (order_uid) DO NOTHING
+`
+
+type InsertOrderIfNotExistsParams struct {
+	OrderUid          string
+	TrackNumber       string
+	Entry             string
+	Locale            string
+	InternalSignature sql.NullString
+	CustomerID        string
+	DeliveryService   string
+	Shardkey          string
+	SmID              int32
+	DateCreated       time.Time
+	OofShard          string
+}
+
+func (q *Queries) InsertOrderIfNotExists(ctx context.Context, arg InsertOrderIfNotExistsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertOrderIfNotExists,
+		arg.OrderUid,
+		arg.TrackNumber,
+		arg.Entry,
+		arg.Locale,
+		arg.InternalSignature,
+		arg.CustomerID,
+		arg.DeliveryService,
+		arg.Shardkey,
+		arg.SmID,
+		arg.DateCreated,
+		arg.OofShard,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const insertOrdersIfNotExist = `-- name: InsertOrdersIfNotExist :many
+INSERT INTO orders (
+    order_uid, 
+    track_number,
+    entry,
+    locale,
+    internal_signature,
+    customer_id,
+    delivery_service,
+    shardkey,
+    sm_id,
+    date_created,
+    oof_shard
+)
+SELECT
+    u.order_uid,
+    u.track_number,
+    u.entry,
+    u.locale,
+    NULLIF(u.internal_signature, ''),
+    u.customer_id,
+    u.delivery_service,
+    u.shardkey,
+    u.sm_id,
+    u.date_created::timestamptz,
+    u.oof_shard
+FROM unnest(
+    $1::text[],
+    $2::text[],
+    $3::text[],
+    $4::text[],
+    $5::text[],
+    $6::text[],
+    $7::text[],
+    $8::text[],
+    $9::int[],
+    $10::text[],
+    $11::text[]
+) AS u(order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
+ON CONFLICT (order_uid) DO NOTHING
+RETURNING order_uid
+`
+
+type InsertOrdersIfNotExistParams struct {
+	OrderUids          []string
+	TrackNumbers       []string
+	Entries            []string
+	Locales            []string
+	InternalSignatures []string
+	CustomerIds        []string
+	DeliveryServices   []string
+	Shardkeys          []string
+	SmIds              []int32
+	DatesCreated       []string
+	OofShards          []string
+}
+
+// Даты передаются текстом в RFC 3339: так массив не зависит от того,
+// как драйвер записывает время
+func (q *Queries) InsertOrdersIfNotExist(ctx context.Context, arg InsertOrdersIfNotExistParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, insertOrdersIfNotExist,
+		pq.Array(arg.OrderUids),
+		pq.Array(arg.TrackNumbers),
+		pq.Array(arg.Entries),
+		pq.Array(arg.Locales),
+		pq.Array(arg.InternalSignatures),
+		pq.Array(arg.CustomerIds),
+		pq.Array(arg.DeliveryServices),
+		pq.Array(arg.Shardkeys),
+		pq.Array(arg.SmIds),
+		pq.Array(arg.DatesCreated),
+		pq.Array(arg.OofShards),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var order_uid string
+		if err := rows.Scan(&order_uid); err != nil {
+			return nil, err
+		}
+		items = append(items, order_uid)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrders = `-- name: ListOrders :many
+SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status FROM orders o
+JOIN payments p ON p.order_uid = o.order_uid
+WHERE ($1::text IS NULL OR o.customer_id = $1)
+    AND ($2::text IS NULL OR o.track_number = $2)
+    AND ($3::text IS NULL OR o.delivery_service = $3)
+    AND ($4::text IS NULL OR o.locale = $4)
+    AND ($5::text IS NULL OR o.entry = $5)
+    AND ($6::text IS NULL OR p.currency = $6)
+    AND ($7::text IS NULL OR p.provider = $7)
+    AND ($8::text IS NULL OR p.bank = $8)
+    AND ($9::timestamptz IS NULL OR o.date_created >= $9)
+    AND ($10::timestamptz IS NULL OR o.date_created < $10)
+    AND (
+        $11::timestamptz IS NULL
+        OR (o.date_created, o.order_uid) < ($11, $12::text)
+    )
+ORDER BY o.date_created DESC, o.order_uid DESC
+LIMIT $13
+`
+
+type ListOrdersParams struct {
+	CustomerID      sql.NullString
+	TrackNumber     sql.NullString
+	DeliveryService sql.NullString
+	Locale          sql.NullString
+	Entry           sql.NullString
+	Currency        sql.NullString
+	Provider        sql.NullString
+	Bank            sql.NullString
+	CreatedFrom     sql.NullTime
+	CreatedTo       sql.NullTime
+	CursorDate      sql.NullTime
+	CursorUid       sql.NullString
+	PageLimit       int32
+}
+
+func (q *Queries) ListOrders(ctx context.Context, arg ListOrdersParams) ([]Order, error) {
+	rows, err := q.db.QueryContext(ctx, listOrders,
+		arg.CustomerID,
+		arg.TrackNumber,
+		arg.DeliveryService,
+		arg.Locale,
+		arg.Entry,
+		arg.Currency,
+		arg.Provider,
+		arg.Bank,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.CursorDate,
+		arg.CursorUid,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Order
+	for rows.Next() {
+		var i Order
+		if err := rows.Scan(
+			&i.OrderUid,
+			&i.TrackNumber,
+			&i.Entry,
+			&i.Locale,
+			&i.InternalSignature,
+			&i.CustomerID,
+			&i.DeliveryService,
+			&i.Shardkey,
+			&i.SmID,
+			&i.DateCreated,
+			&i.OofShard,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertOrder = `-- name: UpsertOrder :one
+INSERT INTO orders (
+    order_uid, 
+    track_number,
+    entry,
+    locale,
+    internal_signature,
+    customer_id,
+    delivery_service,
+    shardkey,
+    sm_id,
+    date_created,
+    oof_shard
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (order_uid) DO UPDATE SET
+    track_number = EXCLUDED.track_number,
+    entry = EXCLUDED.entry,
+    locale = EXCLUDED.locale,
+    internal_signature = EXCLUDED.internal_signature,
+    customer_id = EXCLUDED.customer_id,
+    delivery_service = EXCLUDED.delivery_service,
+    shardkey = EXCLUDED.shardkey,
+    sm_id = EXCLUDED.sm_id,
+    date_created = EXCLUDED.date_created,
+    oof_shard = EXCLUDED.oof_shard
+RETURNING status
+`
+
+type UpsertOrderParams struct {
+	OrderUid          string
+	TrackNumber       string
+	Entry             string
+	Locale            string
+	InternalSignature sql.NullString
+	CustomerID        string
+	DeliveryService   string
+	Shardkey          string
+	SmID              int32
+	DateCreated       time.Time
+	OofShard          string
+}
+
+func (q *Queries) UpsertOrder(ctx context.Context, arg UpsertOrderParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, upsertOrder,
+		arg.OrderUid,
+		arg.TrackNumber,
+		arg.Entry,
+		arg.Locale,
+		arg.InternalSignature,
+		arg.CustomerID,
+		arg.DeliveryService,
+		arg.Shardkey,
+		arg.SmID,
+		arg.DateCreated,
+		arg.OofShard,
+	)
+	var status string
+	err := row.Scan(&status)
+	return status, err
+}
@@ -8,6 +8,8 @@ package database
 import (
 	"context"
 	"database/sql"
+
+	"github.com/lib/pq"
 )
 
 const createPayment = `-- name: CreatePayment :exec
@@ -59,12 +61,120 @@ func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) er
 	return err
 }
 
-const getPayment = `-- name: GetPayment :many
-SELECT order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee FROM payments
+const createPayments = `-- name: CreatePayments :exec
+INSERT INTO payments (
+    order_uid, 
+    transaction,
+    request_id,
+    currency,
+    provider,
+    amount,
+    payment_dt,
+    bank,
+    delivery_cost,
+    goods_total,
+    custom_fee
+)
+SELECT
+    u.order_uid,
+    u.transaction,
+    NULLIF(u.request_id, ''),
+    u.currency,
+    u.provider,
+    u.amount,
+    u.payment_dt,
+    u.bank,
+    u.delivery_cost,
+    u.goods_total,
+    u.custom_fee
+FROM unnest(
+    $1::text[],
+    $2::text[],
+    $3::text[],
+    $4::text[],
+    $5::text[],
+    $6::int[],
+    $7::bigint[],
+    $8::text[],
+    $9::int[],
+    $10::int[],
+    $11::int[]
+) AS u(order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+`
+
+type CreatePaymentsParams struct {
+	OrderUids     []string
+	Transactions  []string
+	RequestIds    []string
+	Currencies    []string
+	Providers     []string
+	Amounts       []int32
+	PaymentDts    []int64
+	Banks         []string
+	DeliveryCosts []int32
+	GoodsTotals   []int32
+	CustomFees    []int32
+}
+
+func (q *Queries) CreatePayments(ctx context.Context, arg CreatePaymentsParams) error {
+	_, err := q.db.ExecContext(ctx, createPayments,
+		pq.Array(arg.OrderUids),
+		pq.Array(arg.Transactions),
+		pq.Array(arg.RequestIds),
+		pq.Array(arg.Currencies),
+		pq.Array(arg.Providers),
+		pq.Array(arg.Amounts),
+		pq.Array(arg.PaymentDts),
+		pq.Array(arg.Banks),
+		pq.Array(arg.DeliveryCosts),
+		pq.Array(arg.GoodsTotals),
+		pq.Array(arg.CustomFees),
+	)
+	return err
+}
+
+const getOrderUIDsByTransaction = `-- name: GetOrderUIDsByTransaction :many
+SELECT p.order_uid FROM payments p
+JOIN orders o ON o.order_uid = p.order_uid
+WHERE p.transaction = $1
+ORDER BY o.date_created DESC, o.order_uid DESC
+LIMIT $2
 `
 
-func (q *Queries) GetPayment(ctx context.Context) ([]Payment, error) {
-	rows, err := q.db.QueryContext(ctx, getPayment)
+type GetOrderUIDsByTransactionParams struct {
+	Transaction string
+	Limit       int32
+}
+
+func (q *Queries) GetOrderUIDsByTransaction(ctx context.Context, arg GetOrderUIDsByTransactionParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getOrderUIDsByTransaction, arg.Transaction, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var order_uid string
+		if err := rows.Scan(&order_uid); err != nil {
+			return nil, err
+		}
+		items = append(items, order_uid)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPaymentsByOrderUIDs = `-- name: GetPaymentsByOrderUIDs :many
+SELECT order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee FROM payments WHERE order_uid = ANY($1::text[])
+`
+
+func (q *Queries) GetPaymentsByOrderUIDs(ctx context.Context, orderUids []string) ([]Payment, error) {
+	rows, err := q.db.QueryContext(ctx, getPaymentsByOrderUIDs, pq.Array(orderUids))
 	if err != nil {
 		return nil, err
 	}
@@ -120,3 +230,62 @@ func (q *Queries) GetSpecificPayment(ctx context.Context, orderUid string) (Paym
 	)
 	return i, err
 }
+
+const upsertPayment = `-- name: UpsertPayment :exec
+INSERT INTO payments (
+    order_uid, 
+    transaction,
+    request_id,
+    currency,
+    provider,
+    amount,
+    payment_dt,
+    bank,
+    delivery_cost,
+    goods_total,
+    custom_fee
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (order_uid) DO UPDATE SET
+    transaction = EXCLUDED.transaction,
+    request_id = EXCLUDED.request_id,
+    currency = EXCLUDED.currency,
+    provider = EXCLUDED.provider,
+    amount = EXCLUDED.amount,
+    payment_dt = EXCLUDED.payment_dt,
+    bank = EXCLUDED.bank,
+    delivery_cost = EXCLUDED.delivery_cost,
+    goods_total = EXCLUDED.goods_total,
+    custom_fee = EXCLUDED.custom_fee
+`
+
+type UpsertPaymentParams struct {
+	OrderUid     string
+	Transaction  string
+	RequestID    sql.NullString
+	Currency     string
+	Provider     string
+	Amount       int32
+	PaymentDt    int64
+	Bank         string
+	DeliveryCost int32
+	GoodsTotal   int32
+	CustomFee    int32
+}
+
+func (q *Queries) UpsertPayment(ctx context.Context, arg UpsertPaymentParams) error {
+	_, err := q.db.ExecContext(ctx, upsertPayment,
+		arg.OrderUid,
+		arg.Transaction,
+		arg.RequestID,
+		arg.Currency,
+		arg.Provider,
+		arg.Amount,
+		arg.PaymentDt,
+		arg.Bank,
+		arg.DeliveryCost,
+		arg.GoodsTotal,
+		arg.CustomFee,
+	)
+	return err
+}
@@ -5,7 +5,14 @@ package dependencies
 //go:generate mockgen -source=../kafka/interfaces.go -destination=../mocks/kafka_mock.go -package=mocks
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+
+	"orders/internal/config"
+	"orders/internal/logging"
+	"orders/internal/migrations"
+	"orders/internal/validation"
 
 	c "orders/internal/cache"
 	k "orders/internal/kafka"
@@ -13,35 +20,87 @@ import (
 )
 
 type Dependencies struct {
-	KafkaConsumer k.MessagesConsumer
-	KafkaProducer k.MessagesProducer
-	Repo          r.OrdersRepository
-	Cache         c.OrdersCache
+	Config          *config.Config
+	Logger          *slog.Logger
+	KafkaConsumer   k.MessagesConsumer
+	KafkaProducer   k.MessagesProducer
+	DeadLetterQueue k.MessagesProducer
+	StatusProducer  k.MessagesProducer
+	Repo            r.OrdersRepository
+	Cache           c.OrdersCache
+	Validator       *validation.Policies
 }
 
-func InitDependencies(driverName, dataSourceName, redisURL string) (*Dependencies, error) {
-	cache, err := c.NewCache(redisURL)
+func InitDependencies(cfg *config.Config, logger *slog.Logger) (*Dependencies, error) {
+	txScope, err := r.ParseTxScope(cfg.Repository.TxScope)
+	if err != nil {
+		return nil, fmt.Errorf("Error configuring repository: %w", err)
+	}
+
+	conflictPolicy, err := r.ParseConflictPolicy(cfg.Repository.ConflictPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("Error configuring repository: %w", err)
+	}
+
+	// Некорректная политика валидации не дает запустить сервис, как и ошибки в конфигурации
+	validator, err := validation.NewPolicies(cfg.Validation.PolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading validation policy: %w", err)
+	}
+
+	redisCache, err := c.NewCache(cfg.Redis.URL, cfg.Cache)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating new cache: %w", err)
 	}
 
-	repo, err := r.NewRepository(driverName, dataSourceName, cache)
+	var cache c.OrdersCache = redisCache
+	if cfg.Cache.Local.Enabled {
+		cache, err = c.NewTieredCache(context.Background(), redisCache, cfg.Cache.Local)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating local cache: %w", err)
+		}
+	}
+
+	repo, err := r.NewRepository(cfg.Postgres.Driver, cfg.Postgres.URL, cache)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating new repository: %w", err)
 	}
+	repo.TxScope = txScope
+	repo.ConflictPolicy = conflictPolicy
+	repo.NotFoundTTL = cfg.Repository.NotFoundTTL
+
+	// Миграции применяются под блокировкой в бд, поэтому экземпляры сервиса,
+	// запущенные одновременно, не мешают друг другу
+	if cfg.Postgres.AutoMigrate {
+		migrator, err := migrations.New(repo.DB)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading migrations: %w", err)
+		}
+		err = migrator.Up(logging.WithLogger(context.Background(), logger))
+		if err != nil {
+			return nil, fmt.Errorf("Error applying migrations: %w", err)
+		}
+	}
 
-	err = k.CreateTopic()
+	err = k.CreateTopic(cfg.Kafka)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating Kafka topic: %w", err)
 	}
 
-	reader := k.CreateReader()
-	writer := k.CreateWriter()
+	reader := k.CreateReader(cfg.Kafka)
+	writer := k.CreateWriter(cfg.Kafka)
+	deadLetterWriter := k.CreateDeadLetterWriter(cfg.Kafka)
+	statusWriter := k.CreateStatusWriter(cfg.Kafka)
 
 	return &Dependencies{
-		KafkaConsumer: reader,
-		KafkaProducer: writer,
-		Repo:          repo,
-		Cache:         cache,
+		Config:          cfg,
+		Logger:          logger,
+		KafkaConsumer:   reader,
+		KafkaProducer:   writer,
+		DeadLetterQueue: deadLetterWriter,
+		StatusProducer:  statusWriter,
+		Repo:            repo,
+		Cache:           cache,
+		Validator:       validator,
 	}, nil
 }
@@ -1,6 +1,9 @@
 package generator
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Order struct {
 	OrderUID          string    `json:"order_uid" db:"order_uid"`
@@ -16,5 +19,28 @@ type Order struct {
 	Shardkey          string    `json:"shardkey" db:"shardkey"`
 	SmID              int       `json:"sm_id" db:"sm_id"`
 	DateCreated       time.Time `json:"date_created" db:"date_created"`
-	OofShard          string    `json:"status" db:"status"`
+	OofShard          string    `json:"oof_shard" db:"oof_shard"`
+	// Status заполняется сервисом: новые заказы сохраняются со статусом created
+	Status string `json:"status" db:"status"`
+}
+
+// UnmarshalJSON читает и заказы прежнего формата, в котором статусов еще не было,
+// а значение oof_shard передавалось в поле status. Заказ без поля oof_shard
+// считается заказом прежнего формата, как и в консьюмере Kafka
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type order Order
+	var v struct {
+		order
+		OofShard json.RawMessage `json:"oof_shard"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*o = Order(v.order)
+	if v.OofShard == nil {
+		o.OofShard, o.Status = o.Status, ""
+		return nil
+	}
+	return json.Unmarshal(v.OofShard, &o.OofShard)
 }
@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Статусы проверок и сервиса в целом
+const (
+	StatusOK           string = "ok"
+	StatusFail         string = "fail"
+	StatusShuttingDown string = "shutting_down"
+)
+
+// Check проверяет доступность одной зависимости сервиса
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Result - итог проверки одной зависимости
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report - итог проверки готовности сервиса с разбивкой по зависимостям
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker выполняет проверки зависимостей для эндпоинта готовности.
+// После Shutdown сервис считается неготовым независимо от проверок
+type Checker struct {
+	timeout      time.Duration
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add регистрирует проверку зависимости под именем name
+func (h *Checker) Add(name string, check Check) {
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// Shutdown переводит сервис в состояние неготовности на время graceful shutdown
+func (h *Checker) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+func (h *Checker) ShuttingDown() bool {
+	return h.shuttingDown.Load()
+}
+
+// Run параллельно выполняет все проверки, ограничивая каждую таймаутом
+func (h *Checker) Run(ctx context.Context) Report {
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]Result, len(h.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runCheck(ctx, c.check, h.timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	if h.ShuttingDown() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, check Check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	latency := time.Since(start)
+
+	result := Result{
+		Status:    StatusOK,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
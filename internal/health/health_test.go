@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирует сбор результатов проверок зависимостей
+func TestCheckerRun(t *testing.T) {
+	t.Run("All checks pass", func(t *testing.T) {
+		h := NewChecker(time.Second)
+		h.Add("first", func(ctx context.Context) error { return nil })
+		h.Add("second", func(ctx context.Context) error { return nil })
+
+		report := h.Run(context.Background())
+		assert.Equal(t, StatusOK, report.Status)
+		require.Len(t, report.Checks, 2)
+		for name, result := range report.Checks {
+			assert.Equal(t, StatusOK, result.Status, "Check %s should pass", name)
+			assert.Empty(t, result.Error)
+		}
+	})
+
+	t.Run("One check fails", func(t *testing.T) {
+		h := NewChecker(time.Second)
+		h.Add("healthy", func(ctx context.Context) error { return nil })
+		h.Add("broken", func(ctx context.Context) error { return errors.New("connection refused") })
+
+		report := h.Run(context.Background())
+		assert.Equal(t, StatusFail, report.Status, "Failed dependency should make service not ready")
+		assert.Equal(t, StatusOK, report.Checks["healthy"].Status)
+		assert.Equal(t, StatusFail, report.Checks["broken"].Status)
+		assert.Equal(t, "connection refused", report.Checks["broken"].Error)
+	})
+
+	t.Run("Check exceeds timeout", func(t *testing.T) {
+		h := NewChecker(10 * time.Millisecond)
+		h.Add("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		report := h.Run(context.Background())
+		assert.Equal(t, StatusFail, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+		assert.GreaterOrEqual(t, report.Checks["slow"].LatencyMs, float64(10), "Latency should be measured")
+	})
+
+	t.Run("Shutting down", func(t *testing.T) {
+		h := NewChecker(time.Second)
+		h.Add("healthy", func(ctx context.Context) error { return nil })
+		h.Shutdown()
+
+		// Зависимости проверяются, но сервис уже не готов
+		report := h.Run(context.Background())
+		assert.Equal(t, StatusShuttingDown, report.Status)
+		assert.Equal(t, StatusOK, report.Checks["healthy"].Status)
+	})
+}
@@ -0,0 +1,192 @@
+package kafka
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"orders/internal/generator"
+	"orders/internal/logging"
+	"orders/internal/metrics"
+	"orders/internal/repository"
+	"orders/internal/validation"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// BatchPolicy описывает пакетную обработку сообщений: сообщения копятся,
+// пока их не наберется Size или не пройдет Timeout с получения первого из них
+type BatchPolicy struct {
+	Size    int
+	Timeout time.Duration
+}
+
+// Enabled сообщает, включена ли пакетная обработка
+func (b BatchPolicy) Enabled() bool {
+	return b.Size > 1
+}
+
+// StartConsumingBatches читает сообщения и обрабатывает их пачками: заказы пачки
+// сохраняются одной транзакцией, а смещения всех партиций коммитятся одним запросом.
+// Когда ридер закрыт, дообрабатывает уже полученные сообщения и только потом
+// возвращается, App.Close ждет этого, прежде чем закрыть базу и DLQ. Отмена ctx
+// прерывает повторы сообщений, которые не удается обработать
+func StartConsumingBatches(ctx context.Context, c MessagesConsumer, dlq MessagesProducer, repo repository.OrdersRepository, v validation.OrderValidator, retry RetryPolicy, batch BatchPolicy, state *ConsumerState, logger *slog.Logger) {
+	state.running.Store(true)
+	defer state.running.Store(false)
+
+	// FetchMessage блокируется до прихода сообщения, поэтому читаем отдельно,
+	// чтобы неполная пачка обработалась по истечении Timeout
+	messages := make(chan kafka.Message, batch.Size)
+	go func() {
+		defer close(messages)
+		fetchMessages(c, retry, state, logger, func(m kafka.Message) {
+			messages <- m
+		})
+	}()
+
+	pending := make([]kafka.Message, 0, batch.Size)
+	// Партиции, в которых сообщение осталось необработанным при остановке,
+	// больше не коммитятся: до остановки такие сообщения повторяются
+	failed := make(map[int]bool)
+	flush := func() {
+		if len(pending) > 0 {
+			handleBatch(ctx, c, dlq, repo, v, retry, pending, failed, state, logger)
+			pending = pending[:0]
+		}
+	}
+
+	timer := time.NewTimer(batch.Timeout)
+	timer.Stop()
+	for {
+		select {
+		case m, ok := <-messages:
+			// Ридер закрыт: дообрабатываем то, что уже получено
+			if !ok {
+				flush()
+				return
+			}
+			pending = append(pending, m)
+			if len(pending) == 1 {
+				timer.Reset(batch.Timeout)
+			}
+			if len(pending) >= batch.Size {
+				timer.Stop()
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// handleBatch обрабатывает пачку сообщений. Заказы всех сообщений сохраняются
+// одним BulkSaveToDB, а если пачку не удалось сохранить целиком, сообщения
+// сохраняются по одному с повторными попытками и DLQ, как без пакетной обработки.
+// Сообщения, которые не удалось ни сохранить, ни отправить в DLQ, повторяются
+// до коммита пачки, пока не будут обработаны или не отменен stop
+func handleBatch(stop context.Context, c MessagesConsumer, dlq MessagesProducer, repo repository.OrdersRepository, v validation.OrderValidator, retry RetryPolicy, msgs []kafka.Message, failed map[int]bool, state *ConsumerState, logger *slog.Logger) {
+	metrics.BatchMessages.Observe(float64(len(msgs)))
+
+	ctxs := make([]context.Context, len(msgs))
+	loggers := make([]*slog.Logger, len(msgs))
+	orders := make([][]*generator.Order, len(msgs))
+	ok := make([]bool, len(msgs))
+	// Ошибки сообщений с неудачей и то, как повторить их обработку
+	errs := make([]error, len(msgs))
+	retries := make([]func() error, len(msgs))
+
+	var all []*generator.Order
+	for i, m := range msgs {
+		ctxs[i], loggers[i] = messageContext(context.Background(), m, logger)
+
+		valid, err := prepareMessage(ctxs[i], m, dlq, v)
+		if err != nil {
+			metrics.MessagesFailed.WithLabelValues("dead_letter").Inc()
+			errs[i] = err
+			retries[i] = func() error {
+				return handleMessage(ctxs[i], m, dlq, repo, v, retry)
+			}
+			continue
+		}
+		ok[i] = true
+		orders[i] = valid
+		all = append(all, valid...)
+	}
+
+	batchLogger := logger.With("messages", len(msgs))
+	if len(all) > 0 {
+		err := repo.BulkSaveToDB(all, logging.WithLogger(context.Background(), batchLogger))
+		if err != nil {
+			batchLogger.Warn("Error saving batch, saving messages one by one", "orders", len(all), "error", err)
+			for i, m := range msgs {
+				if !ok[i] || len(orders[i]) == 0 {
+					continue
+				}
+				if err := persistOrders(ctxs[i], m, dlq, repo, orders[i], retry); err != nil {
+					ok[i] = false
+					metrics.MessagesFailed.WithLabelValues("dead_letter").Inc()
+					errs[i] = err
+					retries[i] = func() error {
+						return persistOrders(ctxs[i], m, dlq, repo, orders[i], retry)
+					}
+				}
+			}
+		} else {
+			batchLogger.Info("Saved batch", "orders", len(all))
+		}
+	}
+
+	for i, m := range msgs {
+		if ok[i] {
+			continue
+		}
+		if err := retryUntilHandled(stop, m, retry, state, loggers[i], errs[i], retries[i]); err != nil {
+			loggers[i].Error("Message is left uncommitted", "error", err)
+			continue
+		}
+		ok[i] = true
+	}
+
+	commitBatch(c, msgs, ok, failed, batchLogger)
+}
+
+// commitBatch коммитит последнее успешно обработанное сообщение каждой партиции.
+// Как и без пакетной обработки, коммит партиции останавливается на первом сообщении
+// с ошибкой: партиция попадает в failed, и следующие пачки ее тоже не коммитят.
+// Такое бывает только при остановке, когда повторы сообщения прерваны, и после
+// перезапуска партиция читается заново с этого сообщения
+func commitBatch(c MessagesConsumer, msgs []kafka.Message, ok []bool, failed map[int]bool, logger *slog.Logger) {
+	last := make(map[int]kafka.Message)
+	committed := 0
+	for i, m := range msgs {
+		if !ok[i] {
+			failed[m.Partition] = true
+		}
+		if failed[m.Partition] {
+			continue
+		}
+		committed++
+		if prev, seen := last[m.Partition]; !seen || m.Offset > prev.Offset {
+			last[m.Partition] = m
+		}
+	}
+	if committed == 0 {
+		return
+	}
+
+	commits := make([]kafka.Message, 0, len(last))
+	for _, m := range last {
+		commits = append(commits, m)
+	}
+	sort.Slice(commits, func(i, j int) bool { return commits[i].Partition < commits[j].Partition })
+
+	if err := c.CommitMessages(context.Background(), commits...); err != nil {
+		metrics.MessagesFailed.WithLabelValues("commit").Inc()
+		logger.Error("Error committing batch", "error", err)
+		return
+	}
+	metrics.MessagesCommitted.Add(float64(committed))
+	logger.Info("Committed batch", "committed", committed, "partitions", len(commits))
+}
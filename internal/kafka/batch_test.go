@@ -0,0 +1,306 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"orders/internal/generator"
+	"orders/internal/mocks"
+	"orders/internal/repository"
+	"orders/internal/validation"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// orderMessages возвращает сообщения с заказами orders, по очереди
+// раскладывая их по partitions партициям
+func orderMessages(t testing.TB, orders []*generator.Order, partitions int) []kafka.Message {
+	offsets := make(map[int]int64)
+	msgs := make([]kafka.Message, 0, len(orders))
+	for i, order := range orders {
+		value, err := json.Marshal(order)
+		require.NoError(t, err)
+
+		partition := i % partitions
+		msgs = append(msgs, kafka.Message{
+			Topic:     "orders",
+			Partition: partition,
+			Offset:    offsets[partition],
+			Key:       []byte(order.OrderUID),
+			Value:     value,
+		})
+		offsets[partition]++
+	}
+	return msgs
+}
+
+// withOrder проверяет, что сохраняется только заказ order
+func withOrder(order *generator.Order) gomock.Matcher {
+	return gomock.Cond(func(orders []*generator.Order) bool {
+		return len(orders) == 1 && orders[0].OrderUID == order.OrderUID
+	})
+}
+
+// Тестирует сохранение и коммит сообщений пачками
+func TestStartConsumingBatches(t *testing.T) {
+	t.Run("Full batches", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		msgs := orderMessages(t, generator.MakeRandomOrder(6), 2)
+		calls := make([]any, 0, len(msgs)+1)
+		for _, m := range msgs {
+			calls = append(calls, mockConsumer.EXPECT().FetchMessage(gomock.Any()).Return(m, nil))
+		}
+		calls = append(calls, mockConsumer.EXPECT().FetchMessage(gomock.Any()).Return(kafka.Message{}, io.EOF))
+		gomock.InOrder(calls...)
+
+		// Пачка из 4 сообщений, а остаток дообрабатывается при закрытии ридера
+		gomock.InOrder(
+			mockRepo.EXPECT().BulkSaveToDB(gomock.Len(4), gomock.Any()).Return(nil),
+			mockRepo.EXPECT().BulkSaveToDB(gomock.Len(2), gomock.Any()).Return(nil),
+		)
+		mockRepo.EXPECT().SaveToDB(gomock.Any(), gomock.Any()).Times(0)
+
+		// Коммитится по одному сообщению на партицию - последнему в пачке
+		gomock.InOrder(
+			mockConsumer.EXPECT().CommitMessages(gomock.Any(), msgs[2], msgs[3]).Return(nil),
+			mockConsumer.EXPECT().CommitMessages(gomock.Any(), msgs[4], msgs[5]).Return(nil),
+		)
+
+		batch := BatchPolicy{Size: 4, Timeout: time.Hour}
+		StartConsumingBatches(context.Background(), mockConsumer, mockDLQ, mockRepo, validation.Default, testRetryPolicy, batch, &ConsumerState{}, slog.Default())
+	})
+
+	t.Run("Batch timeout", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		msgs := orderMessages(t, generator.MakeRandomOrder(2), 1)
+		stop := make(chan struct{})
+		gomock.InOrder(
+			mockConsumer.EXPECT().FetchMessage(gomock.Any()).Return(msgs[0], nil),
+			mockConsumer.EXPECT().FetchMessage(gomock.Any()).Return(msgs[1], nil),
+			// Новых сообщений нет, пока тест не остановит ридер
+			mockConsumer.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (kafka.Message, error) {
+				<-stop
+				return kafka.Message{}, io.EOF
+			}),
+		)
+
+		committed := make(chan struct{})
+		mockRepo.EXPECT().BulkSaveToDB(gomock.Len(2), gomock.Any()).Return(nil)
+		mockConsumer.EXPECT().CommitMessages(gomock.Any(), msgs[1]).DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+			close(committed)
+			return nil
+		})
+
+		done := make(chan struct{})
+		go func() {
+			batch := BatchPolicy{Size: 100, Timeout: 20 * time.Millisecond}
+			StartConsumingBatches(context.Background(), mockConsumer, mockDLQ, mockRepo, validation.Default, testRetryPolicy, batch, &ConsumerState{}, slog.Default())
+			close(done)
+		}()
+
+		// Неполная пачка обрабатывается по таймауту, не дожидаясь новых сообщений
+		select {
+		case <-committed:
+		case <-time.After(time.Second):
+			t.Fatal("Incomplete batch should be committed after timeout")
+		}
+		close(stop)
+		<-done
+	})
+}
+
+// Тестирует обработку пачки, которую не удалось сохранить целиком
+func TestHandleBatchFallback(t *testing.T) {
+	orders := generator.MakeRandomOrder(3)
+	msgs := orderMessages(t, orders, 1)
+	// Первое сообщение не разобрать, оно сразу уходит в DLQ
+	msgs[0].Value = []byte("definitely not a JSON")
+
+	// setup ожидает, что пачка не сохранится, и сообщения сохранятся по одному:
+	// второе успешно, а третье конфликтует с сохраненным заказом, но и DLQ недоступна
+	setup := func(ctrl *gomock.Controller) (*mocks.MockMessagesProducer, *mocks.MockOrdersRepository) {
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		mockRepo.EXPECT().BulkSaveToDB(gomock.Len(2), gomock.Any()).Return(repository.ErrOrderConflict)
+		gomock.InOrder(
+			mockRepo.EXPECT().SaveToDB(withOrder(orders[1]), gomock.Any()).Return(nil),
+			mockRepo.EXPECT().SaveToDB(withOrder(orders[2]), gomock.Any()).Return(repository.ErrOrderConflict),
+		)
+		gomock.InOrder(
+			mockDLQ.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, dead ...kafka.Message) error {
+				assert.Equal(t, StageParse, headerValue(dead[0].Headers, HeaderStage))
+				return nil
+			}),
+			mockDLQ.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(errors.New("Simulated Kafka error")),
+		)
+		return mockDLQ, mockRepo
+	}
+
+	t.Run("Failed message is retried", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+		mockDLQ, mockRepo := setup(ctrl)
+
+		// Повтор сохраняет третье сообщение снова и на этот раз отправляет его в DLQ
+		mockRepo.EXPECT().SaveToDB(withOrder(orders[2]), gomock.Any()).Return(repository.ErrOrderConflict)
+		mockDLQ.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(nil)
+
+		// Пачка коммитится целиком только после того, как третье сообщение обработано
+		mockConsumer.EXPECT().CommitMessages(gomock.Any(), msgs[2]).Return(nil)
+
+		state := &ConsumerState{}
+		handleBatch(context.Background(), mockConsumer, mockDLQ, mockRepo, validation.Default, testRetryPolicy, msgs, make(map[int]bool), state, slog.Default())
+		_, _, stalled := state.oldestStall()
+		assert.False(t, stalled)
+	})
+
+	t.Run("Stopped while retrying", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+		mockDLQ, mockRepo := setup(ctrl)
+
+		// При остановке коммитится последнее обработанное сообщение до неудачного,
+		// а партиция больше не коммитится
+		mockConsumer.EXPECT().CommitMessages(gomock.Any(), msgs[1]).Return(nil)
+
+		stop, cancel := context.WithCancel(context.Background())
+		cancel()
+		failed := make(map[int]bool)
+		handleBatch(stop, mockConsumer, mockDLQ, mockRepo, validation.Default, testRetryPolicy, msgs, failed, &ConsumerState{}, slog.Default())
+		assert.True(t, failed[msgs[2].Partition])
+	})
+}
+
+// Тестирует, что смещение партиции не уходит дальше сообщения с ошибкой
+func TestCommitBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+	msgs := []kafka.Message{{Partition: 0, Offset: 7}, {Partition: 1, Offset: 3}, {Partition: 0, Offset: 8}}
+
+	// Пачка без успешно обработанных сообщений не коммитится
+	mockConsumer.EXPECT().CommitMessages(gomock.Any(), gomock.Any()).Times(0)
+	commitBatch(mockConsumer, msgs, []bool{false, false, false}, make(map[int]bool), slog.Default())
+
+	// Сообщения после неудачного не коммитятся, даже если обработаны
+	failed := make(map[int]bool)
+	mockConsumer.EXPECT().CommitMessages(gomock.Any(), msgs[0], msgs[1]).Return(nil)
+	commitBatch(mockConsumer, msgs, []bool{true, true, false}, failed, slog.Default())
+
+	mockConsumer.EXPECT().CommitMessages(gomock.Any(), msgs[1]).Return(nil)
+	commitBatch(mockConsumer, msgs, []bool{false, true, true}, make(map[int]bool), slog.Default())
+
+	// И в следующих пачках тоже
+	next := []kafka.Message{{Partition: 0, Offset: 9}, {Partition: 1, Offset: 4}}
+	mockConsumer.EXPECT().CommitMessages(gomock.Any(), next[1]).Return(nil)
+	commitBatch(mockConsumer, next, []bool{true, true}, failed, slog.Default())
+}
+
+// benchConsumer отдает заранее подготовленные сообщения, а затем io.EOF
+type benchConsumer struct {
+	mu   sync.Mutex
+	msgs []kafka.Message
+	next int
+}
+
+func (c *benchConsumer) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next == len(c.msgs) {
+		return kafka.Message{}, io.EOF
+	}
+	c.next++
+	return c.msgs[c.next-1], nil
+}
+
+func (c *benchConsumer) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return nil
+}
+
+func (c *benchConsumer) Close() error {
+	return nil
+}
+
+// benchRepo имитирует бд, в которой каждый запрос занимает roundTrip
+// независимо от числа строк, а каждая строка добавляет perOrder
+type benchRepo struct {
+	repository.OrdersRepository
+	roundTrip time.Duration
+	perOrder  time.Duration
+}
+
+func (r *benchRepo) SaveToDB(orders []*generator.Order, ctx context.Context) error {
+	// Заказ по одному - четыре запроса на заказ, доставку, оплату и статус
+	// плюс запрос на каждый товар
+	for _, order := range orders {
+		time.Sleep(time.Duration(4+len(order.Items))*r.roundTrip + r.perOrder)
+	}
+	return nil
+}
+
+func (r *benchRepo) BulkSaveToDB(orders []*generator.Order, ctx context.Context) error {
+	// Пачка - по одному запросу на каждую таблицу
+	time.Sleep(5*r.roundTrip + time.Duration(len(orders))*r.perOrder)
+	return nil
+}
+
+// Сравнивает обработку сообщений по одному и пачками при задержке бд.
+// Запуск: go test ./internal/kafka -run xxx -bench Consume
+func BenchmarkConsume(b *testing.B) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	repo := &benchRepo{roundTrip: 200 * time.Microsecond, perOrder: 10 * time.Microsecond}
+	orders := generator.MakeRandomOrder(1000)
+
+	run := func(b *testing.B, consume func(c MessagesConsumer)) {
+		msgs := orderMessages(b, orders, 3)
+		for len(msgs) < b.N {
+			msgs = append(msgs, msgs...)
+		}
+		b.ResetTimer()
+		consume(&benchConsumer{msgs: msgs[:b.N]})
+	}
+
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("One by one/%d workers", workers), func(b *testing.B) {
+			run(b, func(c MessagesConsumer) {
+				StartConsuming(context.Background(), c, nil, repo, validation.Default, testRetryPolicy, workers, &ConsumerState{}, slog.Default())
+			})
+		})
+	}
+	for _, size := range []int{10, 100} {
+		b.Run(fmt.Sprintf("Batch/%d messages", size), func(b *testing.B) {
+			run(b, func(c MessagesConsumer) {
+				batch := BatchPolicy{Size: size, Timeout: time.Second}
+				StartConsumingBatches(context.Background(), c, nil, repo, validation.Default, testRetryPolicy, batch, &ConsumerState{}, slog.Default())
+			})
+		})
+	}
+}
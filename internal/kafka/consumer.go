@@ -6,43 +6,46 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 
+	"orders/internal/config"
 	"orders/internal/generator"
+	"orders/internal/logging"
+	"orders/internal/metrics"
 	"orders/internal/repository"
+	"orders/internal/schema"
+	"orders/internal/validation"
 
 	"github.com/segmentio/kafka-go"
 )
 
-const (
-	topic   string = "orders"
-	address string = "kafka:9092"
-)
-
-func CreateReader() *kafka.Reader {
+// CreateReader создает ридер группы консьюмеров: партиции топика
+// распределяются между всеми запущенными экземплярами сервиса
+func CreateReader(cfg config.Kafka) *kafka.Reader {
 	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:   []string{address},
-		Topic:     topic,
-		GroupID:   "orders-group",
-		Partition: 0,
+		Brokers: cfg.Brokers,
+		Topic:   cfg.Topic,
+		GroupID: cfg.GroupID,
 	})
 	return r
 }
 
-func CreateTopic() error {
+func CreateTopic(cfg config.Kafka) error {
 	var conn *kafka.Conn
 	var err error
 	maxRetries := 10
+	address := cfg.Brokers[0]
 
 	for i := 0; i < maxRetries; i++ {
 		conn, err = kafka.Dial("tcp", address)
 		if err == nil {
 			break
 		}
-		log.Printf("Error creating Kafka connection (attempt %d/%d): %v", i+1, maxRetries, err)
+		slog.Warn("Error creating Kafka connection", "attempt", i+1, "max_attempts", maxRetries, "error", err)
 
 		if i == maxRetries-1 {
 			return fmt.Errorf("Failed to connect to Kafka after all attempts.")
@@ -66,96 +69,326 @@ func CreateTopic() error {
 
 	topicConfigs := []kafka.TopicConfig{
 		{
-			Topic:             topic,
+			Topic:             cfg.Topic,
+			NumPartitions:     cfg.Partitions,
+			ReplicationFactor: 1,
+		},
+		{
+			Topic:             cfg.DeadLetterTopic,
 			NumPartitions:     1,
 			ReplicationFactor: 1,
 		},
+		{
+			Topic:             cfg.StatusTopic,
+			NumPartitions:     cfg.Partitions,
+			ReplicationFactor: 1,
+		},
 	}
 
 	err = controllerConn.CreateTopics(topicConfigs...)
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
-	log.Printf("Topic %s created successfuly on %s", topic, address)
 
+	// Уже существующие топики создаются заново без ошибки, но с прежним числом партиций
+	for _, topic := range []string{cfg.Topic, cfg.StatusTopic} {
+		if err := ensurePartitions(conn, cfg.Brokers, topic, cfg.Partitions); err != nil {
+			return err
+		}
+	}
+	slog.Info("Kafka topics created", "topic", cfg.Topic, "dead_letter_topic", cfg.DeadLetterTopic,
+		"status_topic", cfg.StatusTopic, "partitions", cfg.Partitions, "broker", address)
+
+	return nil
+}
+
+// ensurePartitions добавляет партиции топику, если их меньше partitions. Уменьшить
+// их число Kafka не позволяет. После добавления часть ключей переходит в новые
+// партиции, и порядок сообщений с этими ключами гарантирован только для новых сообщений
+func ensurePartitions(conn *kafka.Conn, brokers []string, topic string, partitions int) error {
+	existing, err := conn.ReadPartitions(topic)
+	if err != nil {
+		return fmt.Errorf("Error reading partitions of topic %s: %w", topic, err)
+	}
+	if len(existing) >= partitions {
+		if len(existing) > partitions {
+			slog.Warn("Topic has more partitions than configured", "topic", topic, "partitions", len(existing), "configured", partitions)
+		}
+		return nil
+	}
+
+	client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+	resp, err := client.CreatePartitions(context.Background(), &kafka.CreatePartitionsRequest{
+		Topics: []kafka.TopicPartitionsConfig{{Name: topic, Count: int32(partitions)}},
+	})
+	if err == nil {
+		err = resp.Errors[topic]
+	}
+	if err != nil {
+		return fmt.Errorf("Error adding partitions to topic %s: %w", topic, err)
+	}
+	slog.Info("Kafka topic partitions added", "topic", topic, "from", len(existing), "to", partitions)
 	return nil
 }
 
-func StartConsuming(c MessagesConsumer, repo repository.OrdersRepository) {
-	ctx := context.Background()
+// StartConsuming читает сообщения и раздает их workers обработчикам. Сообщения
+// с одним ключом обрабатываются одним обработчиком по порядку, а смещение
+// партиции коммитится, только когда обработаны все сообщения до него.
+// Отмена ctx прерывает повторы сообщений, которые не удается обработать,
+// перед остановкой консьюмера
+func StartConsuming(ctx context.Context, c MessagesConsumer, dlq MessagesProducer, repo repository.OrdersRepository, v validation.OrderValidator, retry RetryPolicy, workers int, state *ConsumerState, logger *slog.Logger) {
+	state.running.Store(true)
+	defer state.running.Store(false)
+
+	offsets := newOffsetTracker()
+	pool := newWorkerPool(workers, func(tm *trackedMessage) {
+		consumeMessage(ctx, c, dlq, repo, v, retry, offsets, tm, state, logger)
+	})
+	// Когда ридер закрыт, дожидаемся обработки уже полученных сообщений:
+	// StartConsuming возвращается только после нее, и App.Close ждет этого,
+	// прежде чем закрыть базу и DLQ. Коммиты закрытого ридера не проходят,
+	// такие сообщения прочитаются повторно
+	defer pool.stop()
+
+	fetchMessages(c, retry, state, logger, func(m kafka.Message) {
+		pool.dispatch(offsets.track(m))
+	})
+}
+
+// fetchMessages читает сообщения и передает их handle, пока ридер не закрыт
+func fetchMessages(c MessagesConsumer, retry RetryPolicy, state *ConsumerState, logger *slog.Logger, handle func(m kafka.Message)) {
+	fetchFailures := 0
 	for {
 		m, err := c.FetchMessage(context.Background())
 		if err != nil {
 			// При graceful shutdown ридер закрывается,
 			// эту ошибку пропускаем
 			if errors.Is(err, io.EOF) {
-				break
+				return
 			}
-			log.Println("Error reading message:", err)
-			break
+			// Сбой брокера не должен останавливать чтение насовсем:
+			// ждем и пробуем снова, увеличивая паузу с каждой неудачей
+			metrics.FetchErrors.Inc()
+			fetchFailures++
+			state.fetchFailed(fetchFailures)
+			delay := retry.Backoff(fetchFailures)
+			logger.Error("Error reading message, retrying", "failures", fetchFailures, "delay", delay, "error", err)
+			time.Sleep(delay)
+			continue
 		}
-		log.Printf("New message at topic/partition/offset %v/%v/%v: %s = %s\n",
-			m.Topic, m.Partition, m.Offset, string(m.Key), string(m.Value))
+		metrics.MessagesFetched.Inc()
+		fetchFailures = 0
+		state.fetched()
 
-		var orders []*generator.Order
-		err = json.Unmarshal(m.Value, &orders)
-		if err != nil {
-			log.Println("Error unmarshalling orders data:", err)
-			continue
+		handle(m)
+	}
+}
+
+// consumeMessage обрабатывает одно сообщение в обработчике пула и коммитит
+// смещение, до которого обработаны все сообщения партиции. Сообщение, которое
+// не удалось ни сохранить, ни отправить в DLQ, повторяется, пока не будет
+// обработано или не отменен stop
+func consumeMessage(stop context.Context, c MessagesConsumer, dlq MessagesProducer, repo repository.OrdersRepository, v validation.OrderValidator, retry RetryPolicy, offsets *offsetTracker, tm *trackedMessage, state *ConsumerState, logger *slog.Logger) {
+	m := tm.msg
+	ctx, msgLogger := messageContext(context.Background(), m, logger)
+
+	err := handleMessage(ctx, m, dlq, repo, v, retry)
+	if err != nil {
+		metrics.MessagesFailed.WithLabelValues("dead_letter").Inc()
+		err = retryUntilHandled(stop, m, retry, state, msgLogger, err, func() error {
+			return handleMessage(ctx, m, dlq, repo, v, retry)
+		})
+	}
+	if err != nil {
+		msgLogger.Error("Message is left uncommitted", "error", err)
+	}
+
+	// Незакоммиченное сообщение будет прочитано повторно после перезапуска
+	// или ребалансировки, сохранение заказов идемпотентно
+	commit, committed := offsets.done(tm, err == nil)
+	if commit == nil {
+		return
+	}
+	if err := tm.partition.commit(ctx, c, *commit); err != nil {
+		metrics.MessagesFailed.WithLabelValues("commit").Inc()
+		msgLogger.Error("Error committing message", "offset", commit.Offset, "error", err)
+		return
+	}
+	metrics.MessagesCommitted.Add(float64(committed))
+	msgLogger.Info("Committed message", "partition", commit.Partition, "offset", commit.Offset)
+}
+
+// messageContext помечает все логи обработки сообщения его id, а если сообщение
+// пришло через POST /orders - еще и id исходного HTTP запроса
+func messageContext(ctx context.Context, m kafka.Message, logger *slog.Logger) (context.Context, *slog.Logger) {
+	msgLogger := logger.With("message_id", messageID(m))
+	if requestID := headerValue(m.Headers, logging.HeaderRequestID); requestID != "" {
+		msgLogger = msgLogger.With("request_id", requestID)
+		ctx = logging.WithRequestID(ctx, requestID)
+	}
+
+	msgLogger.Info("New message", "key", string(m.Key), "size", len(m.Value))
+	msgLogger.Debug("Message value", "value", string(m.Value))
+	return logging.WithLogger(ctx, msgLogger), msgLogger
+}
+
+// messageID однозначно определяет сообщение по топику, партиции и смещению
+func messageID(m kafka.Message) string {
+	return fmt.Sprintf("%s/%d/%d", m.Topic, m.Partition, m.Offset)
+}
+
+// headerValue возвращает значение заголовка сообщения по ключу
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
 		}
+	}
+	return ""
+}
 
-		orders = validateOrders(orders)
+// handleMessage сохраняет заказы из сообщения, а все, что сохранить нельзя,
+// отправляет в DLQ. Ошибка означает, что сообщение нельзя коммитить
+func handleMessage(ctx context.Context, m kafka.Message, dlq MessagesProducer, repo repository.OrdersRepository, v validation.OrderValidator, retry RetryPolicy) error {
+	orders, err := prepareMessage(ctx, m, dlq, v)
+	if err != nil || len(orders) == 0 {
+		return err
+	}
+	return persistOrders(ctx, m, dlq, repo, orders, retry)
+}
 
-		if len(orders) > 0 {
-			err = repo.SaveToDB(orders, ctx)
-			if err != nil {
-				log.Printf("Failed to save orders from Kafka message: %v\n", err)
+// prepareMessage разбирает сообщение и возвращает заказы, которые нужно сохранить.
+// Неразобранное сообщение и отклоненные заказы отправляются в DLQ, ошибка
+// возвращается, только если не удалось отправить их туда
+func prepareMessage(ctx context.Context, m kafka.Message, dlq MessagesProducer, v validation.OrderValidator) ([]*generator.Order, error) {
+	orders, err := decodeMessage(m)
+	if err != nil {
+		stage := StageParse
+		if errors.Is(err, ErrSchemaMismatch) {
+			stage = StageSchema
+		}
+		logging.FromContext(ctx).Error("Error unmarshalling orders data", "stage", stage, "error", err)
+		metrics.MessagesFailed.WithLabelValues(stage).Inc()
+		return nil, SendToDeadLetter(dlq, ctx, m, m.Value, stage, err.Error(), 1)
+	}
+
+	orders, rejected := ValidateOrders(ctx, v, orders)
+
+	// В DLQ уходят только отклоненные заказы в том же формате, что и исходное сообщение
+	if len(rejected) > 0 {
+		metrics.MessagesFailed.WithLabelValues(StageValidation).Inc()
+		rejectedOrders := make([]*generator.Order, 0, len(rejected))
+		reasons := make([]string, 0, len(rejected))
+		for _, r := range rejected {
+			rejectedOrders = append(rejectedOrders, r.Order)
+			if r.Order == nil {
+				reasons = append(reasons, r.Reason)
 				continue
 			}
+			reasons = append(reasons, fmt.Sprintf("%s: %s", r.Order.OrderUID, r.Reason))
+		}
 
-			if err := c.CommitMessages(ctx, m); err != nil {
-				log.Fatalln("Error committing message:", err)
+		// Если отклонены все заказы сообщения, в DLQ уходит оно само. Иначе отклоненные
+		// заказы записываются заново в последней версии схемы, и конверт меняется вместе с ними
+		dead := m
+		if len(orders) > 0 {
+			dead.Value, err = json.Marshal(rejectedOrders)
+			if err != nil {
+				logging.FromContext(ctx).Error("Error marshalling rejected orders", "error", err)
+				return nil, err
+			}
+			if headerValue(m.Headers, HeaderSchemaVersion) != "" {
+				latest := schema.Default.Latest(schema.OrderCreated)
+				dead.Headers = setHeader(m.Headers, HeaderSchemaVersion, strconv.Itoa(latest))
 			}
-			log.Printf("Committed message at topic/partition/offset %v/%v/%v\n",
-				m.Topic, m.Partition, m.Offset)
+		}
+
+		err = SendToDeadLetter(dlq, ctx, dead, dead.Value, StageValidation, strings.Join(reasons, "; "), 1)
+		if err != nil {
+			return nil, err
 		}
 	}
+	return orders, nil
 }
 
-// Валидируем входящие данные
-func validateOrders(orders []*generator.Order) []*generator.Order {
-	var validOrders []*generator.Order
-
-	for _, order := range orders {
-		isValid := true
+// persistOrders сохраняет заказы сообщения с повторными попытками,
+// а если сохранить их не удалось, отправляет сообщение в DLQ
+func persistOrders(ctx context.Context, m kafka.Message, dlq MessagesProducer, repo repository.OrdersRepository, orders []*generator.Order, retry RetryPolicy) error {
+	attempts, err := saveWithRetry(ctx, repo, orders, retry)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to save orders from Kafka message", "attempts", attempts, "error", err)
+		metrics.MessagesFailed.WithLabelValues(StagePersist).Inc()
+		return SendToDeadLetter(dlq, ctx, m, m.Value, StagePersist, err.Error(), attempts)
+	}
+	return nil
+}
 
-		// Например, мы не хотим увидеть id заказа пустым
-		if order.OrderUID == "" {
-			log.Println("Invalid order data found: missing OrderUID. Ignoring this order")
-			isValid = false
+// saveWithRetry сохраняет заказы, повторяя попытки при временных ошибках.
+// Возвращает число сделанных попыток и последнюю ошибку, если сохранить так и не удалось
+func saveWithRetry(ctx context.Context, repo repository.OrdersRepository, orders []*generator.Order, retry RetryPolicy) (int, error) {
+	attempt := 0
+	for {
+		attempt++
+		err := repo.SaveToDB(orders, ctx)
+		if err == nil {
+			return attempt, nil
 		}
-		// Пустой трек-номер тоже не подойдет
-		if order.TrackNumber == "" && isValid {
-			log.Println("Invalid order data found: missing TrackNumber. Ignoring this order")
-			isValid = false
+
+		if !repository.IsRetryable(err) {
+			logging.FromContext(ctx).Error("Permanent error saving orders, giving up", "error", err)
+			return attempt, err
 		}
-		// Или пустой id клиента
-		if order.CustomerID == "" && isValid {
-			log.Println("Invalid order data found: missing CustomerID. Ignoring this order")
-			isValid = false
+		if attempt >= retry.MaxAttempts {
+			logging.FromContext(ctx).Error("Retry limit reached saving orders, message is considered poison", "error", err)
+			return attempt, err
 		}
 
-		phone := order.Delivery.Phone
-		// Или, например, мы считаем, что номер телефона, начинающийся с 0 - некорректный
-		if isValid && len(phone) > 0 && phone[0] == '0' {
-			log.Println("Invalid order phone data found: starts with 0. Ignoring this order")
-			isValid = false
+		delay := retry.Backoff(attempt)
+		logging.FromContext(ctx).Warn("Error saving orders, retrying",
+			"attempt", attempt, "max_attempts", retry.MaxAttempts, "delay", delay, "error", err)
+		time.Sleep(delay)
+	}
+}
+
+// RejectedOrder описывает заказ, не прошедший валидацию
+type RejectedOrder struct {
+	Order  *generator.Order
+	Reason string
+	// Errors - нарушения по полям, пусто для null вместо заказа
+	Errors validation.Errors
+}
+
+// ValidateOrders делит заказы на валидные и отклоненные валидатором v с указанием причины.
+// Используется как при чтении из Kafka, так и при приеме заказов по HTTP
+func ValidateOrders(ctx context.Context, v validation.OrderValidator, orders []*generator.Order) ([]*generator.Order, []RejectedOrder) {
+	var validOrders []*generator.Order
+	var rejected []RejectedOrder
+
+	for _, order := range orders {
+		// Пустой элемент (null) в массиве заказов даже не с чем сравнивать
+		if order == nil {
+			logging.FromContext(ctx).Warn("Invalid order data found, ignoring this order", "reason", "empty order")
+			rejected = append(rejected, RejectedOrder{Reason: "empty order"})
+			metrics.OrdersRejected.WithLabelValues("empty order").Inc()
+			continue
 		}
 
-		// Если все ок, добавляем заказ к результату
-		if isValid {
+		err := v.Validate(order)
+		if err == nil {
 			validOrders = append(validOrders, order)
+			continue
+		}
+
+		var errs validation.Errors
+		logging.FromContext(ctx).Warn("Invalid order data found, ignoring this order", "order_uid", order.OrderUID, "reason", err.Error())
+		// Причина в метрике - первое нарушенное правило, чтобы число меток было ограничено.
+		// Валидатор может вернуть и ошибку без правил, тогда причина - "other"
+		rule := "other"
+		if errors.As(err, &errs) && len(errs) > 0 {
+			rule = errs[0].Rule
 		}
+		rejected = append(rejected, RejectedOrder{Order: order, Reason: err.Error(), Errors: errs})
+		metrics.OrdersRejected.WithLabelValues(rule).Inc()
 	}
-	return validOrders
+	return validOrders, rejected
 }
@@ -1,14 +1,31 @@
 package kafka
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
 	"testing"
+	"time"
 
 	"orders/internal/generator"
+	"orders/internal/metrics"
+	"orders/internal/mocks"
+	"orders/internal/repository"
+	"orders/internal/validation"
 
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 )
 
-// Тестирует корректность обработки невалидных данных в заказах функцией validateOrders
+// Тестирует корректность обработки невалидных данных в заказах функцией ValidateOrders
 func TestValidateOrders(t *testing.T) {
 	// Тестируем при корректности всех заказов
 	t.Run("All orders are valid", func(t *testing.T) {
@@ -18,8 +35,9 @@ func TestValidateOrders(t *testing.T) {
 		orders := generator.MakeRandomOrder(validOrdersAmount)
 
 		// Проверяем итоговый список заказов для сохранения в бд и последующего коммита
-		validOrders := validateOrders(orders)
+		validOrders, rejected := ValidateOrders(context.Background(), validation.Default, orders)
 		require.Len(t, validOrders, len(orders), "Should return all orders if they are valid")
+		require.Empty(t, rejected, "Should not reject valid orders")
 		t.Logf("All %d orders were validated. Returned %d/%d as valid", validOrdersAmount, len(validOrders), validOrdersAmount)
 	})
 
@@ -64,8 +82,366 @@ func TestValidateOrders(t *testing.T) {
 		t.Log("Expected valid orders in one message:", expectedLen)
 
 		// Сравниваем ожидание с реальностью
-		validOrders := validateOrders(ordersBatch)
+		validOrders, rejected := ValidateOrders(context.Background(), validation.Default, ordersBatch)
 		require.Equal(t, expectedLen, len(validOrders), "Valid orders amount should match expected value")
+		require.Len(t, rejected, inputLen-expectedLen, "Every invalid order should be rejected with a reason")
 		t.Logf("All %d orders were validated. Returned %d/%d as valid", inputLen, len(validOrders), expectedLen)
 	})
+
+	// Тестируем валидатор, который возвращает ошибку без нарушенных правил
+	t.Run("Error without rules", func(t *testing.T) {
+		for _, err := range []error{errors.New("validator is broken"), validation.Errors{}} {
+			order := generator.MakeRandomOrder(1)[0]
+			before := testutil.ToFloat64(metrics.OrdersRejected.WithLabelValues("other"))
+
+			validOrders, rejected := ValidateOrders(context.Background(), failingValidator{err}, []*generator.Order{order})
+			require.Empty(t, validOrders)
+			require.Len(t, rejected, 1)
+			assert.Equal(t, err.Error(), rejected[0].Reason)
+			assert.Empty(t, rejected[0].Errors)
+			assert.Equal(t, before+1, testutil.ToFloat64(metrics.OrdersRejected.WithLabelValues("other")))
+		}
+	})
+}
+
+// failingValidator отклоняет любой заказ ошибкой err
+type failingValidator struct{ err error }
+
+func (v failingValidator) Validate(*generator.Order) error { return v.err }
+
+// Политика повторов с минимальными задержками, чтобы не замедлять тесты
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseBackoff: time.Millisecond,
+	MaxBackoff:  2 * time.Millisecond,
+}
+
+// Тестирует отправку в DLQ сообщений, которые не удалось обработать
+func TestHandleMessageDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	// Исходное сообщение, как если бы его прочитали из топика
+	newMessage := func(value []byte) kafka.Message {
+		return kafka.Message{Topic: "orders", Partition: 0, Offset: 42, Value: value}
+	}
+
+	t.Run("Unparsable message", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		m := newMessage([]byte("definitely not a JSON"))
+
+		// Сохранять нечего, сообщение целиком уходит в DLQ
+		mockRepo.EXPECT().SaveToDB(gomock.Any(), gomock.Any()).Times(0)
+		mockDLQ.EXPECT().
+			WriteMessages(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+				require.Len(t, msgs, 1)
+				assert.Equal(t, m.Value, msgs[0].Value, "DLQ should receive the original message")
+				assert.Equal(t, StageParse, headerValue(msgs[0].Headers, HeaderStage))
+				assert.Equal(t, "orders", headerValue(msgs[0].Headers, HeaderOriginalTopic))
+				assert.Equal(t, "0", headerValue(msgs[0].Headers, HeaderOriginalPartition))
+				assert.Equal(t, "42", headerValue(msgs[0].Headers, HeaderOriginalOffset))
+				assert.Equal(t, "1", headerValue(msgs[0].Headers, HeaderAttempts))
+				assert.NotEmpty(t, headerValue(msgs[0].Headers, HeaderReason))
+				return nil
+			}).
+			Times(1)
+
+		err := handleMessage(ctx, m, mockDLQ, mockRepo, validation.Default, testRetryPolicy)
+		assert.NoError(t, err, "Message sent to DLQ should be committed")
+	})
+
+	t.Run("Invalid orders", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		orders := generator.MakeRandomOrder(3)
+		orders[1].CustomerID = ""
+		value, err := json.Marshal(orders)
+		require.NoError(t, err)
+
+		// Валидные заказы сохраняются, невалидный уходит в DLQ
+		mockRepo.EXPECT().
+			SaveToDB(gomock.Len(2), ctx).
+			Return(nil).
+			Times(1)
+		mockDLQ.EXPECT().
+			WriteMessages(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+				var rejected []*generator.Order
+				require.NoError(t, json.Unmarshal(msgs[0].Value, &rejected))
+				require.Len(t, rejected, 1, "Only rejected orders should be sent to DLQ")
+				assert.Equal(t, orders[1].OrderUID, rejected[0].OrderUID)
+				assert.Equal(t, StageValidation, headerValue(msgs[0].Headers, HeaderStage))
+				assert.Contains(t, headerValue(msgs[0].Headers, HeaderReason), "customer_id: is required")
+				return nil
+			}).
+			Times(1)
+
+		err = handleMessage(ctx, newMessage(value), mockDLQ, mockRepo, validation.Default, testRetryPolicy)
+		assert.NoError(t, err, "Message should be committed")
+	})
+
+	t.Run("Keyed message with invalid order", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		order := generator.MakeRandomOrder(1)[0]
+		order.CustomerID = ""
+		value, err := json.Marshal(order)
+		require.NoError(t, err)
+
+		// Сообщение с одним заказом отклонено целиком и уходит в DLQ как есть
+		mockRepo.EXPECT().SaveToDB(gomock.Any(), gomock.Any()).Times(0)
+		mockDLQ.EXPECT().
+			WriteMessages(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+				assert.Equal(t, value, msgs[0].Value, "DLQ should receive the original message")
+				assert.Equal(t, StageValidation, headerValue(msgs[0].Headers, HeaderStage))
+				return nil
+			}).
+			Times(1)
+
+		err = handleMessage(ctx, newMessage(value), mockDLQ, mockRepo, validation.Default, testRetryPolicy)
+		assert.NoError(t, err, "Message should be committed")
+	})
+
+	t.Run("Persistence failure", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		value, err := json.Marshal(generator.MakeRandomOrder(2))
+		require.NoError(t, err)
+
+		// Временная ошибка повторяется до исчерпания попыток
+		mockRepo.EXPECT().
+			SaveToDB(gomock.Any(), ctx).
+			Return(errors.New("Simulated database error")).
+			Times(testRetryPolicy.MaxAttempts)
+		mockDLQ.EXPECT().
+			WriteMessages(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+				assert.Equal(t, value, msgs[0].Value, "DLQ should receive the original message")
+				assert.Equal(t, StagePersist, headerValue(msgs[0].Headers, HeaderStage))
+				assert.Equal(t, "Simulated database error", headerValue(msgs[0].Headers, HeaderReason))
+				assert.Equal(t, strconv.Itoa(testRetryPolicy.MaxAttempts), headerValue(msgs[0].Headers, HeaderAttempts))
+				return nil
+			}).
+			Times(1)
+
+		err = handleMessage(ctx, newMessage(value), mockDLQ, mockRepo, validation.Default, testRetryPolicy)
+		assert.NoError(t, err, "Message sent to DLQ should be committed")
+	})
+
+	t.Run("Dead-letter topic is unavailable", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		mockDLQ.EXPECT().
+			WriteMessages(ctx, gomock.Any()).
+			Return(errors.New("Simulated Kafka error")).
+			Times(1)
+
+		// Если сообщение не удалось сохранить даже в DLQ, коммитить его нельзя
+		err := handleMessage(ctx, newMessage([]byte("{")), mockDLQ, mockRepo, validation.Default, testRetryPolicy)
+		assert.Error(t, err, "Message should stay uncommitted")
+	})
+}
+
+// Тестирует повторные попытки сохранения при временных и постоянных ошибках
+func TestHandleMessageRetry(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Transient error is retried", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		value, err := json.Marshal(generator.MakeRandomOrder(1))
+		require.NoError(t, err)
+
+		// Первая попытка падает из-за обрыва соединения, вторая успешна
+		gomock.InOrder(
+			mockRepo.EXPECT().SaveToDB(gomock.Any(), ctx).Return(&pq.Error{Code: "08006"}),
+			mockRepo.EXPECT().SaveToDB(gomock.Any(), ctx).Return(nil),
+		)
+		mockDLQ.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Times(0)
+
+		err = handleMessage(ctx, kafka.Message{Value: value}, mockDLQ, mockRepo, validation.Default, testRetryPolicy)
+		assert.NoError(t, err, "Message should be committed after successful retry")
+	})
+
+	t.Run("Permanent error is not retried", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		value, err := json.Marshal(generator.MakeRandomOrder(1))
+		require.NoError(t, err)
+
+		// Конфликт данных не исправится сам собой, повторять бессмысленно
+		mockRepo.EXPECT().
+			SaveToDB(gomock.Any(), ctx).
+			Return(fmt.Errorf("%w: some-uid", repository.ErrOrderConflict)).
+			Times(1)
+		mockDLQ.EXPECT().
+			WriteMessages(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+				assert.Equal(t, "1", headerValue(msgs[0].Headers, HeaderAttempts))
+				return nil
+			}).
+			Times(1)
+
+		err = handleMessage(ctx, kafka.Message{Value: value}, mockDLQ, mockRepo, validation.Default, testRetryPolicy)
+		assert.NoError(t, err, "Poison message should be committed after DLQ")
+	})
+}
+
+// Тестирует подсчет отклоненных заказов и сообщений с ошибками в метриках
+func TestHandleMessageMetrics(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+	rejectedBefore := testutil.ToFloat64(metrics.OrdersRejected.WithLabelValues("required"))
+	validationBefore := testutil.ToFloat64(metrics.MessagesFailed.WithLabelValues(StageValidation))
+	parseBefore := testutil.ToFloat64(metrics.MessagesFailed.WithLabelValues(StageParse))
+
+	orders := generator.MakeRandomOrder(3)
+	orders[0].CustomerID = ""
+	orders[2].CustomerID = ""
+	value, err := json.Marshal(orders)
+	require.NoError(t, err)
+
+	mockRepo.EXPECT().SaveToDB(gomock.Any(), ctx).Return(nil).Times(1)
+	mockDLQ.EXPECT().WriteMessages(ctx, gomock.Any()).Return(nil).Times(2)
+
+	require.NoError(t, handleMessage(ctx, kafka.Message{Value: value}, mockDLQ, mockRepo, validation.Default, testRetryPolicy))
+	require.NoError(t, handleMessage(ctx, kafka.Message{Value: []byte("not json")}, mockDLQ, mockRepo, validation.Default, testRetryPolicy))
+
+	// Каждый заказ считается по правилу, а сообщение - один раз на стадию
+	assert.Equal(t, rejectedBefore+2, testutil.ToFloat64(metrics.OrdersRejected.WithLabelValues("required")))
+	assert.Equal(t, validationBefore+1, testutil.ToFloat64(metrics.MessagesFailed.WithLabelValues(StageValidation)))
+	assert.Equal(t, parseBefore+1, testutil.ToFloat64(metrics.MessagesFailed.WithLabelValues(StageParse)))
+}
+
+// Тестирует расчет задержки между попытками
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 10,
+		BaseBackoff: 100 * time.Millisecond,
+		MaxBackoff:  time.Second,
+	}
+
+	// Без разброса задержка удваивается и упирается в максимум
+	assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Backoff(2))
+	assert.Equal(t, 800*time.Millisecond, policy.Backoff(4))
+	assert.Equal(t, time.Second, policy.Backoff(5))
+	assert.Equal(t, time.Second, policy.Backoff(50))
+
+	// С разбросом задержка остается в пределах доли Jitter
+	policy.Jitter = 0.5
+	for range 100 {
+		delay := policy.Backoff(2)
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, 300*time.Millisecond)
+	}
+}
+
+// Тестирует отражение работы StartConsuming в ConsumerState
+func TestConsumerState(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+	mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+	state := &ConsumerState{MaxFetchFailures: 2}
+	require.Error(t, state.Check(context.Background()), "Consumer is not started yet")
+
+	firstFailure := make(chan struct{})
+	resume := make(chan struct{})
+	fetched := make(chan struct{})
+	stop := make(chan struct{})
+
+	// Сначала два сбоя брокера подряд, затем закрытие ридера при остановке сервиса
+	gomock.InOrder(
+		mockConsumer.EXPECT().FetchMessage(gomock.Any()).Return(kafka.Message{}, errors.New("Simulated broker error")),
+		mockConsumer.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (kafka.Message, error) {
+			close(firstFailure)
+			<-resume
+			return kafka.Message{}, errors.New("Simulated broker error")
+		}),
+		mockConsumer.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (kafka.Message, error) {
+			close(fetched)
+			<-stop
+			return kafka.Message{}, io.EOF
+		}),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		StartConsuming(context.Background(), mockConsumer, mockDLQ, mockRepo, validation.Default, testRetryPolicy, 2, state, slog.Default())
+		close(done)
+	}()
+
+	// Единичный сбой консьюмер переживает сам, готовность не пропадает
+	<-firstFailure
+	assert.True(t, state.Running(), "Consumer should be running while fetching")
+	assert.Equal(t, 1, state.FetchFailures())
+	assert.NoError(t, state.Check(context.Background()), "Single fetch error should not fail readiness")
+
+	close(resume)
+	<-fetched
+	assert.Equal(t, 2, state.FetchFailures())
+	assert.ErrorContains(t, state.Check(context.Background()), "2 times in a row")
+
+	close(stop)
+	<-done
+	assert.False(t, state.Running(), "Consumer should stop after reader is closed")
+	assert.ErrorContains(t, state.Check(context.Background()), "not running")
+}
+
+// Тестирует потерю готовности, когда сбои чтения не прекращаются дольше окна
+func TestConsumerStateFailingWindow(t *testing.T) {
+	ctx := context.Background()
+	state := &ConsumerState{MaxFetchFailures: 100, MaxFailingFor: 20 * time.Millisecond}
+	state.running.Store(true)
+
+	state.fetchFailed(1)
+	assert.NoError(t, state.Check(ctx))
+
+	// Редкие сбои с долгими паузами не набирают порог, но длятся дольше окна
+	time.Sleep(30 * time.Millisecond)
+	state.fetchFailed(2)
+	assert.ErrorContains(t, state.Check(ctx), "has been failing to fetch messages")
+
+	// Успешное чтение сбрасывает и счетчик, и окно
+	state.fetched()
+	assert.NoError(t, state.Check(ctx))
+	assert.False(t, state.LastMessage().IsZero())
 }
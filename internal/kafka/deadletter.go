@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+
+	"orders/internal/config"
+	"orders/internal/logging"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Стадии обработки, на которых сообщение может попасть в DLQ
+const (
+	StageParse      string = "parse"
+	StageSchema     string = "schema"
+	StageValidation string = "validation"
+	StagePersist    string = "persist"
+)
+
+// Заголовки, описывающие причину попадания сообщения в DLQ
+const (
+	HeaderStage             string = "x-dlq-stage"
+	HeaderReason            string = "x-dlq-reason"
+	HeaderOriginalTopic     string = "x-dlq-original-topic"
+	HeaderOriginalPartition string = "x-dlq-original-partition"
+	HeaderOriginalOffset    string = "x-dlq-original-offset"
+	HeaderAttempts          string = "x-dlq-attempts"
+)
+
+func CreateDeadLetterWriter(cfg config.Kafka) *kafka.Writer {
+	w := &kafka.Writer{
+		Addr:     kafka.TCP(cfg.Brokers...),
+		Topic:    cfg.DeadLetterTopic,
+		Balancer: &kafka.LeastBytes{},
+	}
+	return w
+}
+
+// SendToDeadLetter публикует value в DLQ с ключом и заголовками исходного
+// сообщения m, дополняя их сведениями о стадии и причине ошибки
+func SendToDeadLetter(p MessagesProducer, ctx context.Context, m kafka.Message, value []byte, stage, reason string, attempts int) error {
+	headers := append([]kafka.Header(nil), m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderStage, Value: []byte(stage)},
+		kafka.Header{Key: HeaderReason, Value: []byte(reason)},
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+	)
+
+	err := p.WriteMessages(ctx,
+		kafka.Message{
+			Key:     m.Key,
+			Value:   value,
+			Headers: headers,
+		},
+	)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to write message to dead-letter topic", "error", err)
+		return err
+	}
+
+	logging.FromContext(ctx).Warn("Message sent to dead-letter topic", "stage", stage, "reason", reason, "attempts", attempts)
+	return nil
+}
@@ -0,0 +1,151 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"orders/internal/generator"
+	"orders/internal/metrics"
+	"orders/internal/schema"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrSchemaMismatch - сообщение разобрано, но не подходит под схему своей версии
+var ErrSchemaMismatch = errors.New("message does not match its schema")
+
+// legacyVersion - последняя версия схемы заказа, которой писались сообщения без конверта
+const legacyVersion = 2
+
+// upgrade переводит заказ, разобранный в map, из версии схемы v в v+1.
+// Ошибка означает, что заказ не может быть заказом версии v
+type upgrade func(order map[string]any) error
+
+// orderUpgrades[v] переводит заказ версии v в следующую. Сообщения любой
+// известной версии поднимаются по цепочке до последней и только потом
+// разбираются в generator.Order
+var orderUpgrades = map[int]upgrade{
+	1: upgradeOrderV1,
+}
+
+// upgradeOrderV1: в v1 значение oof_shard записывалось в поле status,
+// а статуса жизненного цикла еще не было. Поле oof_shard в заказе v1
+// значит, что версия в конверте не соответствует значению
+func upgradeOrderV1(order map[string]any) error {
+	if _, ok := order["oof_shard"]; ok {
+		return errors.New("oof_shard is not expected in v1, it is serialized as status")
+	}
+	order["oof_shard"] = order["status"]
+	delete(order, "status")
+	return nil
+}
+
+// decodeMessage разбирает значение сообщения: один заказ или массив заказов,
+// как в сообщениях, записанных до перехода на сообщение на каждый заказ.
+// Версия схемы берется из конверта, а у сообщений без конверта определяется
+// по каждому заказу: в v1 нет поля oof_shard
+func decodeMessage(m kafka.Message) ([]*generator.Order, error) {
+	envelope, ok, err := ReadEnvelope(m.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSchemaMismatch, err)
+	}
+	if ok && envelope.EventType != schema.OrderCreated {
+		return nil, fmt.Errorf("%w: unexpected event type %q", ErrSchemaMismatch, envelope.EventType)
+	}
+	// Версия 0 означает сообщение без конверта, но явно указанная в заголовке - ошибку
+	if ok && envelope.Version <= 0 {
+		_, err := schema.Default.Schema(envelope.EventType, envelope.Version)
+		return nil, fmt.Errorf("%w: %w", ErrSchemaMismatch, err)
+	}
+	if ok {
+		metrics.MessagesBySchema.WithLabelValues(strconv.Itoa(envelope.Version)).Inc()
+	} else {
+		metrics.MessagesBySchema.WithLabelValues("legacy").Inc()
+	}
+
+	values, err := splitValue(m.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]*generator.Order, len(values))
+	for i, value := range values {
+		orders[i], err = decodeOrder(value, envelope.Version)
+		if err != nil {
+			if len(values) > 1 {
+				return nil, fmt.Errorf("order %d: %w", i, err)
+			}
+			return nil, err
+		}
+	}
+	return orders, nil
+}
+
+// splitValue делит значение сообщения на отдельные заказы
+func splitValue(value []byte) ([]json.RawMessage, error) {
+	value = bytes.TrimSpace(value)
+	if len(value) > 0 && value[0] == '{' {
+		return []json.RawMessage{value}, nil
+	}
+
+	var values []json.RawMessage
+	err := json.Unmarshal(value, &values)
+	return values, err
+}
+
+// decodeOrder проверяет заказ схемой версии version и поднимает его до последней.
+// Версия 0 означает сообщение без конверта. null в массиве остается nil,
+// такие заказы отклоняет валидация
+func decodeOrder(value json.RawMessage, version int) (*generator.Order, error) {
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	var raw any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	fields, _ := raw.(map[string]any)
+	if version == 0 {
+		version = legacyVersion
+		if _, ok := fields["oof_shard"]; fields != nil && !ok {
+			version = 1
+		}
+	}
+
+	s, err := schema.Default.Schema(schema.OrderCreated, version)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSchemaMismatch, err)
+	}
+	if err := s.ValidateValue(raw); err != nil {
+		return nil, fmt.Errorf("%w: v%d: %w", ErrSchemaMismatch, version, err)
+	}
+
+	latest := schema.Default.Latest(schema.OrderCreated)
+	if version < latest {
+		for v := version; v < latest; v++ {
+			up, ok := orderUpgrades[v]
+			if !ok {
+				return nil, fmt.Errorf("%w: no upgrade from v%d to v%d", ErrSchemaMismatch, v, v+1)
+			}
+			if err := up(fields); err != nil {
+				return nil, fmt.Errorf("%w: v%d: %w", ErrSchemaMismatch, v, err)
+			}
+		}
+		value, err = json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var order generator.Order
+	if err := json.Unmarshal(value, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
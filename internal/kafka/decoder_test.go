@@ -0,0 +1,241 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"orders/internal/generator"
+	"orders/internal/metrics"
+	"orders/internal/mocks"
+	"orders/internal/schema"
+	"orders/internal/validation"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// marshalV1 записывает заказ в формате v1, где значение oof_shard лежит в поле status
+func marshalV1(t *testing.T, order *generator.Order) []byte {
+	data, err := json.Marshal(order)
+	require.NoError(t, err)
+
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(data, &fields))
+	fields["status"] = fields["oof_shard"]
+	delete(fields, "oof_shard")
+
+	data, err = json.Marshal(fields)
+	require.NoError(t, err)
+	return data
+}
+
+// envelopeHeaders возвращает заголовки конверта заказа версии version
+func envelopeHeaders(version int) []kafka.Header {
+	envelope := NewEnvelope(schema.OrderCreated, "producer-1")
+	envelope.Version = version
+	return envelope.Headers()
+}
+
+// Тестирует разбор сообщений всех версий схемы заказа
+func TestDecodeMessage(t *testing.T) {
+	order := generator.MakeRandomOrder(1)[0]
+	order.Status = ""
+	v2, err := json.Marshal(order)
+	require.NoError(t, err)
+	v1 := marshalV1(t, order)
+
+	// Любая версия разбирается в один и тот же заказ
+	decode := func(t *testing.T, m kafka.Message) *generator.Order {
+		orders, err := decodeMessage(m)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, order.OrderUID, orders[0].OrderUID)
+		assert.Equal(t, order.OofShard, orders[0].OofShard, "oof_shard should survive the upgrade")
+		assert.Empty(t, orders[0].Status, "Lifecycle status is filled by the service")
+		return orders[0]
+	}
+
+	t.Run("Latest version", func(t *testing.T) {
+		decode(t, kafka.Message{Value: v2, Headers: envelopeHeaders(2)})
+	})
+
+	t.Run("Version 1 is upgraded", func(t *testing.T) {
+		before := testutil.ToFloat64(metrics.MessagesBySchema.WithLabelValues("1"))
+		decode(t, kafka.Message{Value: v1, Headers: envelopeHeaders(1)})
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.MessagesBySchema.WithLabelValues("1")))
+	})
+
+	t.Run("Messages without envelope", func(t *testing.T) {
+		// Версия определяется по каждому заказу: в v1 нет поля oof_shard
+		decode(t, kafka.Message{Value: v1})
+		decode(t, kafka.Message{Value: v2})
+
+		value := []byte("[" + string(v1) + "," + string(v2) + ",null]")
+		orders, err := decodeMessage(kafka.Message{Value: value})
+		require.NoError(t, err)
+		require.Len(t, orders, 3)
+		assert.Equal(t, order.OofShard, orders[0].OofShard)
+		assert.Equal(t, order.OofShard, orders[1].OofShard)
+		assert.Nil(t, orders[2], "null is left for validation to reject")
+	})
+
+	t.Run("Schema mismatch", func(t *testing.T) {
+		wrongType := []byte(`{"order_uid": 42}`)
+		cases := []struct {
+			name  string
+			m     kafka.Message
+			error string
+		}{
+			{"Version 2 body under version 1", kafka.Message{Value: v2, Headers: envelopeHeaders(1)}, "v1: oof_shard is not expected in v1"},
+			{"Unknown version", kafka.Message{Value: v2, Headers: envelopeHeaders(9)}, "unknown schema version"},
+			// Явный 0 в заголовке - не сообщение без конверта
+			{"Zero version", kafka.Message{Value: v2, Headers: envelopeHeaders(0)}, "unknown schema version: order.created v0"},
+			{"Negative version", kafka.Message{Value: v1, Headers: envelopeHeaders(-1)}, "unknown schema version"},
+			{"Wrong field type", kafka.Message{Value: wrongType, Headers: envelopeHeaders(2)}, "order_uid: should be string, got integer"},
+			{"Wrong order in array", kafka.Message{Value: []byte("[" + string(v2) + `, "order"]`)}, "order 1:"},
+			{"Other event type", kafka.Message{Value: v2, Headers: []kafka.Header{
+				{Key: HeaderSchemaVersion, Value: []byte("1")},
+				{Key: HeaderEventType, Value: []byte(schema.OrderStatusChanged)},
+			}}, "unexpected event type"},
+			{"Invalid version header", kafka.Message{Value: v2, Headers: []kafka.Header{
+				{Key: HeaderSchemaVersion, Value: []byte("two")},
+				{Key: HeaderEventType, Value: []byte(schema.OrderCreated)},
+			}}, "invalid x-schema-version header"},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := decodeMessage(tc.m)
+				require.ErrorIs(t, err, ErrSchemaMismatch)
+				assert.Contains(t, err.Error(), tc.error)
+			})
+		}
+
+		// Синтаксическая ошибка - не несоответствие схеме
+		_, err := decodeMessage(kafka.Message{Value: []byte("{"), Headers: envelopeHeaders(2)})
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrSchemaMismatch)
+	})
+}
+
+// Тестирует, что для каждой версии схемы заказа, кроме последней, есть переход на следующую
+func TestOrderUpgrades(t *testing.T) {
+	latest := schema.Default.Latest(schema.OrderCreated)
+	for v := 1; v < latest; v++ {
+		assert.Contains(t, orderUpgrades, v, "Version %d has no upgrade to %d", v, v+1)
+	}
+	assert.Len(t, orderUpgrades, latest-1)
+
+	// Заказ версии v после перехода соответствует схеме версии v+1
+	v1 := marshalV1(t, generator.MakeRandomOrder(1)[0])
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(v1, &fields))
+	require.NoError(t, upgradeOrderV1(fields))
+	upgraded, err := json.Marshal(fields)
+	require.NoError(t, err)
+
+	v2, err := schema.Default.Schema(schema.OrderCreated, 2)
+	require.NoError(t, err)
+	assert.NoError(t, v2.Validate(upgraded))
+
+	// Без перехода сообщение старой версии не подходит под схему, а не роняет консьюмер
+	delete(orderUpgrades, 1)
+	defer func() { orderUpgrades[1] = upgradeOrderV1 }()
+	_, err = decodeMessage(kafka.Message{Value: v1, Headers: envelopeHeaders(1)})
+	require.ErrorIs(t, err, ErrSchemaMismatch)
+	assert.Contains(t, err.Error(), "no upgrade from v1 to v2")
+}
+
+// Тестирует чтение конверта из заголовков
+func TestReadEnvelope(t *testing.T) {
+	envelope := NewEnvelope(schema.OrderCreated, "producer-1")
+	read, ok, err := ReadEnvelope(envelope.Headers())
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, envelope.EventType, read.EventType)
+	assert.Equal(t, envelope.Version, read.Version)
+	assert.Equal(t, envelope.ProducerID, read.ProducerID)
+	assert.True(t, envelope.Time.Equal(read.Time))
+	assert.Equal(t, time.UTC, envelope.Time.Location())
+
+	// Сообщения без конверта
+	_, ok, err = ReadEnvelope(nil)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Версия без типа события
+	_, _, err = ReadEnvelope([]kafka.Header{{Key: HeaderSchemaVersion, Value: []byte("2")}})
+	assert.ErrorContains(t, err, "x-event-type header is required")
+
+	headers := setHeader(envelope.Headers(), HeaderEventTime, "yesterday")
+	_, _, err = ReadEnvelope(headers)
+	assert.ErrorContains(t, err, "invalid x-event-time header")
+}
+
+// Тестирует отправку в DLQ сообщений, не подходящих под схему
+func TestHandleMessageSchema(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Schema mismatch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		m := kafka.Message{Value: []byte(`{"order_uid": 42}`), Headers: envelopeHeaders(2)}
+		before := testutil.ToFloat64(metrics.MessagesFailed.WithLabelValues(StageSchema))
+
+		mockRepo.EXPECT().SaveToDB(gomock.Any(), gomock.Any()).Times(0)
+		mockDLQ.EXPECT().
+			WriteMessages(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+				assert.Equal(t, m.Value, msgs[0].Value)
+				assert.Equal(t, StageSchema, headerValue(msgs[0].Headers, HeaderStage))
+				assert.Contains(t, headerValue(msgs[0].Headers, HeaderReason), "order_uid: should be string")
+				return nil
+			}).
+			Times(1)
+
+		require.NoError(t, handleMessage(ctx, m, mockDLQ, mockRepo, validation.Default, testRetryPolicy))
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.MessagesFailed.WithLabelValues(StageSchema)))
+	})
+
+	t.Run("Rejected orders of old version", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		orders := generator.MakeRandomOrder(2)
+		orders[1].CustomerID = ""
+		value := []byte("[" + string(marshalV1(t, orders[0])) + "," + string(marshalV1(t, orders[1])) + "]")
+
+		// Отклоненный заказ уходит в DLQ в последней версии, и конверт говорит о том же
+		mockRepo.EXPECT().SaveToDB(gomock.Len(1), ctx).Return(nil).Times(1)
+		mockDLQ.EXPECT().
+			WriteMessages(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+				latest := schema.Default.Latest(schema.OrderCreated)
+				assert.Equal(t, strconv.Itoa(latest), headerValue(msgs[0].Headers, HeaderSchemaVersion))
+				assert.Equal(t, "producer-1", headerValue(msgs[0].Headers, HeaderProducerID))
+
+				rejected, err := decodeMessage(msgs[0])
+				require.NoError(t, err)
+				require.Len(t, rejected, 1)
+				assert.Equal(t, orders[1].OofShard, rejected[0].OofShard)
+				return nil
+			}).
+			Times(1)
+
+		m := kafka.Message{Value: value, Headers: envelopeHeaders(1)}
+		require.NoError(t, handleMessage(ctx, m, mockDLQ, mockRepo, validation.Default, testRetryPolicy))
+	})
+}
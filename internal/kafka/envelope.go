@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"orders/internal/config"
+	"orders/internal/schema"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки конверта сообщения. Значение сообщения остается JSON событием,
+// а по версии схемы консьюмер выбирает, как его разобрать
+const (
+	HeaderSchemaVersion string = "x-schema-version"
+	HeaderProducerID    string = "x-producer-id"
+	HeaderEventType     string = "x-event-type"
+	HeaderEventTime     string = "x-event-time"
+)
+
+// Envelope - сведения о событии, которые передаются в заголовках сообщения
+type Envelope struct {
+	EventType  string
+	Version    int
+	ProducerID string
+	Time       time.Time
+}
+
+// NewEnvelope описывает событие eventType последней версии схемы из реестра
+func NewEnvelope(eventType, producerID string) Envelope {
+	return Envelope{
+		EventType:  eventType,
+		Version:    schema.Default.Latest(eventType),
+		ProducerID: producerID,
+		Time:       time.Now().UTC(),
+	}
+}
+
+// Headers возвращает заголовки конверта
+func (e Envelope) Headers() []kafka.Header {
+	return []kafka.Header{
+		{Key: HeaderEventType, Value: []byte(e.EventType)},
+		{Key: HeaderSchemaVersion, Value: []byte(strconv.Itoa(e.Version))},
+		{Key: HeaderProducerID, Value: []byte(e.ProducerID)},
+		{Key: HeaderEventTime, Value: []byte(e.Time.Format(time.RFC3339Nano))},
+	}
+}
+
+// ReadEnvelope читает конверт из заголовков сообщения. Для сообщений,
+// записанных до появления конверта, возвращает false
+func ReadEnvelope(headers []kafka.Header) (Envelope, bool, error) {
+	version := headerValue(headers, HeaderSchemaVersion)
+	if version == "" {
+		return Envelope{}, false, nil
+	}
+
+	var e Envelope
+	var err error
+	e.Version, err = strconv.Atoi(version)
+	if err != nil {
+		return Envelope{}, true, fmt.Errorf("invalid %s header %q", HeaderSchemaVersion, version)
+	}
+	e.EventType = headerValue(headers, HeaderEventType)
+	if e.EventType == "" {
+		return Envelope{}, true, fmt.Errorf("%s header is required with %s", HeaderEventType, HeaderSchemaVersion)
+	}
+	e.ProducerID = headerValue(headers, HeaderProducerID)
+	if eventTime := headerValue(headers, HeaderEventTime); eventTime != "" {
+		e.Time, err = time.Parse(time.RFC3339Nano, eventTime)
+		if err != nil {
+			return Envelope{}, true, fmt.Errorf("invalid %s header %q", HeaderEventTime, eventTime)
+		}
+	}
+	return e, true, nil
+}
+
+// ProducerID возвращает id, которым сервис подписывает свои сообщения:
+// kafka.producer_id из конфигурации или имя хоста
+func ProducerID(cfg config.Kafka) string {
+	if cfg.ProducerID != "" {
+		return cfg.ProducerID
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return "orders"
+}
+
+// setHeader возвращает копию заголовков, в которой значение key заменено на value
+func setHeader(headers []kafka.Header, key, value string) []kafka.Header {
+	result := make([]kafka.Header, 0, len(headers)+1)
+	for _, h := range headers {
+		if h.Key != key {
+			result = append(result, h)
+		}
+	}
+	return append(result, kafka.Header{Key: key, Value: []byte(value)})
+}
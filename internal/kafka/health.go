@@ -0,0 +1,172 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Пороги готовности по умолчанию: единичные сбои чтения и обработки консьюмер
+// переживает сам, повторяя попытки, поэтому готовность пропадает, только
+// если сбои идут подряд или не прекращаются дольше окна
+const (
+	DefaultMaxFetchFailures = 3
+	DefaultMaxFailingFor    = 30 * time.Second
+)
+
+// ConsumerState отражает работу горутины StartConsuming для проверки готовности
+type ConsumerState struct {
+	// MaxFetchFailures - число неудачных чтений подряд, после которого
+	// консьюмер не готов, 0 - DefaultMaxFetchFailures
+	MaxFetchFailures int
+	// MaxFailingFor - сколько могут длиться сбои чтения или стоять партиция
+	// из-за сообщения, которое не удается обработать, 0 - DefaultMaxFailingFor
+	MaxFailingFor time.Duration
+
+	running       atomic.Bool
+	fetchFailures atomic.Int64
+	failingSince  atomic.Int64
+	lastMessage   atomic.Int64
+
+	stallsMu sync.Mutex
+	// stalls - сообщения, которые пока не удалось обработать, и время первой
+	// неудачи. Смещения их партиций не коммитятся, пока они не обработаны
+	stalls map[stallKey]time.Time
+}
+
+type stallKey struct {
+	partition int
+	offset    int64
+}
+
+func (s *ConsumerState) Running() bool {
+	return s.running.Load()
+}
+
+// FetchFailures возвращает число неудачных чтений из брокера подряд
+func (s *ConsumerState) FetchFailures() int {
+	return int(s.fetchFailures.Load())
+}
+
+// fetchFailed отмечает очередное неудачное чтение подряд
+func (s *ConsumerState) fetchFailed(failures int) {
+	if failures == 1 {
+		s.failingSince.Store(time.Now().UnixNano())
+	}
+	s.fetchFailures.Store(int64(failures))
+}
+
+// fetched отмечает успешное чтение сообщения
+func (s *ConsumerState) fetched() {
+	s.fetchFailures.Store(0)
+	s.failingSince.Store(0)
+	s.lastMessage.Store(time.Now().UnixNano())
+}
+
+// stalled отмечает, что сообщение m не удалось обработать и его партиция стоит
+func (s *ConsumerState) stalled(m kafka.Message) {
+	s.stallsMu.Lock()
+	defer s.stallsMu.Unlock()
+
+	if s.stalls == nil {
+		s.stalls = make(map[stallKey]time.Time)
+	}
+	key := stallKey{m.Partition, m.Offset}
+	if _, ok := s.stalls[key]; !ok {
+		s.stalls[key] = time.Now()
+	}
+}
+
+// resumed отмечает, что сообщение m обработано или консьюмер останавливается
+func (s *ConsumerState) resumed(m kafka.Message) {
+	s.stallsMu.Lock()
+	defer s.stallsMu.Unlock()
+	delete(s.stalls, stallKey{m.Partition, m.Offset})
+}
+
+// oldestStall возвращает сообщение, которое не удается обработать дольше всех
+func (s *ConsumerState) oldestStall() (stallKey, time.Time, bool) {
+	s.stallsMu.Lock()
+	defer s.stallsMu.Unlock()
+
+	var oldest stallKey
+	var since time.Time
+	for key, t := range s.stalls {
+		if since.IsZero() || t.Before(since) {
+			oldest, since = key, t
+		}
+	}
+	return oldest, since, !since.IsZero()
+}
+
+// LastMessage возвращает время получения последнего сообщения
+func (s *ConsumerState) LastMessage() time.Time {
+	nanos := s.lastMessage.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// Check сообщает об ошибке, если горутина чтения завершилась, не может
+// получить сообщения из брокера MaxFetchFailures раз подряд либо дольше
+// MaxFailingFor или дольше MaxFailingFor не может обработать сообщение,
+// из-за чего смещение его партиции не коммитится
+func (s *ConsumerState) Check(ctx context.Context) error {
+	if !s.Running() {
+		return errors.New("consumer is not running")
+	}
+
+	maxFailingFor := s.MaxFailingFor
+	if maxFailingFor <= 0 {
+		maxFailingFor = DefaultMaxFailingFor
+	}
+	if stall, since, ok := s.oldestStall(); ok {
+		if stalledFor := time.Since(since); stalledFor >= maxFailingFor {
+			return fmt.Errorf("partition %d is stalled at offset %d for %s: message can't be handled",
+				stall.partition, stall.offset, stalledFor.Round(time.Second))
+		}
+	}
+
+	failures := s.FetchFailures()
+	if failures == 0 {
+		return nil
+	}
+
+	maxFailures := s.MaxFetchFailures
+	if maxFailures <= 0 {
+		maxFailures = DefaultMaxFetchFailures
+	}
+	if failures >= maxFailures {
+		return fmt.Errorf("consumer failed to fetch messages %d times in a row", failures)
+	}
+
+	if since := s.failingSince.Load(); since != 0 {
+		if failingFor := time.Since(time.Unix(0, since)); failingFor >= maxFailingFor {
+			return fmt.Errorf("consumer has been failing to fetch messages for %s", failingFor.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// PingBrokers проверяет, что хотя бы один из брокеров принимает соединения
+func PingBrokers(ctx context.Context, brokers []string) error {
+	var errs []error
+	for _, address := range brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", address)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return conn.Close()
+	}
+	if len(errs) == 0 {
+		return errors.New("no Kafka brokers configured")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Размер очереди каждого обработчика. Когда очередь заполнена,
+// чтение новых сообщений ждет, пока обработчик ее разберет
+const workerQueueSize = 16
+
+// trackedMessage - полученное сообщение, ожидающее коммита
+type trackedMessage struct {
+	msg       kafka.Message
+	partition *partitionOffsets
+	done      bool
+	ok        bool
+}
+
+// partitionOffsets - сообщения партиции в порядке получения
+type partitionOffsets struct {
+	pending []*trackedMessage
+
+	commitMu  sync.Mutex
+	committed int64
+}
+
+// offsetTracker следит за обработкой сообщений, которые обрабатываются
+// параллельно, и позволяет коммитить смещение партиции только после того,
+// как обработаны все полученные до него сообщения этой партиции
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int]*partitionOffsets)}
+}
+
+// track запоминает сообщение. Вызывается в порядке получения сообщений
+func (t *offsetTracker) track(m kafka.Message) *trackedMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[m.Partition]
+	if !ok {
+		p = &partitionOffsets{committed: -1}
+		t.partitions[m.Partition] = p
+	}
+	tm := &trackedMessage{msg: m, partition: p}
+	p.pending = append(p.pending, tm)
+	return tm
+}
+
+// done отмечает сообщение обработанным и убирает из очереди партиции все
+// успешно обработанные сообщения с ее начала. Возвращает последнее из них,
+// которое можно коммитить, и их число. Сообщение с ошибкой остается в начале
+// очереди, и смещение не перешагнет через него: обработчик повторяет такое
+// сообщение, пока оно не будет обработано, и сдается только при остановке
+// консьюмера, так что после перезапуска оно будет прочитано заново
+func (t *offsetTracker) done(tm *trackedMessage, ok bool) (*kafka.Message, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tm.done, tm.ok = true, ok
+	p := tm.partition
+
+	var last *kafka.Message
+	i := 0
+	for ; i < len(p.pending) && p.pending[i].done && p.pending[i].ok; i++ {
+		last = &p.pending[i].msg
+		p.pending[i] = nil
+	}
+	p.pending = p.pending[i:]
+	return last, i
+}
+
+// commit коммитит сообщение m, если смещение партиции еще не ушло дальше.
+// Коммиты одной партиции идут по очереди, чтобы смещение не откатилось назад
+func (p *partitionOffsets) commit(ctx context.Context, c MessagesConsumer, m kafka.Message) error {
+	p.commitMu.Lock()
+	defer p.commitMu.Unlock()
+
+	if m.Offset <= p.committed {
+		return nil
+	}
+	if err := c.CommitMessages(ctx, m); err != nil {
+		return err
+	}
+	p.committed = m.Offset
+	return nil
+}
+
+// workerPool распределяет сообщения между обработчиками по ключу: сообщения
+// с одним ключом попадают к одному обработчику и обрабатываются по порядку
+type workerPool struct {
+	queues []chan *trackedMessage
+	wg     sync.WaitGroup
+}
+
+func newWorkerPool(workers int, handle func(tm *trackedMessage)) *workerPool {
+	p := &workerPool{queues: make([]chan *trackedMessage, max(workers, 1))}
+	for i := range p.queues {
+		queue := make(chan *trackedMessage, workerQueueSize)
+		p.queues[i] = queue
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for tm := range queue {
+				handle(tm)
+			}
+		}()
+	}
+	return p
+}
+
+func (p *workerPool) dispatch(tm *trackedMessage) {
+	p.queues[p.worker(tm.msg)] <- tm
+}
+
+// worker выбирает обработчик по ключу сообщения. Сообщения без ключа
+// (прежний формат, где все заказы шли одним сообщением) распределяются
+// по партиции и обрабатываются в ее порядке
+func (p *workerPool) worker(m kafka.Message) int {
+	h := fnv.New32a()
+	if len(m.Key) > 0 {
+		h.Write(m.Key)
+	} else {
+		h.Write([]byte(strconv.Itoa(m.Partition)))
+	}
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// stop дожидается обработки всех уже распределенных сообщений
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
@@ -0,0 +1,279 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"orders/internal/generator"
+	"orders/internal/mocks"
+	"orders/internal/validation"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// Тестирует выбор смещения для коммита при обработке сообщений не по порядку
+func TestOffsetTracker(t *testing.T) {
+	tracker := newOffsetTracker()
+	track := func(partition int, offset int64) *trackedMessage {
+		return tracker.track(kafka.Message{Partition: partition, Offset: offset})
+	}
+
+	first, second, third := track(0, 10), track(0, 11), track(0, 12)
+	other := track(1, 5)
+
+	// Пока не обработано первое сообщение партиции, коммитить нечего
+	commit, n := tracker.done(second, true)
+	assert.Nil(t, commit)
+	assert.Zero(t, n)
+
+	commit, n = tracker.done(first, true)
+	require.NotNil(t, commit)
+	assert.Equal(t, int64(11), commit.Offset, "Offset should cover all handled messages")
+	assert.Equal(t, 2, n)
+
+	// Партиции не зависят друг от друга
+	commit, n = tracker.done(other, true)
+	require.NotNil(t, commit)
+	assert.Equal(t, 1, commit.Partition)
+	assert.Equal(t, int64(5), commit.Offset)
+	assert.Equal(t, 1, n)
+
+	// Сообщение, брошенное при остановке, не коммитится, и смещение через него не перешагивает
+	commit, n = tracker.done(third, false)
+	assert.Nil(t, commit)
+	assert.Zero(t, n)
+
+	commit, n = tracker.done(track(0, 13), true)
+	assert.Nil(t, commit, "Offset should not go past the failed message")
+	assert.Zero(t, n)
+
+	// Остальные партиции коммитятся как обычно
+	commit, _ = tracker.done(track(1, 6), true)
+	require.NotNil(t, commit)
+	assert.Equal(t, int64(6), commit.Offset)
+}
+
+// Тестирует повторы сообщения, которое не удалось отправить в DLQ: смещение
+// не уходит дальше него, пока оно не обработано, а партиция видна как вставшая
+func TestStartConsumingFailedMessage(t *testing.T) {
+	// Второе сообщение не разбирается и должно уйти в DLQ
+	var messages []kafka.Message
+	for i := range 4 {
+		value, err := json.Marshal(generator.MakeRandomOrder(1)[0])
+		require.NoError(t, err)
+		if i == 1 {
+			value = []byte("definitely not a JSON")
+		}
+		messages = append(messages, kafka.Message{Topic: "orders", Offset: int64(i), Value: value})
+	}
+
+	// setup возвращает консьюмер с сообщениями messages и список закоммиченных смещений
+	setup := func(t *testing.T, ctrl *gomock.Controller) (*mocks.MockMessagesConsumer, *mocks.MockOrdersRepository, *[]int64) {
+		mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		next := 0
+		mockConsumer.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (kafka.Message, error) {
+			if next == len(messages) {
+				return kafka.Message{}, io.EOF
+			}
+			next++
+			return messages[next-1], nil
+		}).AnyTimes()
+		mockRepo.EXPECT().SaveToDB(gomock.Len(1), gomock.Any()).Return(nil).Times(len(messages) - 1)
+
+		var committed []int64
+		mockConsumer.EXPECT().CommitMessages(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+			for _, m := range msgs {
+				committed = append(committed, m.Offset)
+			}
+			return nil
+		}).AnyTimes()
+		return mockConsumer, mockRepo, &committed
+	}
+
+	t.Run("Retried until handled", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockConsumer, mockRepo, committed := setup(t, ctrl)
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		state := &ConsumerState{MaxFailingFor: time.Nanosecond}
+
+		// DLQ недоступна на первых попытках, и пока она недоступна, партиция стоит
+		attempts := 0
+		mockDLQ.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+			attempts++
+			if attempts == 1 {
+				return errors.New("Simulated DLQ error")
+			}
+			if attempts == 2 {
+				assert.Equal(t, []int64{0}, *committed, "Offset should not go past the failed message")
+				assert.ErrorContains(t, state.Check(ctx), "partition 0 is stalled at offset 1")
+				return errors.New("Simulated DLQ error")
+			}
+			return nil
+		}).Times(3)
+
+		StartConsuming(context.Background(), mockConsumer, mockDLQ, mockRepo, validation.Default, testRetryPolicy, 1, state, slog.Default())
+
+		// После обработки коммиты продолжаются, а партиция больше не стоит
+		assert.Equal(t, []int64{0, 1, 2, 3}, *committed)
+		_, _, stalled := state.oldestStall()
+		assert.False(t, stalled)
+	})
+
+	t.Run("Stopped while retrying", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockConsumer, mockRepo, committed := setup(t, ctrl)
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+
+		// DLQ так и не становится доступна, и повторы прерывает остановка
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		attempts := 0
+		mockDLQ.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, ...kafka.Message) error {
+			if attempts++; attempts == 3 {
+				stop()
+			}
+			return errors.New("Simulated DLQ error")
+		}).MinTimes(3)
+
+		StartConsuming(ctx, mockConsumer, mockDLQ, mockRepo, validation.Default, testRetryPolicy, 1, &ConsumerState{}, slog.Default())
+
+		// Закоммичено только сообщение до неудачного, следующие прочитаются повторно
+		assert.Equal(t, []int64{0}, *committed)
+	})
+}
+
+// Тестирует, что смещение партиции не коммитится назад
+func TestPartitionOffsetsCommit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+	mockConsumer.EXPECT().CommitMessages(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	p := &partitionOffsets{committed: -1}
+	ctx := context.Background()
+	require.NoError(t, p.commit(ctx, mockConsumer, kafka.Message{Offset: 3}))
+	require.NoError(t, p.commit(ctx, mockConsumer, kafka.Message{Offset: 7}))
+	require.NoError(t, p.commit(ctx, mockConsumer, kafka.Message{Offset: 5}))
+	assert.Equal(t, int64(7), p.committed)
+}
+
+// Тестирует, что сообщения с одним ключом обрабатываются по порядку
+func TestWorkerPoolKeyOrder(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[string][]int64)
+
+	pool := newWorkerPool(4, func(tm *trackedMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[string(tm.msg.Key)] = append(handled[string(tm.msg.Key)], tm.msg.Offset)
+	})
+
+	keys := []string{"a", "b", "c", "d", "e"}
+	for offset := range int64(100) {
+		key := keys[offset%int64(len(keys))]
+		pool.dispatch(&trackedMessage{msg: kafka.Message{Key: []byte(key), Offset: offset}})
+	}
+	pool.stop()
+
+	for _, key := range keys {
+		require.Len(t, handled[key], 20)
+		assert.IsIncreasing(t, handled[key], "Messages with key %s should be handled in order", key)
+	}
+
+	// Сообщения без ключа одной партиции попадают к одному обработчику
+	assert.Equal(t, pool.worker(kafka.Message{Partition: 2, Offset: 1}), pool.worker(kafka.Message{Partition: 2, Offset: 2}))
+}
+
+// Тестирует параллельное чтение нескольких партиций через StartConsuming
+func TestStartConsumingPartitions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+	mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+	mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+	// По три заказа на каждого из двух покупателей в каждой из трех партиций
+	var messages []kafka.Message
+	offsets := make(map[int]int64)
+	for i := range 18 {
+		order := generator.MakeRandomOrder(1)[0]
+		partition := i % 3
+		order.CustomerID = []string{"alice", "bob"}[i/3%2]
+		value, err := json.Marshal(order)
+		require.NoError(t, err)
+
+		messages = append(messages, kafka.Message{
+			Topic:     "orders",
+			Partition: partition,
+			Offset:    offsets[partition],
+			Key:       []byte(order.CustomerID),
+			Value:     value,
+		})
+		offsets[partition]++
+	}
+
+	next := 0
+	mockConsumer.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (kafka.Message, error) {
+		if next == len(messages) {
+			return kafka.Message{}, io.EOF
+		}
+		next++
+		return messages[next-1], nil
+	}).AnyTimes()
+
+	var mu sync.Mutex
+	saved := make(map[string][]string)
+	mockRepo.EXPECT().SaveToDB(gomock.Len(1), gomock.Any()).DoAndReturn(func(orders []*generator.Order, ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		saved[orders[0].CustomerID] = append(saved[orders[0].CustomerID], orders[0].OrderUID)
+		return nil
+	}).Times(len(messages))
+
+	committed := make(map[int]int64)
+	mockConsumer.EXPECT().CommitMessages(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, m := range msgs {
+			assert.Greater(t, m.Offset, committed[m.Partition]-1, "Offsets should not go back")
+			committed[m.Partition] = m.Offset + 1
+		}
+		return nil
+	}).MinTimes(3)
+
+	// StartConsuming возвращается после обработки всех полученных сообщений
+	StartConsuming(context.Background(), mockConsumer, mockDLQ, mockRepo, validation.Default, testRetryPolicy, 4, &ConsumerState{}, slog.Default())
+
+	// Все партиции закоммичены до конца
+	assert.Equal(t, offsets, committed)
+
+	// Заказы каждого покупателя сохранены в порядке публикации
+	for customer, uids := range saved {
+		var expected []string
+		for _, m := range messages {
+			if string(m.Key) == customer {
+				var order generator.Order
+				require.NoError(t, json.Unmarshal(m.Value, &order))
+				expected = append(expected, order.OrderUID)
+			}
+		}
+		assert.Equal(t, expected, uids, "Orders of %s should be saved in order", customer)
+	}
+}
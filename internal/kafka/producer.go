@@ -2,31 +2,75 @@ package kafka
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+
+	"orders/internal/config"
+	"orders/internal/generator"
+	"orders/internal/logging"
+	"orders/internal/schema"
 
 	"github.com/segmentio/kafka-go"
 )
 
-func CreateWriter() *kafka.Writer {
+// MessageKey - поле заказа, которое служит ключом сообщения. Сообщения
+// с одним ключом попадают в одну партицию и обрабатываются по порядку
+type MessageKey string
+
+const (
+	KeyOrderUID   MessageKey = "order_uid"
+	KeyCustomerID MessageKey = "customer_id"
+)
+
+// Of возвращает ключ сообщения с заказом order
+func (k MessageKey) Of(order *generator.Order) []byte {
+	if k == KeyCustomerID {
+		return []byte(order.CustomerID)
+	}
+	return []byte(order.OrderUID)
+}
+
+// CreateWriter создает продюсера топика с заказами. Партиция выбирается
+// по хэшу ключа, поэтому заказы с одним ключом не обгоняют друг друга
+func CreateWriter(cfg config.Kafka) *kafka.Writer {
 	w := &kafka.Writer{
-		Addr:     kafka.TCP(address),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+		Addr:     kafka.TCP(cfg.Brokers...),
+		Topic:    cfg.Topic,
+		Balancer: &kafka.Hash{},
 	}
 	return w
 }
 
-func WriteMessage(p MessagesProducer, ctx context.Context, msg []byte) error {
-	err := p.WriteMessages(ctx,
-		kafka.Message{
-			Key:   nil,
-			Value: []byte(msg),
-		},
-	)
-	if err != nil {
-		log.Println("Failed to write message:", err)
-		return err
+// WriteOrders публикует каждый заказ отдельным сообщением с ключом key
+// и конвертом order.created от отправителя producerID. Все сообщения
+// отправляются одним запросом
+func WriteOrders(p MessagesProducer, ctx context.Context, orders []*generator.Order, key MessageKey, producerID string) error {
+	headers := append(requestHeaders(ctx), NewEnvelope(schema.OrderCreated, producerID).Headers()...)
+	msgs := make([]kafka.Message, 0, len(orders))
+	for _, order := range orders {
+		value, err := json.Marshal(order)
+		if err != nil {
+			logging.FromContext(ctx).Error("Error marshalling order", "order_uid", order.OrderUID, "error", err)
+			return err
+		}
+		msgs = append(msgs, kafka.Message{
+			Key:     key.Of(order),
+			Value:   value,
+			Headers: headers,
+		})
 	}
 
+	if err := p.WriteMessages(ctx, msgs...); err != nil {
+		logging.FromContext(ctx).Error("Failed to write orders", "orders", len(orders), "error", err)
+		return err
+	}
 	return nil
 }
+
+// requestHeaders передает id HTTP запроса, чтобы связать логи обработки сообщения с ним
+func requestHeaders(ctx context.Context) []kafka.Header {
+	var headers []kafka.Header
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		headers = append(headers, kafka.Header{Key: logging.HeaderRequestID, Value: []byte(requestID)})
+	}
+	return headers
+}
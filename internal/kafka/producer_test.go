@@ -2,63 +2,78 @@ package kafka
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
+	"orders/internal/generator"
+	"orders/internal/logging"
 	"orders/internal/mocks"
+	"orders/internal/schema"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
-func TestWriteMessage(t *testing.T) {
-	t.Run("Successful write", func(t *testing.T) {
-		ctrl := gomock.NewController(t)
-		defer ctrl.Finish()
+// Тестирует публикацию заказов отдельными сообщениями с ключом
+func TestWriteOrders(t *testing.T) {
+	orders := generator.MakeRandomOrder(3)
 
-		mockProducer := mocks.NewMockMessagesProducer(ctrl)
+	for _, key := range []MessageKey{KeyOrderUID, KeyCustomerID} {
+		t.Run(string(key), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
 
-		ctx := context.Background()
-		msg := []byte("Hello there, this is a test for Kafka writer !")
-		expectedMsg := kafka.Message{
-			Key:   nil,
-			Value: msg,
-		}
+			mockProducer := mocks.NewMockMessagesProducer(ctrl)
+			mockProducer.EXPECT().
+				WriteMessages(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+					// Все заказы уходят одним запросом, но каждый своим сообщением
+					require.Len(t, msgs, len(orders))
+					for i, msg := range msgs {
+						var order generator.Order
+						require.NoError(t, json.Unmarshal(msg.Value, &order))
+						assert.Equal(t, orders[i].OrderUID, order.OrderUID)
+						assert.Equal(t, key.Of(orders[i]), msg.Key)
+						assert.Equal(t, "request-7", headerValue(msg.Headers, logging.HeaderRequestID))
 
-		mockProducer.EXPECT().
-			WriteMessages(ctx, gomock.Any()).
-			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
-				if len(msgs) != 1 {
-					t.Errorf("Expected 1 message, got %d", len(msgs))
-				}
-				if string(msgs[0].Value) != string(expectedMsg.Value) {
-					t.Errorf("Message content missmatch")
-				}
-				return nil
-			}).
-			Times(1)
+						// Конверт описывает последнюю версию схемы, и заказ ей соответствует
+						envelope, ok, err := ReadEnvelope(msg.Headers)
+						require.NoError(t, err)
+						require.True(t, ok)
+						assert.Equal(t, schema.OrderCreated, envelope.EventType)
+						assert.Equal(t, schema.Default.Latest(schema.OrderCreated), envelope.Version)
+						assert.Equal(t, "producer-1", envelope.ProducerID)
+						assert.WithinDuration(t, time.Now(), envelope.Time, time.Minute)
 
-		err := WriteMessage(mockProducer, ctx, msg)
-		assert.NoError(t, err, "WriteMessage should not return an error on successful mock call")
-	})
+						s, err := schema.Default.Schema(envelope.EventType, envelope.Version)
+						require.NoError(t, err)
+						assert.NoError(t, s.Validate(msg.Value))
+					}
+					return nil
+				})
+
+			ctx := logging.WithRequestID(context.Background(), "request-7")
+			require.NoError(t, WriteOrders(mockProducer, ctx, orders, key, "producer-1"))
+		})
+	}
+
+	// Ключом служит uid заказа, если не выбран customer_id
+	assert.Equal(t, []byte(orders[0].OrderUID), KeyOrderUID.Of(orders[0]))
+	assert.Equal(t, []byte(orders[0].CustomerID), KeyCustomerID.Of(orders[0]))
 
 	t.Run("Failed write", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
 		mockProducer := mocks.NewMockMessagesProducer(ctrl)
-		ctx := context.Background()
-		msg := []byte("Another test for error check")
 		expectedError := errors.New("Simulated Kafka error")
+		mockProducer.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(expectedError)
 
-		mockProducer.EXPECT().
-			WriteMessages(gomock.Any(), gomock.Any()).
-			Return(expectedError).
-			Times(1)
-
-		err := WriteMessage(mockProducer, ctx, msg)
-		assert.Error(t, err, "WriteMessage should return an error on failed mock call")
-		assert.Equal(t, expectedError, err, "Returned error should match expected one")
+		err := WriteOrders(mockProducer, context.Background(), orders, KeyOrderUID, "producer-1")
+		assert.Equal(t, expectedError, err)
 	})
 }
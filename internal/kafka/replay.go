@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"orders/internal/config"
+	"orders/internal/repository"
+	"orders/internal/validation"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// CreateReplayReader создает ридер партиции partition топика с заказами без группы
+// консьюмеров, начинающий чтение со смещения offset. Смещения группы сервиса он не меняет
+func CreateReplayReader(cfg config.Kafka, partition int, offset int64) (*kafka.Reader, error) {
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   cfg.Brokers,
+		Topic:     cfg.Topic,
+		Partition: partition,
+	})
+	if err := r.SetOffset(offset); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("Error setting replay offset: %w", err)
+	}
+	return r, nil
+}
+
+// ReplayStats - итог повторной обработки сообщений
+type ReplayStats struct {
+	Messages int
+	Failed   int
+}
+
+// Replay заново обрабатывает сообщения так же, как консьюмер сервиса:
+// сохраняет заказы, а то, что сохранить нельзя, отправляет в DLQ.
+// Чтение идет до смещения end не включительно, сообщения не коммитятся
+func Replay(ctx context.Context, c MessagesConsumer, dlq MessagesProducer, repo repository.OrdersRepository, v validation.OrderValidator, retry RetryPolicy, end int64, logger *slog.Logger) (ReplayStats, error) {
+	var stats ReplayStats
+	for {
+		m, err := c.FetchMessage(ctx)
+		if err != nil {
+			return stats, fmt.Errorf("Error reading message: %w", err)
+		}
+		if m.Offset >= end {
+			return stats, nil
+		}
+		stats.Messages++
+
+		msgCtx, msgLogger := messageContext(ctx, m, logger)
+
+		if err := handleMessage(msgCtx, m, dlq, repo, v, retry); err != nil {
+			stats.Failed++
+			msgLogger.Error("Error replaying message", "error", err)
+		} else {
+			msgLogger.Info("Replayed message")
+		}
+
+		if m.Offset+1 >= end {
+			return stats, nil
+		}
+	}
+}
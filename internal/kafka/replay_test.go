@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"orders/internal/generator"
+	"orders/internal/mocks"
+	"orders/internal/validation"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// Тестирует повторную обработку сообщений до заданного смещения
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	value, err := json.Marshal(generator.MakeRandomOrder(1))
+	require.NoError(t, err)
+
+	t.Run("Stops at end offset", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		// Сообщения 5 и 6 сохраняются, до 7 дело не доходит
+		gomock.InOrder(
+			mockConsumer.EXPECT().FetchMessage(ctx).Return(kafka.Message{Offset: 5, Value: value}, nil),
+			mockConsumer.EXPECT().FetchMessage(ctx).Return(kafka.Message{Offset: 6, Value: value}, nil),
+		)
+		mockRepo.EXPECT().SaveToDB(gomock.Len(1), gomock.Any()).Return(nil).Times(2)
+		// Повторная обработка не коммитит сообщения
+		mockConsumer.EXPECT().CommitMessages(gomock.Any(), gomock.Any()).Times(0)
+
+		stats, err := Replay(ctx, mockConsumer, mockDLQ, mockRepo, validation.Default, testRetryPolicy, 7, logger)
+		require.NoError(t, err)
+		assert.Equal(t, ReplayStats{Messages: 2}, stats)
+	})
+
+	t.Run("Failed message does not stop replay", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+		mockDLQ := mocks.NewMockMessagesProducer(ctrl)
+		mockRepo := mocks.NewMockOrdersRepository(ctrl)
+
+		gomock.InOrder(
+			mockConsumer.EXPECT().FetchMessage(ctx).Return(kafka.Message{Offset: 0, Value: []byte("not a JSON")}, nil),
+			mockConsumer.EXPECT().FetchMessage(ctx).Return(kafka.Message{Offset: 1, Value: value}, nil),
+		)
+		// Нечитаемое сообщение не удается отправить и в DLQ
+		mockDLQ.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(errors.New("Simulated Kafka error"))
+		mockRepo.EXPECT().SaveToDB(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		stats, err := Replay(ctx, mockConsumer, mockDLQ, mockRepo, validation.Default, testRetryPolicy, 2, logger)
+		require.NoError(t, err)
+		assert.Equal(t, ReplayStats{Messages: 2, Failed: 1}, stats)
+	})
+
+	t.Run("Broker error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockConsumer := mocks.NewMockMessagesConsumer(ctrl)
+		mockConsumer.EXPECT().FetchMessage(ctx).Return(kafka.Message{}, errors.New("Simulated broker error"))
+
+		_, err := Replay(ctx, mockConsumer, nil, nil, validation.Default, testRetryPolicy, 10, logger)
+		assert.Error(t, err)
+	})
+}
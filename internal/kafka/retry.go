@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// RetryPolicy описывает повторные попытки обработки сообщения:
+// задержка между попытками растет экспоненциально от BaseBackoff до
+// MaxBackoff и случайно отклоняется на долю Jitter в обе стороны
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Jitter      float64
+}
+
+// Backoff возвращает задержку перед повтором после неудачной попытки attempt (начиная с 1)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseBackoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay = time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+	}
+	return delay
+}
+
+// retryUntilHandled повторяет handle после неудачной попытки с ошибкой err, пока
+// сообщение m не будет обработано или не отменен ctx при остановке консьюмера.
+// Смещение партиции не коммитится дальше необработанного сообщения, поэтому
+// пока идут повторы, партиция стоит, а ConsumerState сообщает об этом в /readyz.
+// Возвращает ошибку последней попытки, если повторы прервала остановка
+func retryUntilHandled(ctx context.Context, m kafka.Message, retry RetryPolicy, state *ConsumerState, logger *slog.Logger, err error, handle func() error) error {
+	state.stalled(m)
+	defer state.resumed(m)
+
+	for attempt := 1; err != nil; attempt++ {
+		delay := retry.Backoff(attempt)
+		logger.Error("Error handling message, partition is stalled until it is handled", "attempt", attempt, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = handle()
+	}
+	logger.Info("Message is handled, partition is resumed")
+	return nil
+}
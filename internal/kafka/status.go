@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+
+	"orders/internal/config"
+	"orders/internal/logging"
+	"orders/internal/schema"
+	"orders/internal/status"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func CreateStatusWriter(cfg config.Kafka) *kafka.Writer {
+	w := &kafka.Writer{
+		Addr:     kafka.TCP(cfg.Brokers...),
+		Topic:    cfg.StatusTopic,
+		Balancer: &kafka.Hash{},
+	}
+	return w
+}
+
+// PublishStatusChange публикует событие смены статуса заказа с конвертом
+// order.status_changed. Ключом служит uid заказа, поэтому события одного
+// заказа попадают в одну партицию по порядку
+func PublishStatusChange(p MessagesProducer, ctx context.Context, change *status.Change, producerID string) error {
+	value, err := json.Marshal(change)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error marshalling status change", "error", err)
+		return err
+	}
+
+	err = p.WriteMessages(ctx,
+		kafka.Message{
+			Key:     []byte(change.OrderUID),
+			Value:   value,
+			Headers: append(requestHeaders(ctx), NewEnvelope(schema.OrderStatusChanged, producerID).Headers()...),
+		},
+	)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to write status change", "order_uid", change.OrderUID, "error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"orders/internal/logging"
+	"orders/internal/mocks"
+	"orders/internal/schema"
+	"orders/internal/status"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// Тестирует публикацию событий смены статуса
+func TestPublishStatusChange(t *testing.T) {
+	change := &status.Change{
+		OrderUID:  "order-1",
+		From:      status.Created,
+		To:        status.Paid,
+		Reason:    "payment received",
+		ChangedAt: time.Date(2025, 10, 8, 18, 26, 22, 0, time.UTC),
+	}
+
+	t.Run("Successful write", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockProducer := mocks.NewMockMessagesProducer(ctrl)
+		mockProducer.EXPECT().
+			WriteMessages(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, msgs ...kafka.Message) error {
+				require.Len(t, msgs, 1)
+				// События одного заказа идут с одним ключом
+				assert.Equal(t, "order-1", string(msgs[0].Key))
+				assert.Equal(t, "request-42", headerValue(msgs[0].Headers, logging.HeaderRequestID))
+
+				var published status.Change
+				require.NoError(t, json.Unmarshal(msgs[0].Value, &published))
+				assert.Equal(t, *change, published)
+
+				envelope, ok, err := ReadEnvelope(msgs[0].Headers)
+				require.NoError(t, err)
+				require.True(t, ok)
+				assert.Equal(t, schema.OrderStatusChanged, envelope.EventType)
+				assert.Equal(t, "producer-1", envelope.ProducerID)
+				s, err := schema.Default.Schema(envelope.EventType, envelope.Version)
+				require.NoError(t, err)
+				assert.NoError(t, s.Validate(msgs[0].Value))
+				return nil
+			}).
+			Times(1)
+
+		ctx := logging.WithRequestID(context.Background(), "request-42")
+		assert.NoError(t, PublishStatusChange(mockProducer, ctx, change, "producer-1"))
+	})
+
+	t.Run("Failed write", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockProducer := mocks.NewMockMessagesProducer(ctrl)
+		expectedError := errors.New("Simulated Kafka error")
+		mockProducer.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(expectedError).Times(1)
+
+		err := PublishStatusChange(mockProducer, context.Background(), change, "producer-1")
+		assert.Equal(t, expectedError, err)
+	})
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирует создание логгера с разными форматами и уровнями
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "warn")
+	require.NoError(t, err)
+
+	logger.Info("Should be skipped")
+	logger.Warn("Should be written", "order_uid", "some-uid")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry), "Only one JSON entry should be written")
+	assert.Equal(t, "Should be written", entry["msg"])
+	assert.Equal(t, "some-uid", entry["order_uid"])
+
+	_, err = New(&buf, "xml", "info")
+	assert.Error(t, err, "Unknown format should be rejected")
+	_, err = New(&buf, "text", "verbose")
+	assert.Error(t, err, "Unknown level should be rejected")
+}
+
+// Тестирует присвоение id запросу и его попадание в логи
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	require.NoError(t, err)
+
+	var gotID string
+	handler := Middleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = RequestID(r.Context())
+		FromContext(r.Context()).Info("Inside handler")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	t.Run("Request ID from header", func(t *testing.T) {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set(HeaderRequestID, "client-id-42")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, "client-id-42", gotID)
+		assert.Equal(t, "client-id-42", rec.Header().Get(HeaderRequestID), "Request ID should be returned to client")
+
+		// И лог обработчика, и итоговый лог запроса помечены id
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 2)
+		for _, line := range lines {
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			assert.Equal(t, "client-id-42", entry["request_id"])
+		}
+		assert.Contains(t, lines[1], `"status":418`)
+	})
+
+	t.Run("Generated request ID", func(t *testing.T) {
+		for _, header := range []string{"", "has spaces", strings.Repeat("a", maxRequestIDLength+1)} {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header.Set(HeaderRequestID, header)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Len(t, gotID, 32, "Invalid header %q should be replaced", header)
+			assert.Equal(t, gotID, rec.Header().Get(HeaderRequestID))
+		}
+	})
+}
+
+// Тестирует логгер по умолчанию для контекста без логгера
+func TestFromContext(t *testing.T) {
+	assert.NotNil(t, FromContext(context.Background()))
+	assert.Empty(t, RequestID(context.Background()))
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "orders"
+
+// Метрики чтения заказов из Kafka
+var (
+	MessagesFetched = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_fetched_total",
+		Help:      "Messages fetched from the orders topic.",
+	})
+	FetchErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "fetch_errors_total",
+		Help:      "Failed attempts to fetch a message from the orders topic.",
+	})
+	MessagesCommitted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_committed_total",
+		Help:      "Messages committed after processing.",
+	})
+	BatchMessages = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "batch_messages",
+		Help:      "Messages handled together in batch mode.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+	MessagesBySchema = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_by_schema_total",
+		Help:      "Messages fetched from the orders topic, by schema version; legacy for messages without an envelope.",
+	}, []string{"version"})
+	MessagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_failed_total",
+		Help:      "Messages that failed processing, by stage: parse, schema, validation, persist, dead_letter or commit.",
+	}, []string{"stage"})
+	OrdersRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_rejected_total",
+		Help:      "Orders rejected by validation, by reason.",
+	}, []string{"reason"})
+	PolicyReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "validation",
+		Name:      "policy_reloads_total",
+		Help:      "Reloads of the validation policy file after it changed, by result: ok or error.",
+	}, []string{"result"})
+)
+
+// Метрики базы данных
+var (
+	SaveDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "save_duration_seconds",
+		Help:      "Duration of saving a batch of orders to the database, by result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+	LoadsCoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "loads_coalesced_total",
+		Help:      "Order lookups answered by a database load shared with concurrent lookups of the same uid.",
+	})
+	NotFoundHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "not_found_hits_total",
+		Help:      "Lookups of recently missing order uids answered without querying the database.",
+	})
+)
+
+// Метрики кэша
+var (
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "lookups_total",
+		Help:      "Cache lookups by result: hit, miss or error.",
+	}, []string{"result"})
+	CacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "Orders evicted from the cache by LRU.",
+	})
+	LocalCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "local_cache",
+		Name:      "lookups_total",
+		Help:      "In-process cache lookups by result: hit or miss.",
+	}, []string{"result"})
+	LocalCacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "local_cache",
+		Name:      "evictions_total",
+		Help:      "Orders evicted from the in-process cache by LRU.",
+	})
+	LocalCacheInvalidations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "local_cache",
+		Name:      "invalidations_total",
+		Help:      "Orders dropped from the in-process cache after an update on another replica.",
+	})
+)
+
+// Метрики статусов заказов
+var (
+	StatusChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "status",
+		Name:      "changes_total",
+		Help:      "Order status transitions by target status.",
+	}, []string{"status"})
+	StatusEventsFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "status",
+		Name:      "events_failed_total",
+		Help:      "Committed status transitions whose event could not be published to Kafka.",
+	})
+)
+
+// Метрики HTTP
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+// Результаты для меток result
+const (
+	ResultOK    string = "ok"
+	ResultError string = "error"
+	ResultHit   string = "hit"
+	ResultMiss  string = "miss"
+)
+
+// InstrumentHandler считает запросы и их длительность для маршрута route.
+// Маршрут передается шаблоном, чтобы не плодить метки на каждый order_uid
+func InstrumentHandler(route string, handler http.HandlerFunc) http.Handler {
+	labels := prometheus.Labels{"route": route}
+	return promhttp.InstrumentHandlerDuration(HTTPDuration.MustCurryWith(labels),
+		promhttp.InstrumentHandlerCounter(HTTPRequests.MustCurryWith(labels), handler),
+	)
+}
+
+// Handler отдает метрики в формате Prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирует подсчет HTTP запросов по шаблону маршрута
+func TestInstrumentHandler(t *testing.T) {
+	route := "GET /test/{id}"
+	handler := InstrumentHandler(route, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/test/missing" {
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+	})
+
+	for _, path := range []string{"/test/1", "/test/2", "/test/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Разные id попадают в одну метку маршрута
+	assert.Equal(t, float64(2), testutil.ToFloat64(HTTPRequests.WithLabelValues(route, "get", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(HTTPRequests.WithLabelValues(route, "get", "404")))
+	assert.Equal(t, 1, testutil.CollectAndCount(HTTPDuration, "orders_http_request_duration_seconds"))
+}
+
+// Тестирует отдачу метрик в формате Prometheus
+func TestHandler(t *testing.T) {
+	MessagesFetched.Inc()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.True(t, strings.Contains(body, "orders_kafka_messages_fetched_total"), "Metrics should contain Kafka counters")
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирует встроенные в бинарник миграции
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	// Версии идут подряд без пропусков, у каждой есть запросы в обе стороны
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "migration %s", m.Name)
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+}
+
+// Тестирует разбор директории с миграциями
+func TestLoadFiles(t *testing.T) {
+	file := func(data string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(data)}
+	}
+
+	t.Run("Sorted by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"m/0010_second.up.sql":   file("CREATE TABLE b ();"),
+			"m/0010_second.down.sql": file("DROP TABLE b;"),
+			"m/0002_first.up.sql":    file("CREATE TABLE a ();"),
+			"m/0002_first.down.sql":  file("DROP TABLE a;"),
+		}
+
+		migrations, err := load(fsys, "m")
+		require.NoError(t, err)
+		require.Len(t, migrations, 2)
+		assert.Equal(t, Migration{Version: 2, Name: "first", Up: "CREATE TABLE a ();", Down: "DROP TABLE a;"}, migrations[0])
+		assert.Equal(t, int64(10), migrations[1].Version)
+	})
+
+	cases := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"Missing down", fstest.MapFS{
+			"m/0001_init.up.sql": file("CREATE TABLE a ();"),
+		}},
+		{"Different names", fstest.MapFS{
+			"m/0001_init.up.sql":    file("CREATE TABLE a ();"),
+			"m/0001_other.down.sql": file("DROP TABLE a;"),
+		}},
+		{"Unexpected file", fstest.MapFS{
+			"m/init.sql": file("CREATE TABLE a ();"),
+		}},
+		{"Zero version", fstest.MapFS{
+			"m/0000_init.up.sql":   file("CREATE TABLE a ();"),
+			"m/0000_init.down.sql": file("DROP TABLE a;"),
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := load(tc.fsys, "m")
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS items_order_uid_rid_idx;
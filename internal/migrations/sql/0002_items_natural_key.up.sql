@@ -0,0 +1,8 @@
+-- До идемпотентной вставки повторно доставленные сообщения дублировали товары,
+-- оставляем первую запись каждого товара, чтобы индекс можно было построить
+DELETE FROM items a
+USING items b
+WHERE a.order_uid = b.order_uid AND a.rid = b.rid AND a.item_id > b.item_id;
+
+-- Естественный ключ товара нужен для идемпотентной вставки (ON CONFLICT)
+CREATE UNIQUE INDEX IF NOT EXISTS items_order_uid_rid_idx ON items (order_uid, rid);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS orders_date_created_order_uid_idx;
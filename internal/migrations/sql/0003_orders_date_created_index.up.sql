@@ -0,0 +1,2 @@
+-- Индекс для постраничной выдачи заказов (keyset по дате создания и uid)
+CREATE INDEX IF NOT EXISTS orders_date_created_order_uid_idx ON orders (date_created DESC, order_uid DESC);
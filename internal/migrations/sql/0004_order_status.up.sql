@@ -0,0 +1,23 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'created' CHECK (
+    status IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned')
+);
+
+-- История смены статусов заказа, from_status пуст для начального статуса
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL REFERENCES orders (
+        order_uid
+    ) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_uid_idx ON order_status_history (order_uid, id);
+
+-- Заказам, сохраненным до появления статусов, записываем начальный статус в историю
+INSERT INTO order_status_history (order_uid, to_status, changed_at)
+SELECT o.order_uid, o.status, o.date_created
+FROM orders o
+WHERE NOT EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_uid = o.order_uid);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS orders_customer_id_date_created_idx;
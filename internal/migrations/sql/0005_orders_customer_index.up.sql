@@ -0,0 +1,2 @@
+-- Индекс для заказов покупателя: выборка по customer_id уже отсортирована для keyset
+CREATE INDEX IF NOT EXISTS orders_customer_id_date_created_idx ON orders (customer_id, date_created DESC, order_uid DESC);
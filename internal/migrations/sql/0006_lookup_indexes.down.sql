@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS payments_transaction_idx;
+DROP INDEX IF EXISTS orders_track_number_idx;
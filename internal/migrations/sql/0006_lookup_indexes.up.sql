@@ -0,0 +1,3 @@
+-- Индексы для поиска заказа по трек-номеру и по транзакции оплаты
+CREATE INDEX IF NOT EXISTS orders_track_number_idx ON orders (track_number);
+CREATE INDEX IF NOT EXISTS payments_transaction_idx ON payments (transaction);
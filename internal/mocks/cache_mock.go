@@ -70,6 +70,20 @@ func (mr *MockOrdersCacheMockRecorder) GetFromCache(ctx, uid any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFromCache", reflect.TypeOf((*MockOrdersCache)(nil).GetFromCache), ctx, uid)
 }
 
+// Invalidate mocks base method.
+func (m *MockOrdersCache) Invalidate(ctx context.Context, uid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Invalidate", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Invalidate indicates an expected call of Invalidate.
+func (mr *MockOrdersCacheMockRecorder) Invalidate(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invalidate", reflect.TypeOf((*MockOrdersCache)(nil).Invalidate), ctx, uid)
+}
+
 // LoadInitialOrders mocks base method.
 func (m *MockOrdersCache) LoadInitialOrders(ctx context.Context, latestOrders []*generator.Order, limit int32) {
 	m.ctrl.T.Helper()
@@ -82,6 +96,20 @@ func (mr *MockOrdersCacheMockRecorder) LoadInitialOrders(ctx, latestOrders, limi
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadInitialOrders", reflect.TypeOf((*MockOrdersCache)(nil).LoadInitialOrders), ctx, latestOrders, limit)
 }
 
+// Ping mocks base method.
+func (m *MockOrdersCache) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockOrdersCacheMockRecorder) Ping(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockOrdersCache)(nil).Ping), ctx)
+}
+
 // UpdateCache mocks base method.
 func (m *MockOrdersCache) UpdateCache(ctx context.Context, order *generator.Order) error {
 	m.ctrl.T.Helper()
@@ -95,3 +123,17 @@ func (mr *MockOrdersCacheMockRecorder) UpdateCache(ctx, order any) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCache", reflect.TypeOf((*MockOrdersCache)(nil).UpdateCache), ctx, order)
 }
+
+// UpdateCacheBatch mocks base method.
+func (m *MockOrdersCache) UpdateCacheBatch(ctx context.Context, orders []*generator.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCacheBatch", ctx, orders)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateCacheBatch indicates an expected call of UpdateCacheBatch.
+func (mr *MockOrdersCacheMockRecorder) UpdateCacheBatch(ctx, orders any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCacheBatch", reflect.TypeOf((*MockOrdersCache)(nil).UpdateCacheBatch), ctx, orders)
+}
@@ -12,6 +12,8 @@ package mocks
 import (
 	context "context"
 	generator "orders/internal/generator"
+	repository "orders/internal/repository"
+	status "orders/internal/status"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -41,6 +43,20 @@ func (m *MockOrdersRepository) EXPECT() *MockOrdersRepositoryMockRecorder {
 	return m.recorder
 }
 
+// BulkSaveToDB mocks base method.
+func (m *MockOrdersRepository) BulkSaveToDB(orders []*generator.Order, ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BulkSaveToDB", orders, ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BulkSaveToDB indicates an expected call of BulkSaveToDB.
+func (mr *MockOrdersRepositoryMockRecorder) BulkSaveToDB(orders, ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkSaveToDB", reflect.TypeOf((*MockOrdersRepository)(nil).BulkSaveToDB), orders, ctx)
+}
+
 // Close mocks base method.
 func (m *MockOrdersRepository) Close() error {
 	m.ctrl.T.Helper()
@@ -55,19 +71,19 @@ func (mr *MockOrdersRepositoryMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockOrdersRepository)(nil).Close))
 }
 
-// GetAllOrders mocks base method.
-func (m *MockOrdersRepository) GetAllOrders(ctx context.Context) ([]*generator.Order, error) {
+// GetCustomerSummary mocks base method.
+func (m *MockOrdersRepository) GetCustomerSummary(ctx context.Context, customerID string) (*repository.CustomerSummary, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllOrders", ctx)
-	ret0, _ := ret[0].([]*generator.Order)
+	ret := m.ctrl.Call(m, "GetCustomerSummary", ctx, customerID)
+	ret0, _ := ret[0].(*repository.CustomerSummary)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAllOrders indicates an expected call of GetAllOrders.
-func (mr *MockOrdersRepositoryMockRecorder) GetAllOrders(ctx any) *gomock.Call {
+// GetCustomerSummary indicates an expected call of GetCustomerSummary.
+func (mr *MockOrdersRepositoryMockRecorder) GetCustomerSummary(ctx, customerID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllOrders", reflect.TypeOf((*MockOrdersRepository)(nil).GetAllOrders), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomerSummary", reflect.TypeOf((*MockOrdersRepository)(nil).GetCustomerSummary), ctx, customerID)
 }
 
 // GetLatestOrders mocks base method.
@@ -100,6 +116,80 @@ func (mr *MockOrdersRepositoryMockRecorder) GetOrderById(order_uid, ctx, useCach
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderById", reflect.TypeOf((*MockOrdersRepository)(nil).GetOrderById), order_uid, ctx, useCache)
 }
 
+// GetOrdersByTrackNumber mocks base method.
+func (m *MockOrdersRepository) GetOrdersByTrackNumber(ctx context.Context, trackNumber string) ([]*generator.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersByTrackNumber", ctx, trackNumber)
+	ret0, _ := ret[0].([]*generator.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersByTrackNumber indicates an expected call of GetOrdersByTrackNumber.
+func (mr *MockOrdersRepositoryMockRecorder) GetOrdersByTrackNumber(ctx, trackNumber any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByTrackNumber", reflect.TypeOf((*MockOrdersRepository)(nil).GetOrdersByTrackNumber), ctx, trackNumber)
+}
+
+// GetOrdersByTransaction mocks base method.
+func (m *MockOrdersRepository) GetOrdersByTransaction(ctx context.Context, transaction string) ([]*generator.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersByTransaction", ctx, transaction)
+	ret0, _ := ret[0].([]*generator.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersByTransaction indicates an expected call of GetOrdersByTransaction.
+func (mr *MockOrdersRepositoryMockRecorder) GetOrdersByTransaction(ctx, transaction any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByTransaction", reflect.TypeOf((*MockOrdersRepository)(nil).GetOrdersByTransaction), ctx, transaction)
+}
+
+// GetStatusHistory mocks base method.
+func (m *MockOrdersRepository) GetStatusHistory(ctx context.Context, order_uid string) ([]status.Change, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatusHistory", ctx, order_uid)
+	ret0, _ := ret[0].([]status.Change)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatusHistory indicates an expected call of GetStatusHistory.
+func (mr *MockOrdersRepositoryMockRecorder) GetStatusHistory(ctx, order_uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatusHistory", reflect.TypeOf((*MockOrdersRepository)(nil).GetStatusHistory), ctx, order_uid)
+}
+
+// ListOrders mocks base method.
+func (m *MockOrdersRepository) ListOrders(ctx context.Context, filter repository.OrdersFilter) (*repository.OrdersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOrders", ctx, filter)
+	ret0, _ := ret[0].(*repository.OrdersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOrders indicates an expected call of ListOrders.
+func (mr *MockOrdersRepositoryMockRecorder) ListOrders(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrders", reflect.TypeOf((*MockOrdersRepository)(nil).ListOrders), ctx, filter)
+}
+
+// Ping mocks base method.
+func (m *MockOrdersRepository) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockOrdersRepositoryMockRecorder) Ping(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockOrdersRepository)(nil).Ping), ctx)
+}
+
 // SaveToDB mocks base method.
 func (m *MockOrdersRepository) SaveToDB(orders []*generator.Order, ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -113,3 +203,18 @@ func (mr *MockOrdersRepositoryMockRecorder) SaveToDB(orders, ctx any) *gomock.Ca
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveToDB", reflect.TypeOf((*MockOrdersRepository)(nil).SaveToDB), orders, ctx)
 }
+
+// UpdateStatus mocks base method.
+func (m *MockOrdersRepository) UpdateStatus(ctx context.Context, order_uid string, to status.Status, reason string) (*status.Change, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStatus", ctx, order_uid, to, reason)
+	ret0, _ := ret[0].(*status.Change)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateStatus indicates an expected call of UpdateStatus.
+func (mr *MockOrdersRepositoryMockRecorder) UpdateStatus(ctx, order_uid, to, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockOrdersRepository)(nil).UpdateStatus), ctx, order_uid, to, reason)
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "orders/internal/database"
+	g "orders/internal/generator"
+	"orders/internal/logging"
+	"orders/internal/metrics"
+	"orders/internal/status"
+)
+
+// ErrDuplicateInBatch возвращается BulkSaveToDB, если uid заказа повторяется в пачке.
+// Такую пачку нужно сохранять по порядку через SaveToDB
+var ErrDuplicateInBatch = errors.New("order is repeated in the batch")
+
+// BulkSaveToDB сохраняет заказы одной транзакцией, записывая каждую таблицу
+// одним запросом на всю пачку, и обновляет кэш за одно обращение к Redis.
+// Уже сохраненные заказы обрабатываются политикой конфликтов по одному
+func (r *Repository) BulkSaveToDB(orders []*g.Order, ctx context.Context) error {
+	start := time.Now()
+	err := r.bulkSaveToDB(orders, ctx)
+
+	result := metrics.ResultOK
+	if err != nil {
+		result = metrics.ResultError
+	}
+	metrics.SaveDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	return err
+}
+
+func (r *Repository) bulkSaveToDB(orders []*g.Order, ctx context.Context) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(orders))
+	for _, order := range orders {
+		if seen[order.OrderUID] {
+			return fmt.Errorf("%w: %s", ErrDuplicateInBatch, order.OrderUID)
+		}
+		seen[order.OrderUID] = true
+	}
+
+	var written []*g.Order
+	err := r.inTx(ctx, func(queries *db.Queries) error {
+		inserted, err := insertOrders(ctx, queries, orders)
+		if err != nil {
+			return err
+		}
+
+		written = make([]*g.Order, 0, len(orders))
+		for _, order := range orders {
+			if inserted[order.OrderUID] {
+				written = append(written, order)
+				continue
+			}
+			ok, err := r.saveExisting(ctx, queries, order)
+			if err != nil {
+				return err
+			}
+			if ok {
+				written = append(written, order)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		r.notFound.remove(order.OrderUID)
+	}
+	if len(written) > 0 {
+		// Как и в cacheOrder, ошибка кэша не отменяет сохранения
+		if err := r.cache.UpdateCacheBatch(ctx, written); err != nil {
+			logging.FromContext(ctx).Error("Error caching saved orders", "orders", len(written), "error", err)
+		}
+	}
+	return nil
+}
+
+// insertOrders вставляет заказы, которых еще нет в бд, вместе с доставкой,
+// оплатой, товарами и начальным статусом. Возвращает uid вставленных заказов
+func insertOrders(ctx context.Context, queries *db.Queries, orders []*g.Order) (map[string]bool, error) {
+	var ordersArg db.InsertOrdersIfNotExistParams
+	for _, order := range orders {
+		ordersArg.OrderUids = append(ordersArg.OrderUids, order.OrderUID)
+		ordersArg.TrackNumbers = append(ordersArg.TrackNumbers, order.TrackNumber)
+		ordersArg.Entries = append(ordersArg.Entries, order.Entry)
+		ordersArg.Locales = append(ordersArg.Locales, order.Locale)
+		ordersArg.InternalSignatures = append(ordersArg.InternalSignatures, order.InternalSignature)
+		ordersArg.CustomerIds = append(ordersArg.CustomerIds, order.CustomerID)
+		ordersArg.DeliveryServices = append(ordersArg.DeliveryServices, order.DeliveryService)
+		ordersArg.Shardkeys = append(ordersArg.Shardkeys, order.Shardkey)
+		ordersArg.SmIds = append(ordersArg.SmIds, int32(order.SmID))
+		ordersArg.DatesCreated = append(ordersArg.DatesCreated, order.DateCreated.Format(time.RFC3339Nano))
+		ordersArg.OofShards = append(ordersArg.OofShards, order.OofShard)
+	}
+
+	uids, err := queries.InsertOrdersIfNotExist(ctx, ordersArg)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error inserting orders", "error", err)
+		return nil, err
+	}
+	inserted := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		inserted[uid] = true
+	}
+	if len(inserted) == 0 {
+		return inserted, nil
+	}
+
+	var (
+		deliveryArg db.CreateDeliveriesParams
+		paymentsArg db.CreatePaymentsParams
+		itemsArg    db.CreateItemsParams
+	)
+	for _, order := range orders {
+		if !inserted[order.OrderUID] {
+			continue
+		}
+
+		d := order.Delivery
+		deliveryArg.OrderUids = append(deliveryArg.OrderUids, order.OrderUID)
+		deliveryArg.Names = append(deliveryArg.Names, d.Name)
+		deliveryArg.Phones = append(deliveryArg.Phones, d.Phone)
+		deliveryArg.Zips = append(deliveryArg.Zips, d.Zip)
+		deliveryArg.Cities = append(deliveryArg.Cities, d.City)
+		deliveryArg.Addresses = append(deliveryArg.Addresses, d.Address)
+		deliveryArg.Regions = append(deliveryArg.Regions, d.Region)
+		deliveryArg.Emails = append(deliveryArg.Emails, d.Email)
+
+		p := order.Payment
+		paymentsArg.OrderUids = append(paymentsArg.OrderUids, order.OrderUID)
+		paymentsArg.Transactions = append(paymentsArg.Transactions, p.Transaction)
+		paymentsArg.RequestIds = append(paymentsArg.RequestIds, p.RequestID)
+		paymentsArg.Currencies = append(paymentsArg.Currencies, p.Currency)
+		paymentsArg.Providers = append(paymentsArg.Providers, p.Provider)
+		paymentsArg.Amounts = append(paymentsArg.Amounts, int32(p.Amount))
+		paymentsArg.PaymentDts = append(paymentsArg.PaymentDts, int64(p.PaymentDT))
+		paymentsArg.Banks = append(paymentsArg.Banks, p.Bank)
+		paymentsArg.DeliveryCosts = append(paymentsArg.DeliveryCosts, int32(p.DeliveryCost))
+		paymentsArg.GoodsTotals = append(paymentsArg.GoodsTotals, int32(p.GoodsTotal))
+		paymentsArg.CustomFees = append(paymentsArg.CustomFees, int32(p.CustomFee))
+
+		for _, item := range order.Items {
+			itemsArg.OrderUids = append(itemsArg.OrderUids, order.OrderUID)
+			itemsArg.ChrtIds = append(itemsArg.ChrtIds, int32(item.ChrtID))
+			itemsArg.TrackNumbers = append(itemsArg.TrackNumbers, item.TrackNumber)
+			itemsArg.Prices = append(itemsArg.Prices, int32(item.Price))
+			itemsArg.Rids = append(itemsArg.Rids, item.Rid)
+			itemsArg.Names = append(itemsArg.Names, item.Name)
+			itemsArg.Sales = append(itemsArg.Sales, int32(item.Sale))
+			itemsArg.Sizes = append(itemsArg.Sizes, item.Size)
+			itemsArg.TotalPrices = append(itemsArg.TotalPrices, int32(item.TotalPrice))
+			itemsArg.NmIds = append(itemsArg.NmIds, int32(item.NmID))
+			itemsArg.Brands = append(itemsArg.Brands, item.Brand)
+			itemsArg.Statuses = append(itemsArg.Statuses, int32(item.Status))
+		}
+	}
+
+	if err := queries.CreateDeliveries(ctx, deliveryArg); err != nil {
+		logging.FromContext(ctx).Error("Error inserting deliveries", "error", err)
+		return nil, err
+	}
+	if err := queries.CreatePayments(ctx, paymentsArg); err != nil {
+		logging.FromContext(ctx).Error("Error inserting payments", "error", err)
+		return nil, err
+	}
+	if len(itemsArg.OrderUids) > 0 {
+		if err := queries.CreateItems(ctx, itemsArg); err != nil {
+			logging.FromContext(ctx).Error("Error inserting items", "error", err)
+			return nil, err
+		}
+	}
+
+	err = queries.CreateInitialStatuses(ctx, db.CreateInitialStatusesParams{
+		ToStatus:  string(status.Initial),
+		OrderUids: deliveryArg.OrderUids,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error inserting initial statuses", "error", err)
+		return nil, err
+	}
+
+	for _, order := range orders {
+		if inserted[order.OrderUID] {
+			order.Status = string(status.Initial)
+		}
+	}
+	return inserted, nil
+}
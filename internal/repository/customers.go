@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	db "orders/internal/database"
+	"orders/internal/logging"
+)
+
+// CustomerSummary - сводка по всем заказам покупателя. TotalSpent
+// хранит сумму оплат отдельно по каждой валюте
+type CustomerSummary struct {
+	OrdersCount              int64            `json:"orders_count"`
+	TotalSpent               map[string]int64 `json:"total_spent"`
+	FirstOrderAt             time.Time        `json:"first_order_at"`
+	LastOrderAt              time.Time        `json:"last_order_at"`
+	FavouriteDeliveryService string           `json:"favourite_delivery_service"`
+}
+
+// GetCustomerSummary считает сводку по заказам покупателя.
+// Если заказов у покупателя нет, возвращает sql.ErrNoRows
+func (r *Repository) GetCustomerSummary(ctx context.Context, customerID string) (*CustomerSummary, error) {
+	queries := db.New(r.DB)
+
+	row, err := queries.GetCustomerSummary(ctx, customerID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logging.FromContext(ctx).Error("Error getting customer summary", "error", err)
+		}
+		return nil, err
+	}
+
+	spending, err := queries.GetCustomerSpending(ctx, customerID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error getting customer spending", "error", err)
+		return nil, err
+	}
+
+	summary := &CustomerSummary{
+		OrdersCount:              row.OrdersCount,
+		TotalSpent:               make(map[string]int64, len(spending)),
+		FirstOrderAt:             row.FirstOrderAt,
+		LastOrderAt:              row.LastOrderAt,
+		FavouriteDeliveryService: row.FavouriteDeliveryService,
+	}
+	for _, s := range spending {
+		summary.TotalSpent[s.Currency] = s.Total
+	}
+	return summary, nil
+}
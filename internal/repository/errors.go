@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Классы ошибок postgres, после которых повторная попытка может быть успешной:
+// обрыв соединения, откат из-за конфликта транзакций, нехватка ресурсов и
+// вмешательство оператора (например, перезапуск сервера)
+var retryableClasses = map[pq.ErrorClass]bool{
+	"08": true,
+	"40": true,
+	"53": true,
+	"57": true,
+}
+
+// IsRetryable сообщает, имеет ли смысл повторить операцию, завершившуюся ошибкой err.
+// Конфликты данных, нарушения ограничений и прочие ошибки postgres считаются
+// постоянными, а сетевые и неизвестные ошибки - временными
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrOrderConflict) || errors.Is(err, ErrDuplicateInBatch) {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return retryableClasses[pqErr.Code.Class()]
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"time"
+
+	c "orders/internal/cache"
+)
+
+// Открываем внутренности репозитория для внешнего тестового пакета
+
+// Cache возвращает кэш, с которым был создан репозиторий
+func (r *Repository) Cache() c.OrdersCache {
+	return r.cache
+}
+
+var SameOrder = sameOrder
+
+var FullOrder = fullOrder
+
+var LoadOrder = loadOrder
+
+// NotFoundCache открывает кэш отсутствующих uid
+type NotFoundCache struct {
+	cache notFoundCache
+}
+
+const NotFoundCapacity = notFoundCapacity
+
+func (n *NotFoundCache) Contains(uid string) bool {
+	return n.cache.contains(uid)
+}
+
+func (n *NotFoundCache) Add(uid string, ttl time.Duration) {
+	n.cache.add(uid, ttl)
+}
+
+func (n *NotFoundCache) Remove(uid string) {
+	n.cache.remove(uid)
+}
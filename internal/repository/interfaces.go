@@ -3,13 +3,21 @@ package repository
 import (
 	"context"
 	g "orders/internal/generator"
+	"orders/internal/status"
 )
 
 // OrdersRepository описывает поведение структуры Repository
 type OrdersRepository interface {
 	SaveToDB(orders []*g.Order, ctx context.Context) error
+	BulkSaveToDB(orders []*g.Order, ctx context.Context) error
 	GetOrderById(order_uid string, ctx context.Context, useCache bool) (*g.Order, error)
-	GetAllOrders(ctx context.Context) ([]*g.Order, error)
+	ListOrders(ctx context.Context, filter OrdersFilter) (*OrdersPage, error)
+	GetCustomerSummary(ctx context.Context, customerID string) (*CustomerSummary, error)
+	GetOrdersByTrackNumber(ctx context.Context, trackNumber string) ([]*g.Order, error)
+	GetOrdersByTransaction(ctx context.Context, transaction string) ([]*g.Order, error)
 	GetLatestOrders(ctx context.Context, limit int32) ([]*g.Order, error)
+	UpdateStatus(ctx context.Context, order_uid string, to status.Status, reason string) (*status.Change, error)
+	GetStatusHistory(ctx context.Context, order_uid string) ([]status.Change, error)
+	Ping(ctx context.Context) error
 	Close() error
 }
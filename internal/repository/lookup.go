@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	db "orders/internal/database"
+	g "orders/internal/generator"
+	"orders/internal/logging"
+)
+
+// MaxLookupOrders ограничивает число заказов, возвращаемых поиском
+// по трек-номеру или транзакции
+const MaxLookupOrders = 100
+
+// GetOrdersByTrackNumber возвращает заказы с трек-номером trackNumber, от новых к старым.
+// Если таких заказов нет, возвращает sql.ErrNoRows
+func (r *Repository) GetOrdersByTrackNumber(ctx context.Context, trackNumber string) ([]*g.Order, error) {
+	queries := db.New(r.DB)
+
+	uids, err := queries.GetOrderUIDsByTrackNumber(ctx, db.GetOrderUIDsByTrackNumberParams{
+		TrackNumber: trackNumber,
+		Limit:       MaxLookupOrders,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error getting orders by track number", "error", err)
+		return nil, err
+	}
+	return lookupOrders(ctx, queries, uids)
+}
+
+// GetOrdersByTransaction возвращает заказы, оплаченные транзакцией transaction, от новых к старым.
+// Если таких заказов нет, возвращает sql.ErrNoRows
+func (r *Repository) GetOrdersByTransaction(ctx context.Context, transaction string) ([]*g.Order, error) {
+	queries := db.New(r.DB)
+
+	uids, err := queries.GetOrderUIDsByTransaction(ctx, db.GetOrderUIDsByTransactionParams{
+		Transaction: transaction,
+		Limit:       MaxLookupOrders,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error getting orders by transaction", "error", err)
+		return nil, err
+	}
+	return lookupOrders(ctx, queries, uids)
+}
+
+// lookupOrders собирает найденные заказы, пустой результат поиска превращает в sql.ErrNoRows
+func lookupOrders(ctx context.Context, queries *db.Queries, uids []string) ([]*g.Order, error) {
+	if len(uids) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return loadOrders(ctx, queries, uids)
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"sync"
+	"time"
+)
+
+// notFoundCapacity ограничивает число запоминаемых отсутствующих uid,
+// чтобы перебор случайных uid не расходовал память без предела
+const notFoundCapacity = 10000
+
+// notFoundCache запоминает uid, которых не оказалось в бд.
+// Нулевое значение готово к использованию
+type notFoundCache struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+// contains сообщает, был ли uid недавно не найден в бд
+func (n *notFoundCache) contains(uid string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	expires, ok := n.expires[uid]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(n.expires, uid)
+		return false
+	}
+	return true
+}
+
+// add запоминает отсутствующий uid на время ttl. Нулевое ttl отключает запоминание,
+// а если места нет даже после удаления устаревших записей, uid не запоминается
+func (n *notFoundCache) add(uid string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.expires == nil {
+		n.expires = make(map[string]time.Time)
+	}
+
+	now := time.Now()
+	if len(n.expires) >= notFoundCapacity {
+		for key, expires := range n.expires {
+			if now.After(expires) {
+				delete(n.expires, key)
+			}
+		}
+		if len(n.expires) >= notFoundCapacity {
+			return
+		}
+	}
+	n.expires[uid] = now.Add(ttl)
+}
+
+// remove забывает uid, например, после сохранения заказа с ним
+func (n *notFoundCache) remove(uid string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.expires, uid)
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	db "orders/internal/database"
+	g "orders/internal/generator"
+	"orders/internal/logging"
+)
+
+const (
+	DefaultPageLimit int32 = 50
+	MaxPageLimit     int32 = 500
+)
+
+// ErrInvalidCursor возвращается при попытке разобрать поврежденный курсор
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor указывает на последний заказ предыдущей страницы:
+// заказы отсортированы по дате создания и uid по убыванию
+type Cursor struct {
+	DateCreated time.Time
+	OrderUID    string
+}
+
+// OrdersFilter описывает фильтры и курсор для постраничной выдачи заказов.
+// Пустые поля не участвуют в фильтрации
+type OrdersFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Locale          string
+	Entry           string
+	Currency        string
+	Provider        string
+	Bank            string
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+	Cursor          *Cursor
+	Limit           int32
+}
+
+// OrdersPage - страница заказов и курсор для запроса следующей,
+// курсор пустой, если страница последняя
+type OrdersPage struct {
+	Orders     []*g.Order `json:"orders"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// EncodeCursor упаковывает курсор в непрозрачную для клиента строку
+func EncodeCursor(cursor Cursor) string {
+	raw := cursor.DateCreated.UTC().Format(time.RFC3339Nano) + "|" + cursor.OrderUID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor разбирает строку, полученную от EncodeCursor
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	date, uid, found := strings.Cut(string(raw), "|")
+	if !found || uid == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	dateCreated, err := time.Parse(time.RFC3339Nano, date)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{DateCreated: dateCreated, OrderUID: uid}, nil
+}
+
+func (r *Repository) ListOrders(ctx context.Context, filter OrdersFilter) (*OrdersPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	params := db.ListOrdersParams{
+		CustomerID:      nullString(filter.CustomerID),
+		TrackNumber:     nullString(filter.TrackNumber),
+		DeliveryService: nullString(filter.DeliveryService),
+		Locale:          nullString(filter.Locale),
+		Entry:           nullString(filter.Entry),
+		Currency:        nullString(filter.Currency),
+		Provider:        nullString(filter.Provider),
+		Bank:            nullString(filter.Bank),
+		CreatedFrom:     nullTime(filter.CreatedFrom),
+		CreatedTo:       nullTime(filter.CreatedTo),
+		// Запрашиваем на один заказ больше, чтобы узнать, есть ли следующая страница
+		PageLimit: limit + 1,
+	}
+	if filter.Cursor != nil {
+		params.CursorDate = nullTime(filter.Cursor.DateCreated)
+		params.CursorUid = nullString(filter.Cursor.OrderUID)
+	}
+
+	queries := db.New(r.DB)
+
+	orders, err := queries.ListOrders(ctx, params)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error listing orders", "error", err)
+		return nil, err
+	}
+
+	page := &OrdersPage{Orders: []*g.Order{}}
+	if len(orders) == 0 {
+		return page, nil
+	}
+
+	if int32(len(orders)) > limit {
+		orders = orders[:limit]
+		last := orders[len(orders)-1]
+		page.NextCursor = EncodeCursor(Cursor{DateCreated: last.DateCreated, OrderUID: last.OrderUid})
+	}
+
+	uids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		uids = append(uids, order.OrderUid)
+	}
+
+	deliveries, err := queries.GetDeliveriesByOrderUIDs(ctx, uids)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error getting deliveries", "error", err)
+		return nil, err
+	}
+
+	payments, err := queries.GetPaymentsByOrderUIDs(ctx, uids)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error getting payments", "error", err)
+		return nil, err
+	}
+
+	items, err := queries.GetItemsByOrderUIDs(ctx, uids)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error getting items", "error", err)
+		return nil, err
+	}
+
+	page.Orders = buildOrders(orders, deliveries, payments, items)
+	return page, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -3,16 +3,86 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
 
 	c "orders/internal/cache"
 	db "orders/internal/database"
 	g "orders/internal/generator"
+	"orders/internal/logging"
+	"orders/internal/metrics"
+	"orders/internal/status"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// TxScope определяет границы транзакции при сохранении заказов
+type TxScope int
+
+const (
+	// TxPerOrder сохраняет каждый заказ в отдельной транзакции
+	TxPerOrder TxScope = iota
+	// TxPerBatch сохраняет все заказы из сообщения одной транзакцией
+	TxPerBatch
 )
 
+// ConflictPolicy определяет поведение при повторном сохранении уже существующего заказа,
+// например, когда Kafka заново доставляет сообщение после падения сервиса
+type ConflictPolicy int
+
+const (
+	// ConflictReject пропускает точные дубликаты и отклоняет заказ, если данные отличаются
+	ConflictReject ConflictPolicy = iota
+	// ConflictSkip пропускает заказ, если он уже сохранен, не сравнивая данные
+	ConflictSkip
+	// ConflictOverwrite перезаписывает сохраненный заказ пришедшими данными
+	ConflictOverwrite
+)
+
+// ParseTxScope переводит название границы транзакции из конфигурации
+func ParseTxScope(name string) (TxScope, error) {
+	switch name {
+	case "order":
+		return TxPerOrder, nil
+	case "batch":
+		return TxPerBatch, nil
+	}
+	return 0, fmt.Errorf("unknown transaction scope %q", name)
+}
+
+// ParseConflictPolicy переводит название политики конфликтов из конфигурации
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch name {
+	case "reject":
+		return ConflictReject, nil
+	case "skip":
+		return ConflictSkip, nil
+	case "overwrite":
+		return ConflictOverwrite, nil
+	}
+	return 0, fmt.Errorf("unknown conflict policy %q", name)
+}
+
+// ErrOrderConflict возвращается политикой ConflictReject, если заказ
+// с таким uid уже сохранен, но его данные отличаются от пришедших
+var ErrOrderConflict = errors.New("order already exists with different data")
+
 type Repository struct {
-	DB    *sql.DB
-	cache c.OrdersCache
+	DB             *sql.DB
+	TxScope        TxScope
+	ConflictPolicy ConflictPolicy
+	// NotFoundTTL - время, в течение которого запрос отсутствующего заказа
+	// отвечается без обращения к бд, 0 отключает запоминание
+	NotFoundTTL time.Duration
+	cache       c.OrdersCache
+
+	// loads объединяет одновременные загрузки одного заказа из бд
+	loads    singleflight.Group
+	notFound notFoundCache
 }
 
 func NewRepository(driverName, dataSourceName string, cache c.OrdersCache) (*Repository, error) {
@@ -26,236 +96,558 @@ func NewRepository(driverName, dataSourceName string, cache c.OrdersCache) (*Rep
 		mainErr := err
 
 		if closeErr := db.Close(); closeErr != nil {
-			log.Println("NewRepository: Database connection can't be closed:", closeErr)
+			slog.Error("NewRepository: Database connection can't be closed", "error", closeErr)
 		}
 		return nil, mainErr
 	}
-	log.Println("Database connection opened on db:5432")
+	slog.Info("Database connection opened", "driver", driverName)
 
 	return &Repository{DB: db, cache: cache}, nil
 }
 
 func (r *Repository) SaveToDB(orders []*g.Order, ctx context.Context) error {
-	queries := db.New(r.DB)
+	start := time.Now()
+	err := r.saveToDB(orders, ctx)
 
-	for _, order := range orders {
-		err := queries.CreateOrder(ctx, db.CreateOrderParams{
-			OrderUid:    order.OrderUID,
-			TrackNumber: order.TrackNumber,
-			Entry:       order.Entry,
-			Locale:      order.Locale,
-			InternalSignature: sql.NullString{
-				String: order.InternalSignature,
-				Valid:  order.InternalSignature != "",
-			},
-			CustomerID:      order.CustomerID,
-			DeliveryService: order.DeliveryService,
-			Shardkey:        order.Shardkey,
-			SmID:            int32(order.SmID),
-			DateCreated:     order.DateCreated,
-			OofShard:        order.OofShard,
+	result := metrics.ResultOK
+	if err != nil {
+		result = metrics.ResultError
+	}
+	metrics.SaveDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	return err
+}
+
+func (r *Repository) saveToDB(orders []*g.Order, ctx context.Context) error {
+	if r.TxScope == TxPerBatch {
+		var written []*g.Order
+		err := r.inTx(ctx, func(queries *db.Queries) error {
+			for _, order := range orders {
+				ok, err := r.saveOrder(ctx, queries, order)
+				if err != nil {
+					return err
+				}
+				if ok {
+					written = append(written, order)
+				}
+			}
+			return nil
 		})
 		if err != nil {
-			log.Println("Error inserting order:", err)
 			return err
+		}
 
+		for _, order := range orders {
+			r.notFound.remove(order.OrderUID)
 		}
+		for _, order := range written {
+			r.cacheOrder(ctx, order)
+		}
+		return nil
+	}
 
-		err = queries.CreateDelivery(ctx, db.CreateDeliveryParams{
-			OrderUid: order.OrderUID,
-			Name:     order.Delivery.Name,
-			Phone:    order.Delivery.Phone,
-			Zip:      order.Delivery.Zip,
-			City:     order.Delivery.City,
-			Address:  order.Delivery.Address,
-			Region:   order.Delivery.Region,
-			Email:    order.Delivery.Email,
+	for _, order := range orders {
+		var written bool
+		err := r.inTx(ctx, func(queries *db.Queries) error {
+			var err error
+			written, err = r.saveOrder(ctx, queries, order)
+			return err
 		})
 		if err != nil {
-			log.Println("Error inserting delivery:", err)
 			return err
+		}
 
+		// Заказ есть в бд, даже если он был сохранен раньше
+		r.notFound.remove(order.OrderUID)
+		if written {
+			r.cacheOrder(ctx, order)
 		}
+	}
+	return nil
+}
 
-		err = queries.CreatePayment(ctx, db.CreatePaymentParams{
-			OrderUid:    order.OrderUID,
-			Transaction: order.Payment.Transaction,
-			RequestID: sql.NullString{
-				String: order.Payment.RequestID,
-				Valid:  order.Payment.RequestID != "",
-			},
-			Currency:     order.Payment.Currency,
-			Provider:     order.Payment.Provider,
-			Amount:       int32(order.Payment.Amount),
-			PaymentDt:    int64(order.Payment.PaymentDT),
-			Bank:         order.Payment.Bank,
-			DeliveryCost: int32(order.Payment.DeliveryCost),
-			GoodsTotal:   int32(order.Payment.GoodsTotal),
-			CustomFee:    int32(order.Payment.CustomFee),
-		})
+// inTx выполняет fn в рамках одной транзакции: при любой ошибке
+// изменения откатываются, иначе транзакция фиксируется
+func (r *Repository) inTx(ctx context.Context, fn func(queries *db.Queries) error) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error starting transaction", "error", err)
+		return err
+	}
+
+	err = fn(db.New(r.DB).WithTx(tx))
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			logging.FromContext(ctx).Error("Error rolling back transaction", "error", rollbackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logging.FromContext(ctx).Error("Error committing transaction", "error", err)
+		return err
+	}
+	return nil
+}
+
+// cacheOrder кэширует уже сохраненный заказ. Ошибка кэша не возвращается:
+// заказ зафиксирован в бд и попадет в кэш при следующем чтении
+func (r *Repository) cacheOrder(ctx context.Context, order *g.Order) {
+	err := r.cache.UpdateCache(ctx, order)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error caching saved order", "order_uid", order.OrderUID, "error", err)
+	}
+}
+
+// saveOrder сохраняет заказ согласно политике конфликтов и сообщает,
+// были ли записаны данные: дубликаты существующих заказов пропускаются.
+// Новый заказ просто вставляется, а политика применяется в saveExisting,
+// как и при сохранении пачкой
+func (r *Repository) saveOrder(ctx context.Context, queries *db.Queries, order *g.Order) (bool, error) {
+	inserted, err := insertOrder(ctx, queries, order)
+	if err != nil || inserted {
+		return inserted, err
+	}
+	return r.saveExisting(ctx, queries, order)
+}
+
+// saveExisting применяет политику конфликтов к заказу, который уже есть в бд
+func (r *Repository) saveExisting(ctx context.Context, queries *db.Queries, order *g.Order) (bool, error) {
+	switch r.ConflictPolicy {
+	case ConflictOverwrite:
+		err := upsertOrder(ctx, queries, order)
 		if err != nil {
-			log.Println("Error inserting payment:", err)
-			return err
+			return false, err
+		}
+		return true, nil
+
+	case ConflictSkip:
+		return false, nil
 
+	default:
+		existing, err := loadOrder(ctx, queries, order.OrderUID)
+		if err != nil {
+			return false, err
+		}
+		if !sameOrder(existing, order) {
+			return false, fmt.Errorf("%w: %s", ErrOrderConflict, order.OrderUID)
 		}
+		logging.FromContext(ctx).Info("Order is already saved, skipping duplicate", "order_uid", order.OrderUID)
+		return false, nil
+	}
+}
 
-		for _, item := range order.Items {
-			err = queries.CreateItem(ctx, db.CreateItemParams{
-				OrderUid:    order.OrderUID,
-				ChrtID:      int32(item.ChrtID),
-				TrackNumber: item.TrackNumber,
-				Price:       int32(item.Price),
-				Rid:         item.Rid,
-				Name:        item.Name,
-				Sale:        int32(item.Sale),
-				Size:        item.Size,
-				TotalPrice:  int32(item.TotalPrice),
-				NmID:        int32(item.NmID),
-				Brand:       item.Brand,
-				Status:      int32(item.Status),
-			})
-			if err != nil {
-				log.Println("Error inserting item:", err)
-				return err
-			}
+// insertOrder вставляет заказ, только если заказа с таким uid еще нет в бд
+func insertOrder(ctx context.Context, queries *db.Queries, order *g.Order) (bool, error) {
+	inserted, err := queries.InsertOrderIfNotExists(ctx, db.InsertOrderIfNotExistsParams(orderParams(order)))
+	if err != nil {
+		logging.FromContext(ctx).Error("Error inserting order", "error", err)
+		return false, err
+	}
+	if inserted == 0 {
+		return false, nil
+	}
+
+	err = queries.CreateDelivery(ctx, deliveryParams(order))
+	if err != nil {
+		logging.FromContext(ctx).Error("Error inserting delivery", "error", err)
+		return false, err
+	}
+
+	err = queries.CreatePayment(ctx, paymentParams(order))
+	if err != nil {
+		logging.FromContext(ctx).Error("Error inserting payment", "error", err)
+		return false, err
+	}
+
+	for _, item := range order.Items {
+		err = queries.CreateItem(ctx, itemParams(order.OrderUID, item))
+		if err != nil {
+			logging.FromContext(ctx).Error("Error inserting item", "error", err)
+			return false, err
 		}
+	}
+
+	err = createInitialStatus(ctx, queries, order.OrderUID)
+	if err != nil {
+		return false, err
+	}
+	order.Status = string(status.Initial)
+	return true, nil
+}
+
+// createInitialStatus записывает в историю начальный статус заказа, если истории еще нет
+func createInitialStatus(ctx context.Context, queries *db.Queries, order_uid string) error {
+	err := queries.CreateInitialStatus(ctx, db.CreateInitialStatusParams{
+		OrderUid: order_uid,
+		ToStatus: string(status.Initial),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error inserting initial status", "error", err)
+		return err
+	}
+	return nil
+}
+
+// upsertOrder перезаписывает заказ целиком, удаляя товары,
+// которых больше нет в новой версии заказа. Статус заказа не меняется
+func upsertOrder(ctx context.Context, queries *db.Queries, order *g.Order) error {
+	current, err := queries.UpsertOrder(ctx, db.UpsertOrderParams(orderParams(order)))
+	if err != nil {
+		logging.FromContext(ctx).Error("Error upserting order", "error", err)
+		return err
+	}
+	order.Status = current
+
+	err = createInitialStatus(ctx, queries, order.OrderUID)
+	if err != nil {
+		return err
+	}
+
+	err = queries.UpsertDelivery(ctx, db.UpsertDeliveryParams(deliveryParams(order)))
+	if err != nil {
+		logging.FromContext(ctx).Error("Error upserting delivery", "error", err)
+		return err
+	}
 
-		err = r.cache.UpdateCache(ctx, order)
+	err = queries.UpsertPayment(ctx, db.UpsertPaymentParams(paymentParams(order)))
+	if err != nil {
+		logging.FromContext(ctx).Error("Error upserting payment", "error", err)
+		return err
+	}
+
+	rids := make([]string, 0, len(order.Items))
+	for _, item := range order.Items {
+		err = queries.UpsertItem(ctx, db.UpsertItemParams(itemParams(order.OrderUID, item)))
 		if err != nil {
+			logging.FromContext(ctx).Error("Error upserting item", "error", err)
 			return err
 		}
+		rids = append(rids, item.Rid)
+	}
+
+	err = queries.DeleteStaleItems(ctx, db.DeleteStaleItemsParams{
+		OrderUid: order.OrderUID,
+		Rids:     rids,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error deleting stale items", "error", err)
+		return err
 	}
 	return nil
 }
 
-func (r *Repository) GetOrderById(order_uid string, ctx context.Context, useCache bool) (*g.Order, error) {
-	var orderData *g.Order
+func orderParams(order *g.Order) db.CreateOrderParams {
+	return db.CreateOrderParams{
+		OrderUid:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		Entry:       order.Entry,
+		Locale:      order.Locale,
+		InternalSignature: sql.NullString{
+			String: order.InternalSignature,
+			Valid:  order.InternalSignature != "",
+		},
+		CustomerID:      order.CustomerID,
+		DeliveryService: order.DeliveryService,
+		Shardkey:        order.Shardkey,
+		SmID:            int32(order.SmID),
+		DateCreated:     order.DateCreated,
+		OofShard:        order.OofShard,
+	}
+}
 
+func deliveryParams(order *g.Order) db.CreateDeliveryParams {
+	return db.CreateDeliveryParams{
+		OrderUid: order.OrderUID,
+		Name:     order.Delivery.Name,
+		Phone:    order.Delivery.Phone,
+		Zip:      order.Delivery.Zip,
+		City:     order.Delivery.City,
+		Address:  order.Delivery.Address,
+		Region:   order.Delivery.Region,
+		Email:    order.Delivery.Email,
+	}
+}
+
+func paymentParams(order *g.Order) db.CreatePaymentParams {
+	return db.CreatePaymentParams{
+		OrderUid:    order.OrderUID,
+		Transaction: order.Payment.Transaction,
+		RequestID: sql.NullString{
+			String: order.Payment.RequestID,
+			Valid:  order.Payment.RequestID != "",
+		},
+		Currency:     order.Payment.Currency,
+		Provider:     order.Payment.Provider,
+		Amount:       int32(order.Payment.Amount),
+		PaymentDt:    int64(order.Payment.PaymentDT),
+		Bank:         order.Payment.Bank,
+		DeliveryCost: int32(order.Payment.DeliveryCost),
+		GoodsTotal:   int32(order.Payment.GoodsTotal),
+		CustomFee:    int32(order.Payment.CustomFee),
+	}
+}
+
+func itemParams(orderUID string, item g.Item) db.CreateItemParams {
+	return db.CreateItemParams{
+		OrderUid:    orderUID,
+		ChrtID:      int32(item.ChrtID),
+		TrackNumber: item.TrackNumber,
+		Price:       int32(item.Price),
+		Rid:         item.Rid,
+		Name:        item.Name,
+		Sale:        int32(item.Sale),
+		Size:        item.Size,
+		TotalPrice:  int32(item.TotalPrice),
+		NmID:        int32(item.NmID),
+		Brand:       item.Brand,
+		Status:      int32(item.Status),
+	}
+}
+
+// sameOrder сравнивает сохраненный заказ с пришедшим повторно. Время
+// сравнивается с точностью postgres, порядок товаров не учитывается
+func sameOrder(stored, incoming *g.Order) bool {
+	normalize := func(order *g.Order) (g.Order, map[string]g.Item) {
+		o := *order
+		o.DateCreated = o.DateCreated.Truncate(time.Microsecond)
+		o.Delivery.OrderUID = ""
+		o.Payment.OrderUID = ""
+		// Статус ведет сервис, в пришедших заказах его нет
+		o.Status = ""
+
+		items := make(map[string]g.Item, len(o.Items))
+		for _, item := range o.Items {
+			item.OrderUID = ""
+			items[item.Rid] = item
+		}
+		o.Items = nil
+		return o, items
+	}
+
+	a, aItems := normalize(stored)
+	b, bItems := normalize(incoming)
+
+	if !a.DateCreated.Equal(b.DateCreated) {
+		return false
+	}
+	a.DateCreated, b.DateCreated = time.Time{}, time.Time{}
+
+	if !reflect.DeepEqual(a, b) || len(aItems) != len(bItems) || len(stored.Items) != len(incoming.Items) {
+		return false
+	}
+	for rid, item := range aItems {
+		if bItems[rid] != item {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Repository) GetOrderById(order_uid string, ctx context.Context, useCache bool) (*g.Order, error) {
 	if useCache {
 		orderData, err := r.cache.GetFromCache(ctx, order_uid)
-		if err != nil {
-			useCache = false
-		} else {
+		if err == nil {
 			return orderData, nil
 		}
 	}
 
-	if !useCache {
-		queries := db.New(r.DB)
+	if useCache && r.notFound.contains(order_uid) {
+		metrics.NotFoundHits.Inc()
+		return nil, sql.ErrNoRows
+	}
 
-		order, err := queries.GetSpecificOrder(ctx, order_uid)
-		if err != nil {
-			log.Println("Error getting order:", err)
-			return nil, err
+	// Загрузка не отменяется вместе с запросом, который ее начал:
+	// ее результат могут ждать другие запросы того же заказа
+	loadCtx := context.WithoutCancel(ctx)
+	result := r.loads.DoChan(order_uid, func() (any, error) {
+		return r.loadAndCache(loadCtx, order_uid)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Shared {
+			metrics.LoadsCoalesced.Inc()
+		}
+		if res.Err != nil {
+			return nil, res.Err
 		}
+		return res.Val.(*g.Order), nil
+	}
+}
+
+// loadAndCache загружает заказ из бд и кэширует его, а отсутствующий uid запоминает
+func (r *Repository) loadAndCache(ctx context.Context, order_uid string) (*g.Order, error) {
+	orderData, err := loadOrder(ctx, db.New(r.DB), order_uid)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.notFound.add(order_uid, r.NotFoundTTL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.cache.UpdateCache(ctx, orderData)
+	if err != nil {
+		return nil, err
+	}
+	return orderData, nil
+}
+
+// loadOrder собирает заказ целиком из таблиц orders, delivery, payments и items одним запросом
+func loadOrder(ctx context.Context, queries *db.Queries, order_uid string) (*g.Order, error) {
+	row, err := queries.GetFullOrder(ctx, order_uid)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error getting order", "error", err)
+		return nil, err
+	}
+
+	order, err := fullOrder(row.Order, row.Delivery, row.Payment, row.Items)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error decoding order", "order_uid", order_uid, "error", err)
+		return nil, err
+	}
+	return order, nil
+}
+
+// loadOrders собирает заказы с указанными uid одним запросом, от новых к старым
+func loadOrders(ctx context.Context, queries *db.Queries, uids []string) ([]*g.Order, error) {
+	rows, err := queries.GetFullOrders(ctx, uids)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error getting orders", "error", err)
+		return nil, err
+	}
 
-		delivery, err := queries.GetSpecificDelivery(ctx, order_uid)
+	ordersList := make([]*g.Order, 0, len(rows))
+	for _, row := range rows {
+		order, err := fullOrder(row.Order, row.Delivery, row.Payment, row.Items)
 		if err != nil {
-			log.Println("Error getting delivery:", err)
+			logging.FromContext(ctx).Error("Error decoding order", "order_uid", row.Order.OrderUid, "error", err)
 			return nil, err
 		}
+		ordersList = append(ordersList, order)
+	}
+	return ordersList, nil
+}
 
-		payments, err := queries.GetSpecificPayment(ctx, order_uid)
+// fullOrder собирает заказ из строки orders и агрегированных в JSON
+// строк delivery, payments и items
+func fullOrder(order db.Order, delivery, payment, items json.RawMessage) (*g.Order, error) {
+	result := &g.Order{
+		OrderUID:          order.OrderUid,
+		TrackNumber:       order.TrackNumber,
+		Entry:             order.Entry,
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature.String,
+		CustomerID:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.Shardkey,
+		SmID:              int(order.SmID),
+		DateCreated:       order.DateCreated,
+		OofShard:          order.OofShard,
+		Status:            order.Status,
+	}
+
+	if err := json.Unmarshal(delivery, &result.Delivery); err != nil {
+		return nil, fmt.Errorf("delivery: %w", err)
+	}
+	if err := json.Unmarshal(payment, &result.Payment); err != nil {
+		return nil, fmt.Errorf("payment: %w", err)
+	}
+	if err := json.Unmarshal(items, &result.Items); err != nil {
+		return nil, fmt.Errorf("items: %w", err)
+	}
+	return result, nil
+}
+
+// UpdateStatus переводит заказ в статус to, если автомат статусов разрешает
+// переход из текущего, и записывает переход в историю. Заказ блокируется
+// до конца транзакции, поэтому одновременные переходы выполняются по очереди
+func (r *Repository) UpdateStatus(ctx context.Context, order_uid string, to status.Status, reason string) (*status.Change, error) {
+	var change *status.Change
+	err := r.inTx(ctx, func(queries *db.Queries) error {
+		current, err := queries.GetOrderStatusForUpdate(ctx, order_uid)
 		if err != nil {
-			log.Println("Error getting payment:", err)
-			return nil, err
+			if !errors.Is(err, sql.ErrNoRows) {
+				logging.FromContext(ctx).Error("Error getting order status", "error", err)
+			}
+			return err
 		}
 
-		items, err := queries.GetSpecificItems(ctx, order_uid)
+		from, err := status.Parse(current)
 		if err != nil {
-			log.Println("Error getting items:", err)
-			return nil, err
+			return err
 		}
-
-		var itemsList []g.Item
-		for _, item := range items {
-			itemsList = append(itemsList, g.Item{
-				ChrtID:      int(item.ChrtID),
-				TrackNumber: item.TrackNumber,
-				Price:       int(item.Price),
-				Rid:         item.Rid,
-				Name:        item.Name,
-				Sale:        int(item.Sale),
-				Size:        item.Size,
-				TotalPrice:  int(item.TotalPrice),
-				NmID:        int(item.NmID),
-				Brand:       item.Brand,
-				Status:      int(item.Status),
-			})
+		err = status.Transition(from, to)
+		if err != nil {
+			return err
 		}
 
-		orderData = &g.Order{
-			OrderUID:    order.OrderUid,
-			TrackNumber: order.TrackNumber,
-			Entry:       order.Entry,
-			Delivery: g.Delivery{
-				Name:    delivery.Name,
-				Phone:   delivery.Phone,
-				Zip:     delivery.Zip,
-				City:    delivery.City,
-				Address: delivery.Address,
-				Region:  delivery.Region,
-				Email:   delivery.Email,
-			},
-			Payment: g.Payment{
-				Transaction:  payments.Transaction,
-				RequestID:    payments.RequestID.String,
-				Currency:     payments.Currency,
-				Provider:     payments.Provider,
-				Amount:       int(payments.Amount),
-				PaymentDT:    int(payments.PaymentDt),
-				Bank:         payments.Bank,
-				DeliveryCost: int(payments.DeliveryCost),
-				GoodsTotal:   int(payments.GoodsTotal),
-				CustomFee:    int(payments.CustomFee),
-			},
-			Items:             itemsList,
-			Locale:            order.Locale,
-			InternalSignature: order.InternalSignature.String,
-			CustomerID:        order.CustomerID,
-			DeliveryService:   order.DeliveryService,
-			Shardkey:          order.Shardkey,
-			SmID:              int(order.SmID),
-			DateCreated:       order.DateCreated,
-			OofShard:          order.OofShard,
+		err = queries.UpdateOrderStatus(ctx, db.UpdateOrderStatusParams{
+			OrderUid: order_uid,
+			Status:   string(to),
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Error updating order status", "error", err)
+			return err
 		}
 
-		err = r.cache.UpdateCache(ctx, orderData)
+		changedAt, err := queries.CreateStatusChange(ctx, db.CreateStatusChangeParams{
+			OrderUid:   order_uid,
+			FromStatus: nullString(string(from)),
+			ToStatus:   string(to),
+			Reason:     nullString(reason),
+		})
 		if err != nil {
-			return nil, err
+			logging.FromContext(ctx).Error("Error inserting status change", "error", err)
+			return err
 		}
-	}
-	return orderData, nil
-}
-
-func (r *Repository) GetAllOrders(ctx context.Context) ([]*g.Order, error) {
-	queries := db.New(r.DB)
 
-	orders, err := queries.GetOrders(ctx)
+		change = &status.Change{
+			OrderUID:  order_uid,
+			From:      from,
+			To:        to,
+			Reason:    reason,
+			ChangedAt: changedAt,
+		}
+		return nil
+	})
 	if err != nil {
-		log.Fatalln("Error getting orders:", err)
 		return nil, err
 	}
 
-	deliveries, err := queries.GetDelivery(ctx)
+	// Ошибка кэша не возвращается: статус уже зафиксирован в бд
+	err = r.cache.Invalidate(ctx, order_uid)
 	if err != nil {
-		log.Fatalln("Error getting deliveries:", err)
-		return nil, err
+		logging.FromContext(ctx).Error("Error invalidating cached order", "order_uid", order_uid, "error", err)
 	}
+	return change, nil
+}
 
-	payments, err := queries.GetPayment(ctx)
+// GetStatusHistory возвращает переходы статусов заказа от первого к последнему
+func (r *Repository) GetStatusHistory(ctx context.Context, order_uid string) ([]status.Change, error) {
+	rows, err := db.New(r.DB).GetStatusHistory(ctx, order_uid)
 	if err != nil {
-		log.Fatalln("Error getting payments:", err)
+		logging.FromContext(ctx).Error("Error getting status history", "error", err)
 		return nil, err
 	}
 
-	items, err := queries.GetItems(ctx)
-	if err != nil {
-		log.Fatalln("Error getting items:", err)
-		return nil, err
-
+	history := make([]status.Change, 0, len(rows))
+	for _, row := range rows {
+		history = append(history, status.Change{
+			OrderUID:  row.OrderUid,
+			From:      status.Status(row.FromStatus.String),
+			To:        status.Status(row.ToStatus),
+			Reason:    row.Reason.String,
+			ChangedAt: row.ChangedAt,
+		})
 	}
+	return history, nil
+}
 
+// buildOrders собирает заказы из строк таблиц orders, delivery, payments и items
+func buildOrders(orders []db.Order, deliveries []db.Delivery, payments []db.Payment, items []db.Item) []*g.Order {
 	deliveriesMap := make(map[string]g.Delivery)
 	paymentsMap := make(map[string]g.Payment)
 	itemsMap := make(map[string][]g.Item)
@@ -323,32 +715,29 @@ func (r *Repository) GetAllOrders(ctx context.Context) ([]*g.Order, error) {
 			SmID:              int(order.SmID),
 			DateCreated:       order.DateCreated,
 			OofShard:          order.OofShard,
+			Status:            order.Status,
 		})
 	}
 
-	return ordersList, nil
+	return ordersList
 }
 
+// GetLatestOrders возвращает limit самых новых заказов двумя запросами:
+// за uid и за самими заказами
 func (r *Repository) GetLatestOrders(ctx context.Context, limit int32) ([]*g.Order, error) {
 	queries := db.New(r.DB)
 
 	latestOrders, err := queries.GetLatestOrders(ctx, limit)
 	if err != nil {
-		log.Println("Error getting latest orders:", err)
+		logging.FromContext(ctx).Error("Error getting latest orders", "error", err)
 		return nil, err
 	}
 
-	var ordersList []*g.Order
-	for _, orderUID := range latestOrders {
-		orderData, err := r.GetOrderById(orderUID, ctx, false)
-		if err != nil {
-			log.Println("Error getting order data by id:", err)
-			continue
-		}
-		ordersList = append(ordersList, orderData)
-	}
+	return loadOrders(ctx, queries, latestOrders)
+}
 
-	return ordersList, nil
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.DB.PingContext(ctx)
 }
 
 func (r *Repository) Close() error {
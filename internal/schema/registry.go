@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Схемы вида <тип события>.v<версия>.json, например order.created.v2.json.
+// Версии одного события нумеруются подряд с 1, старые версии не удаляются,
+// пока в топиках могут оставаться сообщения в их формате
+//
+//go:embed schemas/*.json
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^([a-z_.]+)\.v(\d+)\.json$`)
+
+// Типы событий, которые публикует сервис
+const (
+	OrderCreated       = "order.created"
+	OrderStatusChanged = "order.status_changed"
+)
+
+var (
+	ErrUnknownEventType = errors.New("unknown event type")
+	ErrUnknownVersion   = errors.New("unknown schema version")
+)
+
+// Registry хранит все версии схем каждого типа события
+type Registry struct {
+	// versions[eventType][i] - схема версии i+1
+	versions map[string][]*Schema
+}
+
+// Default - реестр встроенных в бинарник схем
+var Default = mustLoad()
+
+func mustLoad() *Registry {
+	r, err := Load()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Load читает встроенные в бинарник схемы
+func Load() (*Registry, error) {
+	return load(files, "schemas")
+}
+
+func load(fsys fs.FS, dir string) (*Registry, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading schemas: %w", err)
+	}
+
+	byVersion := make(map[string]map[int]*Schema)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected schema file name %q", entry.Name())
+		}
+		version, err := strconv.Atoi(match[2])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid schema version in %q", entry.Name())
+		}
+
+		data, err := fs.ReadFile(fsys, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("Error reading schema %s: %w", entry.Name(), err)
+		}
+		s, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		if byVersion[match[1]] == nil {
+			byVersion[match[1]] = make(map[int]*Schema)
+		}
+		byVersion[match[1]][version] = s
+	}
+
+	r := &Registry{versions: make(map[string][]*Schema, len(byVersion))}
+	for eventType, schemas := range byVersion {
+		versions := make([]*Schema, len(schemas))
+		for version, s := range schemas {
+			if version > len(schemas) {
+				return nil, fmt.Errorf("schema versions of %s should go in a row from 1, got v%d of %d", eventType, version, len(schemas))
+			}
+			versions[version-1] = s
+		}
+		r.versions[eventType] = versions
+	}
+	return r, nil
+}
+
+// Schema возвращает схему версии version события eventType
+func (r *Registry) Schema(eventType string, version int) (*Schema, error) {
+	versions, ok := r.versions[eventType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEventType, eventType)
+	}
+	if version <= 0 || version > len(versions) {
+		return nil, fmt.Errorf("%w: %s v%d, latest is v%d", ErrUnknownVersion, eventType, version, len(versions))
+	}
+	return versions[version-1], nil
+}
+
+// Latest возвращает последнюю версию схемы события или 0 для неизвестного события
+func (r *Registry) Latest(eventType string) int {
+	return len(r.versions[eventType])
+}
+
+// EventTypes возвращает отсортированные типы событий реестра
+func (r *Registry) EventTypes() []string {
+	types := make([]string, 0, len(r.versions))
+	for eventType := range r.versions {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,256 @@
+// Package schema - локальный реестр JSON Schema сообщений Kafka. Схемы
+// встроены в бинарник, каждая версия события описана отдельным файлом.
+// Поддерживается подмножество JSON Schema, достаточное для заказов и событий
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema - схема значения: type, properties, required, items, enum и format date-time.
+// Остальные ключевые слова JSON Schema не поддерживаются, и схема с ними не загрузится
+type Schema struct {
+	Type       Types              `json:"type"`
+	Properties map[string]*Schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *Schema            `json:"items"`
+	Enum       []any              `json:"enum"`
+	Format     string             `json:"format"`
+
+	// Описательные ключевые слова на проверку не влияют
+	Dialect     string `json:"$schema"`
+	ID          string `json:"$id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// Types - допустимые типы значения. В схеме записывается строкой или массивом строк
+type Types []string
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("type should be a string or an array of strings: %w", err)
+	}
+	*t = many
+	return nil
+}
+
+var knownTypes = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+// Parse разбирает схему и проверяет, что в ней только поддерживаемые ключевые слова
+func Parse(data []byte) (*Schema, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var s Schema
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+	if err := s.check(""); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) check(path string) error {
+	for _, t := range s.Type {
+		if !slices.Contains(knownTypes, t) {
+			return fmt.Errorf("%s: unknown type %q", pathOrRoot(path), t)
+		}
+	}
+	if s.Format != "" && s.Format != "date-time" {
+		return fmt.Errorf("%s: unsupported format %q", pathOrRoot(path), s.Format)
+	}
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			return fmt.Errorf("%s: required property %q is not described", pathOrRoot(path), name)
+		}
+	}
+	for name, p := range s.Properties {
+		if err := p.check(join(path, name)); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+// FieldError - несоответствие значения схеме. Path - путь к полю, например items[2].price
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return pathOrRoot(e.Path) + ": " + e.Message
+}
+
+// Errors - все несоответствия значения схеме
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate проверяет JSON значение data и возвращает Errors со всеми несоответствиями
+func (s *Schema) Validate(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+	return s.ValidateValue(value)
+}
+
+// ValidateValue проверяет значение, разобранное encoding/json с UseNumber
+func (s *Schema) ValidateValue(value any) error {
+	var errs Errors
+	s.validate("", value, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, value any, errs *Errors) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return hasType(value, t) }) {
+		fail("should be %s, got %s", strings.Join(s.Type, " or "), typeOf(value))
+		return
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return equal(e, value) }) {
+		fail("should be one of %s, got %s", formatEnum(s.Enum), formatValue(value))
+	}
+
+	if str, ok := value.(string); ok && s.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+			fail("should be an RFC 3339 date-time, got %q", str)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, FieldError{Path: join(path, name), Message: "is required"})
+			}
+		}
+		// Неописанные поля разрешены: так более новые версии читаются старыми схемами
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if field, ok := v[name]; ok {
+				s.Properties[name].validate(join(path, name), field, errs)
+			}
+		}
+	case []any:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range v {
+			s.Items.validate(path+"["+strconv.Itoa(i)+"]", item, errs)
+		}
+	}
+}
+
+func hasType(value any, t string) bool {
+	switch v := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case json.Number:
+		if t == "number" {
+			return true
+		}
+		_, err := v.Int64()
+		return t == "integer" && err == nil
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	}
+	return false
+}
+
+func typeOf(value any) string {
+	for _, t := range knownTypes {
+		if hasType(value, t) {
+			return t
+		}
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// equal сравнивает значение из enum схемы со значением сообщения, числа - по записи
+func equal(enum, value any) bool {
+	if n, ok := value.(json.Number); ok {
+		if e, ok := enum.(float64); ok {
+			f, err := n.Float64()
+			return err == nil && f == e
+		}
+		return false
+	}
+	switch value.(type) {
+	case []any, map[string]any:
+		return false
+	}
+	return enum == value
+}
+
+func formatEnum(enum []any) string {
+	values := make([]string, len(enum))
+	for i, e := range enum {
+		values[i] = formatValue(e)
+	}
+	return strings.Join(values, ", ")
+}
+
+func formatValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
@@ -0,0 +1,148 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "test",
+  "type": "object",
+  "properties": {
+    "id": {"type": "string"},
+    "count": {"type": "integer"},
+    "state": {"type": "string", "enum": ["new", "done"]},
+    "at": {"type": "string", "format": "date-time"},
+    "tags": {"type": ["array", "null"], "items": {"type": "string"}}
+  },
+  "required": ["id", "count"]
+}`
+
+// schemaErrors возвращает несоответствия значения data схеме s
+func schemaErrors(t *testing.T, s *Schema, data string) Errors {
+	err := s.Validate([]byte(data))
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	require.True(t, errors.As(err, &errs), "Unexpected error: %v", err)
+	return errs
+}
+
+// Тестирует проверку значений схемой
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(testSchema))
+	require.NoError(t, err)
+
+	t.Run("Valid values", func(t *testing.T) {
+		for _, data := range []string{
+			`{"id": "a", "count": 1}`,
+			`{"id": "a", "count": 1, "state": "done", "at": "2025-10-08T18:26:22.5+03:00", "tags": ["x"]}`,
+			`{"id": "a", "count": 1, "tags": null}`,
+			// Неописанные поля не мешают: так новые версии читаются старыми схемами
+			`{"id": "a", "count": 1, "extra": {"nested": true}}`,
+		} {
+			assert.Empty(t, schemaErrors(t, s, data), "Value %s should be valid", data)
+		}
+	})
+
+	t.Run("Every mismatch is reported", func(t *testing.T) {
+		errs := schemaErrors(t, s, `{"count": 1.5, "state": "lost", "at": "yesterday", "tags": ["x", 2]}`)
+		assert.Equal(t, Errors{
+			{Path: "id", Message: "is required"},
+			{Path: "at", Message: `should be an RFC 3339 date-time, got "yesterday"`},
+			{Path: "count", Message: "should be integer, got number"},
+			{Path: "state", Message: `should be one of "new", "done", got "lost"`},
+			{Path: "tags[1]", Message: "should be string, got integer"},
+		}, errs)
+		assert.Contains(t, errs.Error(), "tags[1]: should be string")
+	})
+
+	t.Run("Wrong root type", func(t *testing.T) {
+		errs := schemaErrors(t, s, `[]`)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "(root): should be object, got array", errs[0].Error())
+	})
+
+	t.Run("Malformed JSON", func(t *testing.T) {
+		err := s.Validate([]byte(`{"id": `))
+		require.Error(t, err)
+		assert.False(t, errors.As(err, new(Errors)), "Syntax error is not a schema mismatch")
+	})
+}
+
+// Тестирует ошибки в самой схеме
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema string
+		error  string
+	}{
+		{"Unsupported keyword", `{"type": "string", "minLength": 1}`, "minLength"},
+		{"Unknown type", `{"type": "integr"}`, `unknown type "integr"`},
+		{"Unsupported format", `{"type": "string", "format": "email"}`, `unsupported format "email"`},
+		{"Undescribed required property", `{"type": "object", "required": ["id"]}`, `required property "id" is not described`},
+		{"Nested error", `{"type": "object", "properties": {"items": {"type": "array", "items": {"type": "integr"}}}}`, "items[]: unknown type"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.schema))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.error)
+		})
+	}
+}
+
+// Тестирует реестр встроенных схем
+func TestRegistry(t *testing.T) {
+	assert.Equal(t, []string{OrderCreated, OrderStatusChanged}, Default.EventTypes())
+	assert.Equal(t, 2, Default.Latest(OrderCreated))
+	assert.Equal(t, 1, Default.Latest(OrderStatusChanged))
+	assert.Equal(t, 0, Default.Latest("order.deleted"))
+
+	v1, err := Default.Schema(OrderCreated, 1)
+	require.NoError(t, err)
+	v2, err := Default.Schema(OrderCreated, 2)
+	require.NoError(t, err)
+
+	// В v1 значение oof_shard записывалось в поле status
+	assert.Contains(t, v1.Required, "status")
+	assert.NotContains(t, v1.Properties, "oof_shard")
+	assert.Contains(t, v2.Required, "oof_shard")
+
+	_, err = Default.Schema(OrderCreated, 3)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+	_, err = Default.Schema(OrderCreated, 0)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+	_, err = Default.Schema("order.deleted", 1)
+	assert.ErrorIs(t, err, ErrUnknownEventType)
+}
+
+// Тестирует ошибки в файлах реестра
+func TestLoadErrors(t *testing.T) {
+	object := &fstest.MapFile{Data: []byte(`{"type": "object"}`)}
+	cases := []struct {
+		name  string
+		files fstest.MapFS
+		error string
+	}{
+		{"Unexpected name", fstest.MapFS{"schemas/order.json": object}, `unexpected schema file name "order.json"`},
+		{"Zero version", fstest.MapFS{"schemas/order.v0.json": object}, "invalid schema version"},
+		{"Versions with a gap", fstest.MapFS{"schemas/order.v1.json": object, "schemas/order.v3.json": object}, "should go in a row from 1"},
+		{"Invalid schema", fstest.MapFS{"schemas/order.v1.json": {Data: []byte(`{"type": 1}`)}}, "order.v1.json"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := load(tc.files, "schemas")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.error)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package status
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status - этап жизненного цикла заказа
+type Status string
+
+const (
+	Created    Status = "created"
+	Paid       Status = "paid"
+	Assembling Status = "assembling"
+	Shipped    Status = "shipped"
+	Delivered  Status = "delivered"
+	Cancelled  Status = "cancelled"
+	Returned   Status = "returned"
+)
+
+// Initial - статус, с которым заказ сохраняется в бд
+const Initial = Created
+
+// transitions перечисляет допустимые переходы: отменить можно только
+// еще не отправленный заказ, а вернуть - только доставленный
+var transitions = map[Status][]Status{
+	Created:    {Paid, Cancelled},
+	Paid:       {Assembling, Cancelled},
+	Assembling: {Shipped, Cancelled},
+	Shipped:    {Delivered},
+	Delivered:  {Returned},
+	Cancelled:  {},
+	Returned:   {},
+}
+
+// ErrUnknownStatus возвращается при разборе названия, которого нет среди статусов
+var ErrUnknownStatus = errors.New("unknown order status")
+
+// ErrInvalidTransition возвращается при попытке перехода, не разрешенного автоматом
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// All возвращает все статусы в порядке жизненного цикла
+func All() []Status {
+	return []Status{Created, Paid, Assembling, Shipped, Delivered, Cancelled, Returned}
+}
+
+// Parse переводит название статуса из запроса или бд
+func Parse(name string) (Status, error) {
+	s := Status(name)
+	if _, ok := transitions[s]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownStatus, name)
+	}
+	return s, nil
+}
+
+// Next возвращает статусы, в которые можно перейти из s
+func (s Status) Next() []Status {
+	return append([]Status{}, transitions[s]...)
+}
+
+// Final сообщает, что из статуса s переходов нет
+func (s Status) Final() bool {
+	return len(transitions[s]) == 0
+}
+
+// CanTransition сообщает, разрешен ли переход из from в to
+func CanTransition(from, to Status) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition проверяет переход из from в to и возвращает
+// ErrInvalidTransition с пояснением, если он запрещен
+func Transition(from, to Status) error {
+	if CanTransition(from, to) {
+		return nil
+	}
+	if from.Final() {
+		return fmt.Errorf("%w: %s is final", ErrInvalidTransition, from)
+	}
+	return fmt.Errorf("%w: %s -> %s, allowed: %v", ErrInvalidTransition, from, to, from.Next())
+}
+
+// Change описывает смену статуса заказа. From пуст для начального статуса
+type Change struct {
+	OrderUID  string    `json:"order_uid"`
+	From      Status    `json:"from,omitempty"`
+	To        Status    `json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -0,0 +1,76 @@
+package status
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирует разбор названий статусов
+func TestParse(t *testing.T) {
+	for _, s := range All() {
+		parsed, err := Parse(string(s))
+		require.NoError(t, err)
+		assert.Equal(t, s, parsed)
+	}
+
+	_, err := Parse("lost")
+	assert.True(t, errors.Is(err, ErrUnknownStatus))
+
+	_, err = Parse("")
+	assert.True(t, errors.Is(err, ErrUnknownStatus))
+}
+
+// Тестирует допустимые и запрещенные переходы
+func TestTransition(t *testing.T) {
+	cases := []struct {
+		from, to Status
+		allowed  bool
+	}{
+		{Created, Paid, true},
+		{Paid, Assembling, true},
+		{Assembling, Shipped, true},
+		{Shipped, Delivered, true},
+		{Delivered, Returned, true},
+		{Created, Cancelled, true},
+		{Assembling, Cancelled, true},
+
+		{Created, Shipped, false},
+		{Shipped, Cancelled, false},
+		{Paid, Paid, false},
+		{Paid, Created, false},
+		{Cancelled, Paid, false},
+		{Returned, Delivered, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.from)+" -> "+string(tc.to), func(t *testing.T) {
+			assert.Equal(t, tc.allowed, CanTransition(tc.from, tc.to))
+
+			err := Transition(tc.from, tc.to)
+			if tc.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, ErrInvalidTransition))
+			}
+		})
+	}
+}
+
+// Тестирует, что из каждого статуса, кроме конечных, можно уйти,
+// и все переходы ведут в известные статусы
+func TestTransitionsAreComplete(t *testing.T) {
+	for _, s := range All() {
+		for _, next := range s.Next() {
+			_, err := Parse(string(next))
+			assert.NoError(t, err, "%s leads to unknown status %s", s, next)
+		}
+	}
+
+	assert.True(t, Cancelled.Final())
+	assert.True(t, Returned.Final())
+	assert.False(t, Created.Final())
+	assert.Contains(t, Transition(Cancelled, Paid).Error(), "cancelled is final")
+}
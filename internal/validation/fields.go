@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"orders/internal/generator"
+)
+
+// field - поле заказа, на которое можно сослаться в политике.
+// Путь составляется из json тегов: entry, delivery.phone, items.sale
+type field struct {
+	path string
+	// item - поле товара, оно проверяется у каждого товара заказа
+	item  bool
+	index []int
+	kind  reflect.Kind
+}
+
+// fields - строковые и числовые поля заказа, доставки, оплаты и товаров
+var fields = func() map[string]field {
+	m := make(map[string]field)
+	order := reflect.TypeOf(generator.Order{})
+	for i := range order.NumField() {
+		f := order.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		switch {
+		case f.Type.Kind() == reflect.Struct && f.Type.PkgPath() == order.PkgPath():
+			addFields(m, name+".", f.Type, []int{i}, false)
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Struct:
+			addFields(m, name+".", f.Type.Elem(), nil, true)
+		default:
+			addField(m, name, f, []int{i}, false)
+		}
+	}
+	return m
+}()
+
+func addFields(m map[string]field, prefix string, t reflect.Type, index []int, item bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if name := jsonName(f); name != "" {
+			addField(m, prefix+name, f, append(append([]int{}, index...), i), item)
+		}
+	}
+}
+
+func addField(m map[string]field, path string, f reflect.StructField, index []int, item bool) {
+	switch f.Type.Kind() {
+	case reflect.String, reflect.Int:
+		m[path] = field{path: path, item: item, index: index, kind: f.Type.Kind()}
+	}
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// lookupField находит поле по пути и проверяет, что у него нужный тип
+func lookupField(path string, kind reflect.Kind) (field, error) {
+	f, ok := fields[path]
+	if !ok {
+		return field{}, fmt.Errorf("unknown field %q", path)
+	}
+	if f.kind != kind {
+		return field{}, fmt.Errorf("field %q is not a %s", path, kindName(kind))
+	}
+	return f, nil
+}
+
+func kindName(kind reflect.Kind) string {
+	if kind == reflect.Int {
+		return "number"
+	}
+	return "string"
+}
+
+// each вызывает fn для значения поля заказа, а для поля товара - для каждого товара.
+// name - путь к значению в заказе, например items[2].sale
+func (f field) each(o *generator.Order, fn func(name string, v reflect.Value)) {
+	if !f.item {
+		fn(f.path, reflect.ValueOf(o).Elem().FieldByIndex(f.index))
+		return
+	}
+	_, itemPath, _ := strings.Cut(f.path, ".")
+	for i := range o.Items {
+		fn(itemField(i, itemPath), reflect.ValueOf(&o.Items[i]).Elem().FieldByIndex(f.index))
+	}
+}
+
+// sortedKeys возвращает ключи в порядке возрастания, чтобы нарушения
+// выводились в одном и том же порядке
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
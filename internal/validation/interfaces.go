@@ -0,0 +1,8 @@
+package validation
+
+import "orders/internal/generator"
+
+// OrderValidator описывает поведение Validator и Policies
+type OrderValidator interface {
+	Validate(order *generator.Order) error
+}
@@ -0,0 +1,114 @@
+package validation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"orders/internal/generator"
+	"orders/internal/logging"
+	"orders/internal/metrics"
+)
+
+// Policies - действующая политика валидации. Файл политики перечитывается
+// на лету: новые правила применяются к заказам, пришедшим после перечитывания
+type Policies struct {
+	path   string
+	active atomic.Pointer[activePolicy]
+}
+
+type activePolicy struct {
+	policy   *compiledPolicy
+	data     []byte
+	loadedAt time.Time
+}
+
+// NewPolicies загружает политику из файла path. Без файла заказы
+// проверяются только встроенными правилами пакета
+func NewPolicies(path string) (*Policies, error) {
+	p := &Policies{path: path}
+	if path == "" {
+		p.active.Store(&activePolicy{policy: builtinPolicy, loadedAt: time.Now()})
+		return p, nil
+	}
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload перечитывает файл политики и сообщает, изменилась ли она.
+// Если новая политика некорректна, остается действовать прежняя
+func (p *Policies) Reload() (bool, error) {
+	if p.path == "" {
+		return false, nil
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return false, fmt.Errorf("Error reading validation policy: %w", err)
+	}
+	if current := p.active.Load(); current != nil && bytes.Equal(current.data, data) {
+		return false, nil
+	}
+
+	policy, err := loadPolicy(data)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", p.path, err)
+	}
+	p.active.Store(&activePolicy{policy: policy, data: data, loadedAt: time.Now()})
+	return true, nil
+}
+
+// Watch проверяет файл политики каждые interval, пока не отменен ctx
+func (p *Policies) Watch(ctx context.Context, interval time.Duration) {
+	if p.path == "" || interval <= 0 {
+		return
+	}
+	logger := logging.FromContext(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := p.Reload()
+		if err != nil {
+			logger.Error("Error reloading validation policy, keeping the previous one", "path", p.path, "error", err)
+			metrics.PolicyReloads.WithLabelValues("error").Inc()
+			continue
+		}
+		if changed {
+			logger.Info("Validation policy reloaded", "path", p.path)
+			metrics.PolicyReloads.WithLabelValues("ok").Inc()
+		}
+	}
+}
+
+// Validate проверяет заказ встроенными правилами и набором политики для его entry
+func (p *Policies) Validate(order *generator.Order) error {
+	v, _ := p.active.Load().policy.validator(order.Entry)
+	return v.Validate(order)
+}
+
+// Ruleset возвращает название набора правил, которым проверяются заказы с entry
+func (p *Policies) Ruleset(entry string) string {
+	_, name := p.active.Load().policy.validator(entry)
+	return name
+}
+
+// Source возвращает путь к файлу политики, пустой для встроенных правил
+func (p *Policies) Source() string {
+	return p.path
+}
+
+// LoadedAt возвращает время загрузки действующей политики
+func (p *Policies) LoadedAt() time.Time {
+	return p.active.Load().loadedAt
+}
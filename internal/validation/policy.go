@@ -0,0 +1,241 @@
+package validation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"orders/internal/generator"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRuleset - название набора правил для заказов, у entry которых нет своего
+const DefaultRuleset = "default"
+
+// Policy - правила валидации из файла политики. Они применяются поверх
+// встроенных правил пакета. Набор из Entries выбирается по полю entry
+// заказа и полностью заменяет Default
+type Policy struct {
+	Default Ruleset            `yaml:"default"`
+	Entries map[string]Ruleset `yaml:"entries"`
+}
+
+// Ruleset - набор правил по путям полей заказа (delivery.phone, items.sale).
+// Правила для полей товаров проверяются у каждого товара. Pattern и Enum
+// пропускают пустые строки: обязательность задается в Required
+type Ruleset struct {
+	// Required - поля, которые не должны быть пустыми или нулевыми
+	Required []string `yaml:"required"`
+	// Patterns - регулярные выражения для строковых полей
+	Patterns map[string]string `yaml:"patterns"`
+	// Ranges - допустимые границы числовых полей
+	Ranges map[string]Range `yaml:"ranges"`
+	// Enums - допустимые значения строковых полей
+	Enums map[string][]string `yaml:"enums"`
+}
+
+// Range - границы числового поля включительно, любая из них может отсутствовать
+type Range struct {
+	Min *int `yaml:"min"`
+	Max *int `yaml:"max"`
+}
+
+func (r Range) contains(v int) bool {
+	return (r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max)
+}
+
+func (r Range) String() string {
+	switch {
+	case r.Min != nil && r.Max != nil:
+		return fmt.Sprintf("from %d to %d", *r.Min, *r.Max)
+	case r.Min != nil:
+		return fmt.Sprintf("at least %d", *r.Min)
+	case r.Max != nil:
+		return fmt.Sprintf("at most %d", *r.Max)
+	}
+	return "any number"
+}
+
+// loadPolicy разбирает политику в формате YAML или JSON и проверяет,
+// что все поля существуют, регулярные выражения корректны, а границы не перепутаны
+func loadPolicy(data []byte) (*compiledPolicy, error) {
+	var p Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("Error parsing validation policy: %w", err)
+	}
+	return p.compile()
+}
+
+// compiledPolicy - валидаторы политики по entry
+type compiledPolicy struct {
+	fallback *Validator
+	entries  map[string]*Validator
+}
+
+// builtinPolicy используется, пока файл политики не задан
+var builtinPolicy = &compiledPolicy{fallback: Default}
+
+func (p *Policy) compile() (*compiledPolicy, error) {
+	var errs []error
+	compiled := &compiledPolicy{entries: make(map[string]*Validator, len(p.Entries))}
+
+	rules, err := p.Default.compile(DefaultRuleset)
+	errs = append(errs, err)
+	compiled.fallback = Default.With(rules...)
+
+	for _, entry := range sortedKeys(p.Entries) {
+		rules, err := p.Entries[entry].compile("entries." + entry)
+		errs = append(errs, err)
+		compiled.entries[entry] = Default.With(rules...)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("Invalid validation policy:\n%w", err)
+	}
+	return compiled, nil
+}
+
+func (p *compiledPolicy) validator(entry string) (*Validator, string) {
+	if v, ok := p.entries[entry]; ok {
+		return v, entry
+	}
+	return p.fallback, DefaultRuleset
+}
+
+// fieldCheck - проверка одного поля: ok сообщает, подходит ли значение,
+// а message объясняет, каким оно должно быть
+type fieldCheck struct {
+	field   field
+	ok      func(v reflect.Value) bool
+	message func(v reflect.Value) string
+}
+
+// compile превращает набор в правила Validator. prefix - путь к набору в файле для ошибок
+func (rs Ruleset) compile(prefix string) ([]Rule, error) {
+	var errs []error
+	fail := func(key string, err error) {
+		errs = append(errs, fmt.Errorf("%s.%s: %w", prefix, key, err))
+	}
+
+	var required []fieldCheck
+	for _, path := range rs.Required {
+		f, ok := fields[path]
+		if !ok {
+			fail("required", fmt.Errorf("unknown field %q", path))
+			continue
+		}
+		required = append(required, fieldCheck{
+			field:   f,
+			ok:      func(v reflect.Value) bool { return !v.IsZero() },
+			message: func(reflect.Value) string { return "is required" },
+		})
+	}
+
+	var patterns []fieldCheck
+	for _, path := range sortedKeys(rs.Patterns) {
+		f, err := lookupField(path, reflect.String)
+		if err != nil {
+			fail("patterns", err)
+			continue
+		}
+		pattern := rs.Patterns[path]
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			fail("patterns."+path, err)
+			continue
+		}
+		patterns = append(patterns, fieldCheck{
+			field: f,
+			ok:    func(v reflect.Value) bool { return v.String() == "" || re.MatchString(v.String()) },
+			message: func(v reflect.Value) string {
+				return fmt.Sprintf("should match %s, got %q", pattern, v.String())
+			},
+		})
+	}
+
+	var ranges []fieldCheck
+	for _, path := range sortedKeys(rs.Ranges) {
+		f, err := lookupField(path, reflect.Int)
+		if err != nil {
+			fail("ranges", err)
+			continue
+		}
+		r := rs.Ranges[path]
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			fail("ranges."+path, fmt.Errorf("min %d is greater than max %d", *r.Min, *r.Max))
+			continue
+		}
+		ranges = append(ranges, fieldCheck{
+			field: f,
+			ok:    func(v reflect.Value) bool { return r.contains(int(v.Int())) },
+			message: func(v reflect.Value) string {
+				return fmt.Sprintf("should be %s, got %d", r, v.Int())
+			},
+		})
+	}
+
+	var enums []fieldCheck
+	for _, path := range sortedKeys(rs.Enums) {
+		f, err := lookupField(path, reflect.String)
+		if err != nil {
+			fail("enums", err)
+			continue
+		}
+		allowed := rs.Enums[path]
+		if len(allowed) == 0 {
+			fail("enums."+path, errors.New("should list at least one value"))
+			continue
+		}
+		enums = append(enums, fieldCheck{
+			field: f,
+			ok: func(v reflect.Value) bool {
+				if v.String() == "" {
+					return true
+				}
+				for _, a := range allowed {
+					if v.String() == a {
+						return true
+					}
+				}
+				return false
+			},
+			message: func(v reflect.Value) string {
+				return fmt.Sprintf("should be one of %s, got %q", strings.Join(allowed, ", "), v.String())
+			},
+		})
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return []Rule{
+		checksRule("policy_required", required),
+		checksRule("policy_pattern", patterns),
+		checksRule("policy_range", ranges),
+		checksRule("policy_enum", enums),
+	}, nil
+}
+
+func checksRule(name string, checks []fieldCheck) Rule {
+	return Rule{
+		Name: name,
+		Check: func(o *generator.Order) []FieldError {
+			var errs []FieldError
+			for _, c := range checks {
+				c.field.each(o, func(name string, v reflect.Value) {
+					if !c.ok(v) {
+						errs = append(errs, FieldError{Field: name, Message: c.message(v)})
+					}
+				})
+			}
+			return errs
+		},
+	}
+}
@@ -0,0 +1,232 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"orders/internal/generator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `
+default:
+  required: [delivery.region]
+  patterns:
+    delivery.city: '^[A-Z]'
+  ranges:
+    items.sale: {min: 0, max: 50}
+  enums:
+    payment.bank: [Sber]
+entries:
+  WBIL_1:
+    ranges:
+      payment.amount: {max: 100}
+`
+
+// validationErrors возвращает нарушения, найденные валидатором v в заказе
+func validationErrors(t *testing.T, v OrderValidator, order *generator.Order) Errors {
+	err := v.Validate(order)
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	return errs
+}
+
+// Тестирует правила политики и выбор набора по entry
+func TestPolicy(t *testing.T) {
+	policy, err := loadPolicy([]byte(testPolicy))
+	require.NoError(t, err)
+
+	// Заказ, подходящий под набор default
+	newOrder := func() *generator.Order {
+		order := generator.MakeRandomOrder(1)[0]
+		order.Entry = "WBIL"
+		order.Delivery.Region = "Kraiot"
+		order.Delivery.City = "Moscow"
+		order.Payment.Bank = "Sber"
+		for i := range order.Items {
+			order.Items[i].Sale = 0
+			order.Items[i].TotalPrice = order.Items[i].Price
+		}
+		order.Payment.GoodsTotal = 0
+		for _, item := range order.Items {
+			order.Payment.GoodsTotal += item.TotalPrice
+		}
+		order.Payment.Amount = order.Payment.DeliveryCost + order.Payment.GoodsTotal + order.Payment.CustomFee
+		return order
+	}
+
+	t.Run("Default ruleset", func(t *testing.T) {
+		v, name := policy.validator("WBIL")
+		assert.Equal(t, DefaultRuleset, name)
+		require.Empty(t, validationErrors(t, v, newOrder()))
+
+		order := newOrder()
+		order.Delivery.Region = ""
+		order.Delivery.City = "moscow"
+		order.Payment.Bank = "Alpha"
+		order.Items[0].Sale = 60
+		order.Items[0].TotalPrice = order.Items[0].Price * 40 / 100
+		order.Payment.GoodsTotal -= order.Items[0].Price - order.Items[0].TotalPrice
+		order.Payment.Amount = order.Payment.DeliveryCost + order.Payment.GoodsTotal + order.Payment.CustomFee
+
+		// Нарушения идут по видам правил: обязательные поля, шаблоны, границы, перечисления
+		errs := validationErrors(t, v, order)
+		require.Len(t, errs, 4, "Unexpected errors: %v", errs)
+		assert.Equal(t, FieldError{Field: "delivery.region", Rule: "policy_required", Message: "is required"}, errs[0])
+		assert.Equal(t, FieldError{Field: "delivery.city", Rule: "policy_pattern", Message: `should match ^[A-Z], got "moscow"`}, errs[1])
+		assert.Equal(t, FieldError{Field: "items[0].sale", Rule: "policy_range", Message: "should be from 0 to 50, got 60"}, errs[2])
+		assert.Equal(t, FieldError{Field: "payment.bank", Rule: "policy_enum", Message: `should be one of Sber, got "Alpha"`}, errs[3])
+	})
+
+	t.Run("Entry ruleset replaces default", func(t *testing.T) {
+		v, name := policy.validator("WBIL_1")
+		assert.Equal(t, "WBIL_1", name)
+
+		order := newOrder()
+		order.Entry = "WBIL_1"
+		order.Payment.Bank = "Alpha"
+
+		errs := validationErrors(t, v, order)
+		require.Len(t, errs, 1, "Only the entry ruleset should apply: %v", errs)
+		assert.Equal(t, "payment.amount", errs[0].Field)
+		assert.Equal(t, "policy_range", errs[0].Rule)
+	})
+
+	t.Run("Built-in rules still apply", func(t *testing.T) {
+		v, _ := policy.validator("WBIL")
+		order := newOrder()
+		order.Payment.Amount++
+
+		errs := validationErrors(t, v, order)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "payment_amount", errs[0].Rule)
+	})
+}
+
+// Тестирует, что пример политики из репозитория корректен
+func TestPolicyExample(t *testing.T) {
+	data, err := os.ReadFile("../../policy.example.yaml")
+	require.NoError(t, err)
+
+	policy, err := loadPolicy(data)
+	require.NoError(t, err)
+	assert.Contains(t, policy.entries, "WBIL")
+	assert.Contains(t, policy.entries, "WBIL_1")
+
+	// Набор WBIL_1 наследует обязательные поля default через якорь
+	v, _ := policy.validator("WBIL_1")
+	order := generator.MakeRandomOrder(1)[0]
+	order.Delivery.Name = ""
+	order.Delivery.Phone = "+79991234567"
+	errs := validationErrors(t, v, order)
+	require.NotEmpty(t, errs)
+	assert.Equal(t, FieldError{Field: "delivery.name", Rule: "policy_required", Message: "is required"}, errs[0])
+}
+
+// Тестирует ошибки в файле политики
+func TestPolicyErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy string
+		error  string
+	}{
+		{"Unknown key", "default:\n  requierd: [entry]", "field requierd not found"},
+		{"Unknown field", "default:\n  required: [delivery.planet]", `default.required: unknown field "delivery.planet"`},
+		{"Pattern for number", "default:\n  patterns:\n    sm_id: '^1'", `default.patterns: field "sm_id" is not a string`},
+		{"Invalid pattern", "default:\n  patterns:\n    entry: '['", "default.patterns.entry: error parsing regexp"},
+		{"Range for string", "entries:\n  WBIL:\n    ranges:\n      entry: {min: 1}", `entries.WBIL.ranges: field "entry" is not a number`},
+		{"Reversed range", "default:\n  ranges:\n    items.sale: {min: 50, max: 10}", "default.ranges.items.sale: min 50 is greater than max 10"},
+		{"Empty enum", "default:\n  enums:\n    locale: []", "default.enums.locale: should list at least one value"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadPolicy([]byte(tc.policy))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.error)
+		})
+	}
+
+	// Все ошибки выводятся разом
+	_, err := loadPolicy([]byte("default:\n  required: [a, b]"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"a"`)
+	assert.Contains(t, err.Error(), `"b"`)
+
+	// JSON тоже подходит
+	_, err = loadPolicy([]byte(`{"default": {"enums": {"locale": ["ru"]}}}`))
+	assert.NoError(t, err)
+}
+
+// Тестирует перечитывание файла политики на лету
+func TestPoliciesReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	write := func(policy string) {
+		require.NoError(t, os.WriteFile(path, []byte(policy), 0o644))
+	}
+
+	order := generator.MakeRandomOrder(1)[0]
+	order.Entry = "WBIL"
+	order.Locale = "ru"
+
+	write("default:\n  enums:\n    locale: [ru]")
+	policies, err := NewPolicies(path)
+	require.NoError(t, err)
+	require.NoError(t, policies.Validate(order))
+	loadedAt := policies.LoadedAt()
+
+	t.Run("Unchanged file", func(t *testing.T) {
+		changed, err := policies.Reload()
+		require.NoError(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, loadedAt, policies.LoadedAt())
+	})
+
+	t.Run("Changed file", func(t *testing.T) {
+		write("entries:\n  WBIL:\n    enums:\n      locale: [en]")
+		changed, err := policies.Reload()
+		require.NoError(t, err)
+		assert.True(t, changed)
+		assert.Equal(t, "WBIL", policies.Ruleset("WBIL"))
+		assert.Equal(t, DefaultRuleset, policies.Ruleset("WBIL_2"))
+		assert.Error(t, policies.Validate(order))
+	})
+
+	t.Run("Invalid file keeps previous policy", func(t *testing.T) {
+		write("entries:\n  WBIL:\n    enums:\n      locale: {")
+		_, err := policies.Reload()
+		require.Error(t, err)
+		assert.Equal(t, "WBIL", policies.Ruleset("WBIL"))
+		assert.Error(t, policies.Validate(order))
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go policies.Watch(ctx, 10*time.Millisecond)
+
+		write("default:\n  enums:\n    locale: [ru]")
+		require.Eventually(t, func() bool {
+			return policies.Ruleset("WBIL") == DefaultRuleset
+		}, time.Second, 10*time.Millisecond, "Watch should pick up the new policy")
+		assert.NoError(t, policies.Validate(order))
+	})
+
+	// Без файла действуют только встроенные правила
+	builtin, err := NewPolicies("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRuleset, builtin.Ruleset("WBIL"))
+	assert.NoError(t, builtin.Validate(generator.MakeRandomOrder(1)[0]))
+
+	_, err = NewPolicies(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,225 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+
+	"orders/internal/generator"
+)
+
+// Форматы контактов. Телефон - от 10 до 15 цифр с необязательным +,
+// без ведущего нуля. Индекс - от 3 до 10 букв и цифр, допускаются пробел и дефис
+var (
+	phonePattern = regexp.MustCompile(`^\+?[1-9][0-9]{9,14}$`)
+	zipPattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 -]{1,8}[A-Za-z0-9]$`)
+)
+
+// Required проверяет поля, без которых заказ нельзя сохранить и найти
+var Required = Rule{
+	Name: "required",
+	Check: func(o *generator.Order) []FieldError {
+		var errs []FieldError
+		for _, f := range []struct{ field, value string }{
+			{"order_uid", o.OrderUID},
+			{"track_number", o.TrackNumber},
+			{"customer_id", o.CustomerID},
+		} {
+			if f.value == "" {
+				errs = append(errs, FieldError{Field: f.field, Message: "is required"})
+			}
+		}
+		return errs
+	},
+}
+
+// ItemsNotEmpty проверяет, что в заказе есть товары
+var ItemsNotEmpty = Rule{
+	Name: "items_not_empty",
+	Check: func(o *generator.Order) []FieldError {
+		if len(o.Items) == 0 {
+			return []FieldError{{Field: "items", Message: "should contain at least one item"}}
+		}
+		return nil
+	},
+}
+
+// ItemTrackNumbers проверяет, что товары относятся к трек-номеру заказа.
+// Без трек-номера заказа сравнивать не с чем, его отсутствие отмечает Required
+var ItemTrackNumbers = Rule{
+	Name: "item_track_number",
+	Check: func(o *generator.Order) []FieldError {
+		if o.TrackNumber == "" {
+			return nil
+		}
+		var errs []FieldError
+		for i, item := range o.Items {
+			if item.TrackNumber != o.TrackNumber {
+				errs = append(errs, FieldError{
+					Field:   itemField(i, "track_number"),
+					Message: fmt.Sprintf("should match order track_number %q, got %q", o.TrackNumber, item.TrackNumber),
+				})
+			}
+		}
+		return errs
+	},
+}
+
+// SaleRange проверяет, что скидка задана в процентах от 0 до 100
+var SaleRange = Rule{
+	Name: "sale_range",
+	Check: func(o *generator.Order) []FieldError {
+		var errs []FieldError
+		for i, item := range o.Items {
+			if item.Sale < 0 || item.Sale > 100 {
+				errs = append(errs, FieldError{
+					Field:   itemField(i, "sale"),
+					Message: fmt.Sprintf("should be from 0 to 100, got %d", item.Sale),
+				})
+			}
+		}
+		return errs
+	},
+}
+
+// ItemTotalPrices проверяет цену товара со скидкой: price * (100 - sale) / 100.
+// Товары со скидкой вне диапазона пропускаются, о них сообщает SaleRange
+var ItemTotalPrices = Rule{
+	Name: "item_total_price",
+	Check: func(o *generator.Order) []FieldError {
+		var errs []FieldError
+		for i, item := range o.Items {
+			if item.Sale < 0 || item.Sale > 100 {
+				continue
+			}
+			if item.Price < 0 {
+				errs = append(errs, FieldError{
+					Field:   itemField(i, "price"),
+					Message: fmt.Sprintf("should not be negative, got %d", item.Price),
+				})
+				continue
+			}
+			expected := item.Price * (100 - item.Sale) / 100
+			if item.TotalPrice != expected {
+				errs = append(errs, FieldError{
+					Field: itemField(i, "total_price"),
+					Message: fmt.Sprintf("should be %d for price %d and sale %d, got %d",
+						expected, item.Price, item.Sale, item.TotalPrice),
+				})
+			}
+		}
+		return errs
+	},
+}
+
+// GoodsTotal проверяет, что стоимость товаров в оплате равна сумме их цен
+var GoodsTotal = Rule{
+	Name: "goods_total",
+	Check: func(o *generator.Order) []FieldError {
+		sum := 0
+		for _, item := range o.Items {
+			sum += item.TotalPrice
+		}
+		if o.Payment.GoodsTotal != sum {
+			return []FieldError{{
+				Field:   "payment.goods_total",
+				Message: fmt.Sprintf("should be %d (sum of items total_price), got %d", sum, o.Payment.GoodsTotal),
+			}}
+		}
+		return nil
+	},
+}
+
+// PaymentAmount проверяет итог оплаты: доставка, товары и пошлина
+var PaymentAmount = Rule{
+	Name: "payment_amount",
+	Check: func(o *generator.Order) []FieldError {
+		p := o.Payment
+		var errs []FieldError
+		for _, f := range []struct {
+			field string
+			value int
+		}{
+			{"payment.delivery_cost", p.DeliveryCost},
+			{"payment.custom_fee", p.CustomFee},
+		} {
+			if f.value < 0 {
+				errs = append(errs, FieldError{Field: f.field, Message: fmt.Sprintf("should not be negative, got %d", f.value)})
+			}
+		}
+
+		expected := p.DeliveryCost + p.GoodsTotal + p.CustomFee
+		if p.Amount != expected {
+			errs = append(errs, FieldError{
+				Field:   "payment.amount",
+				Message: fmt.Sprintf("should be %d (delivery_cost + goods_total + custom_fee), got %d", expected, p.Amount),
+			})
+		}
+		return errs
+	},
+}
+
+// Currency проверяет, что валюта оплаты - код ISO 4217
+var Currency = Rule{
+	Name: "currency",
+	Check: func(o *generator.Order) []FieldError {
+		if !currencies[o.Payment.Currency] {
+			return []FieldError{{
+				Field:   "payment.currency",
+				Message: fmt.Sprintf("%q is not an ISO 4217 currency code", o.Payment.Currency),
+			}}
+		}
+		return nil
+	},
+}
+
+// Правила форматов контактов пропускают пустые значения:
+// обязательность полей проверяет Required
+
+// Phone проверяет формат телефона получателя
+var Phone = Rule{
+	Name: "phone",
+	Check: func(o *generator.Order) []FieldError {
+		phone := o.Delivery.Phone
+		if phone != "" && !phonePattern.MatchString(phone) {
+			return []FieldError{{
+				Field:   "delivery.phone",
+				Message: "should be 10 to 15 digits with an optional leading +, not starting with 0",
+			}}
+		}
+		return nil
+	},
+}
+
+// Email проверяет адрес почты получателя
+var Email = Rule{
+	Name: "email",
+	Check: func(o *generator.Order) []FieldError {
+		email := o.Delivery.Email
+		if email == "" {
+			return nil
+		}
+		// Имя вида "Name <addr>" тоже разбирается, но в заказе нужен только адрес
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Address != email {
+			return []FieldError{{Field: "delivery.email", Message: "is not a valid email address"}}
+		}
+		return nil
+	},
+}
+
+// Zip проверяет почтовый индекс получателя
+var Zip = Rule{
+	Name: "zip",
+	Check: func(o *generator.Order) []FieldError {
+		zip := o.Delivery.Zip
+		if zip != "" && !zipPattern.MatchString(zip) {
+			return []FieldError{{Field: "delivery.zip", Message: "is not a valid postal code"}}
+		}
+		return nil
+	},
+}
+
+func itemField(i int, field string) string {
+	return fmt.Sprintf("items[%d].%s", i, field)
+}
@@ -0,0 +1,85 @@
+// Package validation проверяет заказы перед сохранением: обязательные поля,
+// форматы контактов, валюту и согласованность сумм оплаты и товаров.
+// Проверка собирается из независимых правил и возвращает все нарушения сразу
+package validation
+
+import (
+	"strings"
+
+	"orders/internal/generator"
+)
+
+// FieldError - нарушение правила в конкретном поле заказа.
+// Field - путь к полю по json тегам, например items[2].total_price
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors - все нарушения, найденные в заказе
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Rule - правило проверки заказа. Check возвращает нарушения без
+// названия правила, его проставляет Validator
+type Rule struct {
+	Name  string
+	Check func(order *generator.Order) []FieldError
+}
+
+// Validator проверяет заказ набором правил
+type Validator struct {
+	rules []Rule
+}
+
+// New создает валидатор из правил, которые применяются в указанном порядке
+func New(rules ...Rule) *Validator {
+	return &Validator{rules: rules}
+}
+
+// With возвращает новый валидатор с правилами v и дополнительными rules после них
+func (v *Validator) With(rules ...Rule) *Validator {
+	return New(append(append([]Rule{}, v.rules...), rules...)...)
+}
+
+// Default - все правила пакета. Им проверяются заказы из Kafka и HTTP
+var Default = New(
+	Required,
+	ItemsNotEmpty,
+	ItemTrackNumbers,
+	SaleRange,
+	ItemTotalPrices,
+	GoodsTotal,
+	PaymentAmount,
+	Currency,
+	Phone,
+	Email,
+	Zip,
+)
+
+// Validate возвращает Errors со всеми нарушениями или nil, если заказ корректен
+func (v *Validator) Validate(order *generator.Order) error {
+	var errs Errors
+	for _, rule := range v.rules {
+		for _, fe := range rule.Check(order) {
+			fe.Rule = rule.Name
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
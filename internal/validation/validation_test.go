@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"orders/internal/generator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тестирует, что сгенерированные заказы проходят все правила
+func TestDefaultAcceptsGeneratedOrders(t *testing.T) {
+	for _, order := range generator.MakeRandomOrder(200) {
+		require.NoError(t, Default.Validate(order), "Generated order %s should be valid", order.OrderUID)
+	}
+}
+
+// Тестирует каждое правило на заказе с одним испорченным полем
+func TestRules(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(o *generator.Order)
+		field  string
+		rule   string
+	}{
+		{"Missing order uid", func(o *generator.Order) { o.OrderUID = "" }, "order_uid", "required"},
+		{"Missing customer id", func(o *generator.Order) { o.CustomerID = "" }, "customer_id", "required"},
+		{"No items", func(o *generator.Order) {
+			o.Items = nil
+			o.Payment.GoodsTotal = 0
+			o.Payment.Amount = o.Payment.DeliveryCost + o.Payment.CustomFee
+		}, "items", "items_not_empty"},
+		{"Foreign item", func(o *generator.Order) { o.Items[0].TrackNumber = "OTHER" }, "items[0].track_number", "item_track_number"},
+		{"Sale over 100", func(o *generator.Order) { o.Items[0].Sale = 150 }, "items[0].sale", "sale_range"},
+		{"Wrong item total", func(o *generator.Order) { o.Items[0].TotalPrice++; o.Payment.GoodsTotal++; o.Payment.Amount++ }, "items[0].total_price", "item_total_price"},
+		{"Wrong goods total", func(o *generator.Order) { o.Payment.GoodsTotal++; o.Payment.Amount++ }, "payment.goods_total", "goods_total"},
+		{"Wrong amount", func(o *generator.Order) { o.Payment.Amount++ }, "payment.amount", "payment_amount"},
+		{"Negative fee", func(o *generator.Order) { o.Payment.Amount -= o.Payment.CustomFee + 10; o.Payment.CustomFee = -10 }, "payment.custom_fee", "payment_amount"},
+		{"Unknown currency", func(o *generator.Order) { o.Payment.Currency = "XYZ" }, "payment.currency", "currency"},
+		{"Lowercase currency", func(o *generator.Order) { o.Payment.Currency = "rub" }, "payment.currency", "currency"},
+		{"Phone starts with 0", func(o *generator.Order) { o.Delivery.Phone = "012345678" }, "delivery.phone", "phone"},
+		{"Phone with letters", func(o *generator.Order) { o.Delivery.Phone = "+7999ABC4567" }, "delivery.phone", "phone"},
+		{"Email without domain", func(o *generator.Order) { o.Delivery.Email = "test@" }, "delivery.email", "email"},
+		{"Email with name", func(o *generator.Order) { o.Delivery.Email = "Test <test@gmail.com>" }, "delivery.email", "email"},
+		{"Zip with symbols", func(o *generator.Order) { o.Delivery.Zip = "12#45" }, "delivery.zip", "zip"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			order := generator.MakeRandomOrder(1)[0]
+			tc.modify(order)
+
+			err := Default.Validate(order)
+			require.Error(t, err)
+
+			var errs Errors
+			require.True(t, errors.As(err, &errs))
+			// Испорчено одно поле, поэтому и нарушение должно быть одно
+			require.Len(t, errs, 1, "Unexpected errors: %v", errs)
+			assert.Equal(t, tc.field, errs[0].Field)
+			assert.Equal(t, tc.rule, errs[0].Rule)
+			assert.NotEmpty(t, errs[0].Message)
+		})
+	}
+}
+
+// Тестирует форматы, которые должны приниматься
+func TestFormatsAccepted(t *testing.T) {
+	order := generator.MakeRandomOrder(1)[0]
+	order.Delivery.Phone = "+9720000000"
+	order.Delivery.Zip = "2639809"
+	order.Delivery.Email = "test@gmail.com"
+	require.NoError(t, Default.Validate(order))
+
+	// Британский индекс с пробелом
+	order.Delivery.Zip = "SW1A 1AA"
+	require.NoError(t, Default.Validate(order))
+
+	// Пустые контакты проверяются только правилом Required, а в него они не входят
+	order.Delivery.Phone, order.Delivery.Zip, order.Delivery.Email = "", "", ""
+	require.NoError(t, Default.Validate(order))
+}
+
+// Тестирует сбор всех нарушений и составной валидатор
+func TestValidator(t *testing.T) {
+	order := generator.MakeRandomOrder(1)[0]
+	order.OrderUID = ""
+	order.Payment.Currency = "XYZ"
+	order.Delivery.Phone = "0"
+
+	err := Default.Validate(order)
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	// Нарушения идут в порядке правил
+	require.Len(t, errs, 3)
+	assert.Equal(t, []string{"required", "currency", "phone"}, []string{errs[0].Rule, errs[1].Rule, errs[2].Rule})
+	assert.Equal(t, `order_uid: is required; payment.currency: "XYZ" is not an ISO 4217 currency code; `+
+		`delivery.phone: should be 10 to 15 digits with an optional leading +, not starting with 0`, err.Error())
+
+	// Валидатор из части правил не видит остальные нарушения
+	err = New(Required).Validate(order)
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 1)
+	assert.Equal(t, "order_uid", errs[0].Field)
+
+	assert.NoError(t, New(Email, Zip).Validate(order))
+}
@@ -12,8 +12,51 @@ INSERT INTO delivery (
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
--- name: GetDelivery :many
-SELECT * FROM delivery;
-
 -- name: GetSpecificDelivery :one
 SELECT * FROM delivery WHERE order_uid = $1;
+
+-- name: UpsertDelivery :exec
+INSERT INTO delivery (
+    order_uid, 
+    name,
+    phone,
+    zip,
+    city,
+    address,
+    region,
+    email
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (order_uid) DO UPDATE SET
+    name = EXCLUDED.name,
+    phone = EXCLUDED.phone,
+    zip = EXCLUDED.zip,
+    city = EXCLUDED.city,
+    address = EXCLUDED.address,
+    region = EXCLUDED.region,
+    email = EXCLUDED.email;
+
+-- name: GetDeliveriesByOrderUIDs :many
+SELECT * FROM delivery WHERE order_uid = ANY(sqlc.arg(order_uids)::text[]);
+
+-- name: CreateDeliveries :exec
+INSERT INTO delivery (
+    order_uid, 
+    name,
+    phone,
+    zip,
+    city,
+    address,
+    region,
+    email
+)
+SELECT * FROM unnest(
+    sqlc.arg(order_uids)::text[],
+    sqlc.arg(names)::text[],
+    sqlc.arg(phones)::text[],
+    sqlc.arg(zips)::text[],
+    sqlc.arg(cities)::text[],
+    sqlc.arg(addresses)::text[],
+    sqlc.arg(regions)::text[],
+    sqlc.arg(emails)::text[]
+);
@@ -16,8 +16,70 @@ INSERT INTO items (
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 RETURNING *;
 
--- name: GetItems :many
-SELECT * FROM items;
-
 -- name: GetSpecificItems :many
 SELECT * FROM items WHERE order_uid = $1;
+
+-- name: UpsertItem :exec
+INSERT INTO items (
+    order_uid, 
+    chrt_id,
+    track_number,
+    price,
+    rid,
+    name,
+    sale,
+    size,
+    total_price,
+    nm_id,
+    brand,
+    status
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (order_uid, rid) DO UPDATE SET
+    chrt_id = EXCLUDED.chrt_id,
+    track_number = EXCLUDED.track_number,
+    price = EXCLUDED.price,
+    name = EXCLUDED.name,
+    sale = EXCLUDED.sale,
+    size = EXCLUDED.size,
+    total_price = EXCLUDED.total_price,
+    nm_id = EXCLUDED.nm_id,
+    brand = EXCLUDED.brand,
+    status = EXCLUDED.status;
+
+-- name: DeleteStaleItems :exec
+DELETE FROM items
+WHERE order_uid = sqlc.arg(order_uid) AND NOT (rid = ANY(sqlc.arg(rids)::text[]));
+
+-- name: GetItemsByOrderUIDs :many
+SELECT * FROM items WHERE order_uid = ANY(sqlc.arg(order_uids)::text[]) ORDER BY item_id;
+
+-- name: CreateItems :exec
+INSERT INTO items (
+    order_uid, 
+    chrt_id,
+    track_number,
+    price,
+    rid,
+    name,
+    sale,
+    size,
+    total_price,
+    nm_id,
+    brand,
+    status
+)
+SELECT * FROM unnest(
+    sqlc.arg(order_uids)::text[],
+    sqlc.arg(chrt_ids)::int[],
+    sqlc.arg(track_numbers)::text[],
+    sqlc.arg(prices)::int[],
+    sqlc.arg(rids)::text[],
+    sqlc.arg(names)::text[],
+    sqlc.arg(sales)::int[],
+    sqlc.arg(sizes)::text[],
+    sqlc.arg(total_prices)::int[],
+    sqlc.arg(nm_ids)::int[],
+    sqlc.arg(brands)::text[],
+    sqlc.arg(statuses)::int[]
+);
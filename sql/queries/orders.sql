@@ -15,11 +15,177 @@ INSERT INTO orders (
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 RETURNING *;
 
--- name: GetOrders :many
-SELECT * FROM orders;
-
 -- name: GetSpecificOrder :one
 SELECT * FROM orders WHERE order_uid = $1;
 
 -- name: GetLatestOrders :many
 SELECT order_uid FROM orders ORDER BY date_created DESC LIMIT $1;
+
+-- name: GetOrderUIDsByTrackNumber :many
+SELECT order_uid FROM orders
+WHERE track_number = $1
+ORDER BY date_created DESC, order_uid DESC
+LIMIT $2;
+
+-- name: GetFullOrder :one
+SELECT
+    sqlc.embed(o),
+    to_jsonb(d)::jsonb AS delivery,
+    to_jsonb(p)::jsonb AS payment,
+    COALESCE(
+        (SELECT jsonb_agg(to_jsonb(i) ORDER BY i.item_id) FROM items i WHERE i.order_uid = o.order_uid),
+        '[]'
+    )::jsonb AS items
+FROM orders o
+JOIN delivery d ON d.order_uid = o.order_uid
+JOIN payments p ON p.order_uid = o.order_uid
+WHERE o.order_uid = $1;
+
+-- name: GetFullOrders :many
+SELECT
+    sqlc.embed(o),
+    to_jsonb(d)::jsonb AS delivery,
+    to_jsonb(p)::jsonb AS payment,
+    COALESCE(
+        (SELECT jsonb_agg(to_jsonb(i) ORDER BY i.item_id) FROM items i WHERE i.order_uid = o.order_uid),
+        '[]'
+    )::jsonb AS items
+FROM orders o
+JOIN delivery d ON d.order_uid = o.order_uid
+JOIN payments p ON p.order_uid = o.order_uid
+WHERE o.order_uid = ANY(sqlc.arg(order_uids)::text[])
+ORDER BY o.date_created DESC, o.order_uid DESC;
+
+-- name: InsertOrderIfNotExists :execrows
+INSERT INTO orders (
+    order_uid, 
+    track_number,
+    entry,
+    locale,
+    internal_signature,
+    customer_id,
+    delivery_service,
+    shardkey,
+    sm_id,
+    date_created,
+    oof_shard
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (order_uid) DO NOTHING;
+
+-- name: UpsertOrder :one
+INSERT INTO orders (
+    order_uid, 
+    track_number,
+    entry,
+    locale,
+    internal_signature,
+    customer_id,
+    delivery_service,
+    shardkey,
+    sm_id,
+    date_created,
+    oof_shard
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (order_uid) DO UPDATE SET
+    track_number = EXCLUDED.track_number,
+    entry = EXCLUDED.entry,
+    locale = EXCLUDED.locale,
+    internal_signature = EXCLUDED.internal_signature,
+    customer_id = EXCLUDED.customer_id,
+    delivery_service = EXCLUDED.delivery_service,
+    shardkey = EXCLUDED.shardkey,
+    sm_id = EXCLUDED.sm_id,
+    date_created = EXCLUDED.date_created,
+    oof_shard = EXCLUDED.oof_shard
+RETURNING status;
+
+-- name: ListOrders :many
+SELECT o.* FROM orders o
+JOIN payments p ON p.order_uid = o.order_uid
+WHERE (sqlc.narg(customer_id)::text IS NULL OR o.customer_id = sqlc.narg(customer_id))
+    AND (sqlc.narg(track_number)::text IS NULL OR o.track_number = sqlc.narg(track_number))
+    AND (sqlc.narg(delivery_service)::text IS NULL OR o.delivery_service = sqlc.narg(delivery_service))
+    AND (sqlc.narg(locale)::text IS NULL OR o.locale = sqlc.narg(locale))
+    AND (sqlc.narg(entry)::text IS NULL OR o.entry = sqlc.narg(entry))
+    AND (sqlc.narg(currency)::text IS NULL OR p.currency = sqlc.narg(currency))
+    AND (sqlc.narg(provider)::text IS NULL OR p.provider = sqlc.narg(provider))
+    AND (sqlc.narg(bank)::text IS NULL OR p.bank = sqlc.narg(bank))
+    AND (sqlc.narg(created_from)::timestamptz IS NULL OR o.date_created >= sqlc.narg(created_from))
+    AND (sqlc.narg(created_to)::timestamptz IS NULL OR o.date_created < sqlc.narg(created_to))
+    AND (
+        sqlc.narg(cursor_date)::timestamptz IS NULL
+        OR (o.date_created, o.order_uid) < (sqlc.narg(cursor_date), sqlc.narg(cursor_uid)::text)
+    )
+ORDER BY o.date_created DESC, o.order_uid DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetCustomerSummary :one
+SELECT
+    customer_id,
+    COUNT(*) AS orders_count,
+    MIN(date_created)::timestamptz AS first_order_at,
+    MAX(date_created)::timestamptz AS last_order_at,
+    (
+        SELECT f.delivery_service FROM orders f
+        WHERE f.customer_id = o.customer_id
+        GROUP BY f.delivery_service
+        ORDER BY COUNT(*) DESC, f.delivery_service
+        LIMIT 1
+    )::text AS favourite_delivery_service
+FROM orders o
+WHERE o.customer_id = $1
+GROUP BY o.customer_id;
+
+-- name: GetCustomerSpending :many
+SELECT p.currency, SUM(p.amount)::bigint AS total
+FROM orders o
+JOIN payments p ON p.order_uid = o.order_uid
+WHERE o.customer_id = $1
+GROUP BY p.currency
+ORDER BY p.currency;
+
+-- name: InsertOrdersIfNotExist :many
+-- Даты передаются текстом в RFC 3339: так массив не зависит от того,
+-- как драйвер записывает время
+INSERT INTO orders (
+    order_uid, 
+    track_number,
+    entry,
+    locale,
+    internal_signature,
+    customer_id,
+    delivery_service,
+    shardkey,
+    sm_id,
+    date_created,
+    oof_shard
+)
+SELECT
+    u.order_uid,
+    u.track_number,
+    u.entry,
+    u.locale,
+    NULLIF(u.internal_signature, ''),
+    u.customer_id,
+    u.delivery_service,
+    u.shardkey,
+    u.sm_id,
+    u.date_created::timestamptz,
+    u.oof_shard
+FROM unnest(
+    sqlc.arg(order_uids)::text[],
+    sqlc.arg(track_numbers)::text[],
+    sqlc.arg(entries)::text[],
+    sqlc.arg(locales)::text[],
+    sqlc.arg(internal_signatures)::text[],
+    sqlc.arg(customer_ids)::text[],
+    sqlc.arg(delivery_services)::text[],
+    sqlc.arg(shardkeys)::text[],
+    sqlc.arg(sm_ids)::int[],
+    sqlc.arg(dates_created)::text[],
+    sqlc.arg(oof_shards)::text[]
+) AS u(order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
+ON CONFLICT (order_uid) DO NOTHING
+RETURNING order_uid;
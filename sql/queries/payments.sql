@@ -15,8 +15,82 @@ INSERT INTO payments (
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 RETURNING *;
 
--- name: GetPayment :many
-SELECT * FROM payments;
-
 -- name: GetSpecificPayment :one
 SELECT * FROM payments WHERE order_uid = $1;
+
+-- name: UpsertPayment :exec
+INSERT INTO payments (
+    order_uid, 
+    transaction,
+    request_id,
+    currency,
+    provider,
+    amount,
+    payment_dt,
+    bank,
+    delivery_cost,
+    goods_total,
+    custom_fee
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (order_uid) DO UPDATE SET
+    transaction = EXCLUDED.transaction,
+    request_id = EXCLUDED.request_id,
+    currency = EXCLUDED.currency,
+    provider = EXCLUDED.provider,
+    amount = EXCLUDED.amount,
+    payment_dt = EXCLUDED.payment_dt,
+    bank = EXCLUDED.bank,
+    delivery_cost = EXCLUDED.delivery_cost,
+    goods_total = EXCLUDED.goods_total,
+    custom_fee = EXCLUDED.custom_fee;
+
+-- name: GetPaymentsByOrderUIDs :many
+SELECT * FROM payments WHERE order_uid = ANY(sqlc.arg(order_uids)::text[]);
+
+-- name: GetOrderUIDsByTransaction :many
+SELECT p.order_uid FROM payments p
+JOIN orders o ON o.order_uid = p.order_uid
+WHERE p.transaction = $1
+ORDER BY o.date_created DESC, o.order_uid DESC
+LIMIT $2;
+
+-- name: CreatePayments :exec
+INSERT INTO payments (
+    order_uid, 
+    transaction,
+    request_id,
+    currency,
+    provider,
+    amount,
+    payment_dt,
+    bank,
+    delivery_cost,
+    goods_total,
+    custom_fee
+)
+SELECT
+    u.order_uid,
+    u.transaction,
+    NULLIF(u.request_id, ''),
+    u.currency,
+    u.provider,
+    u.amount,
+    u.payment_dt,
+    u.bank,
+    u.delivery_cost,
+    u.goods_total,
+    u.custom_fee
+FROM unnest(
+    sqlc.arg(order_uids)::text[],
+    sqlc.arg(transactions)::text[],
+    sqlc.arg(request_ids)::text[],
+    sqlc.arg(currencies)::text[],
+    sqlc.arg(providers)::text[],
+    sqlc.arg(amounts)::int[],
+    sqlc.arg(payment_dts)::bigint[],
+    sqlc.arg(banks)::text[],
+    sqlc.arg(delivery_costs)::int[],
+    sqlc.arg(goods_totals)::int[],
+    sqlc.arg(custom_fees)::int[]
+) AS u(order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee);
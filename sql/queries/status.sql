@@ -0,0 +1,28 @@
+-- name: CreateInitialStatus :exec
+INSERT INTO order_status_history (order_uid, to_status)
+SELECT sqlc.arg(order_uid)::text, sqlc.arg(to_status)::text
+WHERE NOT EXISTS (
+    SELECT 1 FROM order_status_history WHERE order_uid = sqlc.arg(order_uid)::text
+);
+
+-- name: GetOrderStatusForUpdate :one
+SELECT status FROM orders WHERE order_uid = $1 FOR UPDATE;
+
+-- name: UpdateOrderStatus :exec
+UPDATE orders SET status = $2 WHERE order_uid = $1;
+
+-- name: CreateStatusChange :one
+INSERT INTO order_status_history (order_uid, from_status, to_status, reason)
+VALUES ($1, $2, $3, $4)
+RETURNING changed_at;
+
+-- name: GetStatusHistory :many
+SELECT * FROM order_status_history WHERE order_uid = $1 ORDER BY id;
+
+-- name: CreateInitialStatuses :exec
+INSERT INTO order_status_history (order_uid, to_status)
+SELECT u.order_uid, sqlc.arg(to_status)::text
+FROM unnest(sqlc.arg(order_uids)::text[]) AS u(order_uid)
+WHERE NOT EXISTS (
+    SELECT 1 FROM order_status_history h WHERE h.order_uid = u.order_uid
+);
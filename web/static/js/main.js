@@ -2,44 +2,83 @@ const getId = (id) => document.getElementById(id);
 
 const orderInfo = getId("orderInfo");
 const orderNotFound = getId("orderNotFound");
+const orderMatches = getId("orderMatches");
 const orderContainer = getId("orderContainer");
 const terminalOutput = getId("terminalOutput");
 const inputField = getId("orderInput");
 
 inputField.addEventListener("keydown", function (event) {
     if (event.key === "Enter") {
-        const orderUID = inputField.value;
+        const query = inputField.value.trim();
 
-        loadOrder(orderUID);
+        loadOrder(query);
     }
 });
 
-function loadOrder(orderUID) {
-    if (orderUID != "") {
-        fetch(`/orders/${orderUID}`)
-            .then((responce) => {
-                if (responce.status === 404) {
-                    return null;
-                }
-                return responce.json();
-            })
-            .then((order) => {
-                if (order) {
-                    renderOrderInfo(order);
+const uuidPattern =
+    /^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$/i;
+const trackPattern = /^[A-Z0-9]+$/;
+
+// Адреса поиска по каждому виду идентификатора. Поиск по трек-номеру
+// и транзакции возвращает список заказов, от новых к старым
+const lookups = {
+    uid: (value) => `/orders/${encodeURIComponent(value)}`,
+    track: (value) => `/orders/by-track/${encodeURIComponent(value)}`,
+    transaction: (value) =>
+        `/orders/by-transaction/${encodeURIComponent(value)}`,
+};
+
+// Определяет по виду строки, в каком порядке ее искать. Трек-номер
+// и транзакция могут совпадать по формату, поэтому пробуем оба
+function detectLookups(query) {
+    if (uuidPattern.test(query)) {
+        return ["uid", "transaction"];
+    }
+    if (trackPattern.test(query)) {
+        return ["track", "transaction", "uid"];
+    }
+    return ["uid", "transaction", "track"];
+}
+
+// Возвращает все найденные заказы: по uid находится не больше одного,
+// а по трек-номеру или транзакции - сразу несколько
+async function findOrders(query) {
+    for (const kind of detectLookups(query)) {
+        const responce = await fetch(lookups[kind](query));
+        if (responce.status === 404) {
+            continue;
+        }
+        if (!responce.ok) {
+            throw new Error(`Unexpected status ${responce.status}`);
+        }
+
+        const found = await responce.json();
+        return Array.isArray(found) ? found : [found];
+    }
+    return [];
+}
+
+function loadOrder(query) {
+    if (query != "") {
+        findOrders(query)
+            .then((orders) => {
+                if (orders.length > 0) {
+                    renderOrderMatches(orders);
                 } else {
-                    renderOrderNotFound(orderUID);
+                    renderOrderNotFound(query);
                 }
             })
             .catch((error) => {
                 console.error("Error fetching order:", error);
             });
     } else {
-        console.log("Empty order_uid is ignored");
+        console.log("Empty query is ignored");
     }
 }
 
 function renderOrderNotFound(orderUID) {
     orderInfo.style.display = "none";
+    orderMatches.style.display = "none";
     orderContainer.style.display = "flex";
     terminalOutput.style.display = "block";
     orderNotFound.style.display = "block";
@@ -81,6 +120,38 @@ function renderOrderNotFound(orderUID) {
     });
 }
 
+// Показывает первый из найденных заказов, а если их несколько - еще и
+// список всех совпадений, чтобы переключаться между ними
+function renderOrderMatches(orders) {
+    renderOrderInfo(orders[0]);
+
+    if (orders.length < 2) {
+        orderMatches.style.display = "none";
+        return;
+    }
+    orderMatches.style.display = "block";
+    getId("matchesAmount").textContent = orders.length;
+
+    const matchesList = getId("matchesList");
+    matchesList.innerHTML = "";
+
+    orders.forEach((order, i) => {
+        const button = document.createElement("button");
+        button.textContent = order.order_uid;
+        if (i === 0) {
+            button.classList.add("active");
+        }
+        button.addEventListener("click", () => {
+            matchesList
+                .querySelectorAll("button")
+                .forEach((b) => b.classList.remove("active"));
+            button.classList.add("active");
+            renderOrderInfo(order);
+        });
+        matchesList.appendChild(button);
+    });
+}
+
 function renderOrderInfo(order) {
     terminalOutput.style.display = "none";
     orderNotFound.style.display = "none";
@@ -93,6 +164,7 @@ function renderOrderInfo(order) {
     getId("orderUID").textContent = order.order_uid;
     getId("trackNumber").textContent = order.track_number;
     getId("creationDate").textContent = dateFormatted;
+    getId("orderStatus").textContent = order.status;
     getId("locale").textContent = order.locale;
     getId("deliveryService").textContent = order.delivery_service;
     getId("internalSignature").textContent = order.internal_signature;